@@ -28,7 +28,8 @@ go clean -cache
 
 # Step 7: Build the project
 echo "Building the project..."
-go build -o backup-butler ./cmd/main.go
+go build -ldflags "-X github.com/jack-sneddon/backup-butler/internal/backup.ToolVersion=$(git describe --tags --always --dirty)" \
+  -o backup-butler ./cmd/main.go
 # go build ./...
 
 # Step 8: Run the binary to test
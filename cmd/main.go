@@ -2,14 +2,31 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/jack-sneddon/backup-butler/internal/backup"
 )
 
+// slowestFilesShown is how many of the slowest copies printVersionDetails lists
+const slowestFilesShown = 5
+
+// configList collects repeated -config flags in order
+type configList []string
+
+func (c *configList) String() string {
+	return strings.Join(*c, ",")
+}
+
+func (c *configList) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
 func printHelp() {
 	fmt.Print(`backup-butler - Backup Utility
 
@@ -17,38 +34,117 @@ Usage:
   backup-butler [options]
 
 Options:
-  -config <file>       Path to the configuration file (JSON or YAML)
+  -config <file>       Path to the configuration file (JSON or YAML); repeat to
+                       layer overrides, later files win field by field
   --help, -h          Show this help message and exit
   --verbose, -v       Enable verbose logging
   --quiet, -q         Suppress all output except errors
+  --output <format>   Output format for --list-versions: text (default) or json
   --validate          Validate the configuration file without performing a backup
   --dry-run           Simulate the backup process without making any changes
+  --ignore-existing   Skip every file that already exists at the destination
+  --force             Let mirror mode delete more than max_delete_percent of the target
+  --explain           Log the reason each file is copied or skipped (new, size-differs,
+                      checksum-differs, excluded, ignore-existing, update-skip, ...)
+  --since-last        Skip files unmodified since the last completed backup without checking
+                      the target; run without it now and then for a full comparison
+  --diagnostics       After a backup, report worker utilization, bytes per read, retries,
+                      and time spent stat-ing, hashing and copying
+  --itemize           Print an rsync-style change code per copied or deleted file
+                      (e.g. ">f+++++++++ path" for a new file) instead of the progress bar
+  --resume            Continue the most recent interrupted backup from its autosave
+  --preflight         Run all runtime checks (folders, writability, overlap, space) without copying
   --log-level <level> Set logging level: info, warn, error
   --list-versions     List all backup versions
   --show-version <id> Show details of a specific backup version
   --latest-version    Show most recent backup details
+  --stats             Show cumulative statistics across all backups
+  --compare-versions-to-disk
+                      Report target files missing from the latest manifest and vice versa
+  --repair <id>       Re-copy files of a backup version that are missing or corrupt
+  --restore <id>      Restore a backup version into the directory given by --restore-dest;
+                      combine with --dry-run to report what would be written
+  --restore-dest <dir>
+                      Destination directory for --restore; when omitted, files go back
+                      to the version's original source directory, which requires --yes
+  --yes               Confirm restoring over the original source directory
+  --test-restore <id> Restore a backup version to a temporary directory, verify it, and clean up
+  --verify-restore <id> <dir>
+                      Check a restored directory (or the target) against a backup version's
+                      checksums, reporting missing, mismatched and extra files; changes nothing
+  --empty-trash       Permanently remove files moved to the target's .trash by mirror mode
+  --benchmark         Measure copy throughput for several concurrency and buffer size settings
+  --rebuild-index     Validate every version file and reload the history
+  --quarantine-bad    With --rebuild-index, move corrupt version files to .versions/.quarantine
+  --migrate-checksums Recompute every version manifest with the configured checksum_algorithm
+  --pattern-stats     Show how many files and bytes each exclude pattern matches
+  --cleanup-logs      Remove run logs beyond log_retention_count / log_retention_days
+  --check-manifest <file>
+                      Compare source files against a sha256sum-style checksum manifest
+  --diff-source <id>  Report source files that are new, modified or deleted since a backup
+                      version, comparing checksums where the version recorded them
+  --diff-versions <id> <other>
+                      Report files added, modified or deleted between two backup versions;
+                      versions with the same root hash are reported identical at once
+  --find-duplicates   Report sets of identical source files and the space they waste
+  --checksum <file> [<other>]
+                      Print a file's hash with the configured checksum_algorithm, or
+                      whether two files are identical
 
 Examples:
   backup-butler -config backup_config.json
   backup-butler -config backup_config.yaml --dry-run --verbose
+  backup-butler -config base.yaml -config machine.yaml
   backup-butler -config backup_config.yaml --list-versions
   backup-butler -config backup_config.yaml --show-version 20240117-150405
   backup-butler -config backup_config.yaml --latest-version
+  backup-butler -config backup_config.yaml --repair 20240117-150405
+  backup-butler -config backup_config.yaml --restore 20240117-150405 --restore-dest /tmp/restored --dry-run
 `)
 }
 
 func main() {
 	// Parse CLI flags
-	configPath := flag.String("config", "", "Path to the configuration file")
+	var configPaths configList
+	flag.Var(&configPaths, "config", "Path to the configuration file (repeatable)")
 	helpFlag := flag.Bool("help", false, "Show help message")
 	verboseFlag := flag.Bool("verbose", false, "Enable verbose logging")
 	quietFlag := flag.Bool("quiet", false, "Suppress all output except errors")
+	outputFlag := flag.String("output", "text", "Output format for --list-versions: text or json")
 	validateFlag := flag.Bool("validate", false, "Validate the configuration file without performing a backup")
 	dryRunFlag := flag.Bool("dry-run", false, "Simulate the backup process without making any changes")
+	itemizeFlag := flag.Bool("itemize", false, "Print an rsync-style itemized change line per file")
+	ignoreExisting := flag.Bool("ignore-existing", false, "Skip files that already exist at the destination")
+	forceFlag := flag.Bool("force", false, "Allow mirror deletions beyond max_delete_percent")
+	diagnosticsFlag := flag.Bool("diagnostics", false, "Report worker utilization and phase timings after a backup")
+	explainFlag := flag.Bool("explain", false, "Log why each file is copied or skipped")
+	sinceLastFlag := flag.Bool("since-last", false, "Only check files modified since the last completed backup")
+	resumeFlag := flag.Bool("resume", false, "Continue the most recent interrupted backup from its autosave")
+	preflightFlag := flag.Bool("preflight", false, "Run all runtime checks without copying")
 	logLevel := flag.String("log-level", "info", "Set logging level: info, warn, error")
 	listVersions := flag.Bool("list-versions", false, "List all backup versions")
 	showVersion := flag.String("show-version", "", "Show details of a specific backup version")
 	latestVersion := flag.Bool("latest-version", false, "Show most recent backup details")
+	statsFlag := flag.Bool("stats", false, "Show cumulative statistics across all backups")
+	auditFlag := flag.Bool("compare-versions-to-disk", false, "Report inconsistencies between the latest manifest and the target")
+	repairVersion := flag.String("repair", "", "Re-copy files of a backup version that are missing or corrupt")
+	restoreVersion := flag.String("restore", "", "Restore a backup version into --restore-dest")
+	restoreDest := flag.String("restore-dest", "", "Destination directory for --restore (default: the original source)")
+	yesFlag := flag.Bool("yes", false, "Confirm restoring over the original source directory")
+	testRestore := flag.String("test-restore", "", "Restore a backup version to a temporary directory and verify it")
+	verifyRestore := flag.String("verify-restore", "", "Check a restored directory, given after the flags, against a backup version")
+	emptyTrash := flag.Bool("empty-trash", false, "Permanently remove files in the target's .trash")
+	benchmarkFlag := flag.Bool("benchmark", false, "Measure copy throughput for several concurrency/buffer settings")
+	rebuildIndex := flag.Bool("rebuild-index", false, "Validate every version file and reload the history")
+	migrateChecksums := flag.Bool("migrate-checksums", false, "Recompute every version manifest with the configured checksum_algorithm")
+	quarantineBad := flag.Bool("quarantine-bad", false, "With --rebuild-index, move corrupt version files to .versions/.quarantine")
+	patternStats := flag.Bool("pattern-stats", false, "Show how many files and bytes each exclude pattern matches")
+	cleanupLogs := flag.Bool("cleanup-logs", false, "Remove run logs beyond the configured log retention")
+	checkManifest := flag.String("check-manifest", "", "Compare source files against a sha256sum-style checksum manifest")
+	diffSource := flag.String("diff-source", "", "Compare a backup version with the current source")
+	diffVersions := flag.String("diff-versions", "", "Compare a backup version with a second one given after the flags")
+	findDuplicates := flag.Bool("find-duplicates", false, "Report sets of identical source files")
+	checksumFile := flag.String("checksum", "", "Print a file's hash, or compare it with a second file given after it")
 
 	flag.Parse()
 
@@ -59,14 +155,14 @@ func main() {
 	}
 
 	// Validate required flags
-	if *configPath == "" {
+	if len(configPaths) == 0 {
 		fmt.Println("Error: -config flag is required.")
 		printHelp()
 		os.Exit(1)
 	}
 
 	// Create backup configuration
-	cfg, err := backup.LoadConfig(*configPath)
+	cfg, err := backup.LoadConfigs(configPaths...)
 	if err != nil {
 		fmt.Printf("Failed to load configuration: %v\n", err)
 		os.Exit(1)
@@ -74,9 +170,16 @@ func main() {
 
 	// Set configuration options from flags
 	cfg.Options = &backup.Options{
-		Verbose:  *verboseFlag,
-		Quiet:    *quietFlag,
-		LogLevel: *logLevel,
+		Verbose:        *verboseFlag,
+		Quiet:          *quietFlag,
+		LogLevel:       *logLevel,
+		Resume:         *resumeFlag,
+		Itemize:        *itemizeFlag,
+		IgnoreExisting: *ignoreExisting,
+		Force:          *forceFlag,
+		Diagnostics:    *diagnosticsFlag,
+		Explain:        *explainFlag,
+		SinceLast:      *sinceLastFlag,
 	}
 
 	// Create backup service
@@ -88,7 +191,7 @@ func main() {
 
 	// Handle version management flags
 	if *listVersions {
-		printVersionList(service)
+		printVersionList(service, *outputFlag)
 		return
 	}
 	if *showVersion != "" {
@@ -104,6 +207,88 @@ func main() {
 		printVersionDetails(service, version.ID)
 		return
 	}
+	if *statsFlag {
+		printLifetimeStats(service)
+		return
+	}
+	if *auditFlag {
+		runAudit(service)
+		return
+	}
+	if *repairVersion != "" {
+		runRepair(service, *repairVersion)
+		return
+	}
+	if *restoreVersion != "" {
+		runRestore(service, *restoreVersion, *restoreDest, *dryRunFlag, *yesFlag)
+		return
+	}
+	if *testRestore != "" {
+		runTestRestore(service, *testRestore)
+		return
+	}
+	if *verifyRestore != "" {
+		runVerifyRestore(service, *verifyRestore, flag.Args())
+		return
+	}
+	if *checkManifest != "" {
+		runCheckManifest(service, *checkManifest)
+		return
+	}
+	if *diffSource != "" {
+		runDiffSource(service, *diffSource)
+		return
+	}
+	if *diffVersions != "" {
+		runDiffVersions(service, *diffVersions, flag.Args())
+		return
+	}
+	if *checksumFile != "" {
+		runChecksum(service, *checksumFile, flag.Args())
+		return
+	}
+	if *findDuplicates {
+		runFindDuplicates(service)
+		return
+	}
+	if *emptyTrash {
+		removed, err := service.EmptyTrash(0)
+		if err != nil {
+			fmt.Printf("Failed to empty trash: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Emptied %d trashed runs.\n", removed)
+		return
+	}
+	if *rebuildIndex {
+		runRebuildIndex(service, *quarantineBad)
+		return
+	}
+	if *migrateChecksums {
+		runMigrateChecksums(service)
+		return
+	}
+	if *patternStats {
+		runPatternStats(service)
+		return
+	}
+	if *benchmarkFlag {
+		runBenchmark(service)
+		return
+	}
+	if *cleanupLogs {
+		if cfg.LogRetentionCount == 0 && cfg.LogRetentionDays == 0 {
+			fmt.Println("No log retention configured; set log_retention_count or log_retention_days.")
+			os.Exit(1)
+		}
+		removed, err := service.CleanupLogs()
+		if err != nil {
+			fmt.Printf("Failed to clean up logs: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d old log files.\n", removed)
+		return
+	}
 
 	// Validate configuration if requested
 	if *validateFlag {
@@ -115,6 +300,12 @@ func main() {
 		return
 	}
 
+	// Run runtime checks if requested
+	if *preflightFlag {
+		runPreflight(service)
+		return
+	}
+
 	// Create context for the operation
 	ctx := context.Background()
 
@@ -142,7 +333,22 @@ func main() {
 	}
 }
 
-func printVersionList(service *backup.Service) {
+func printVersionList(service *backup.Service, output string) {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(service.VersionSummaries(), "", "  ")
+		if err != nil {
+			fmt.Printf("Failed to encode versions: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	case "text":
+	default:
+		fmt.Printf("Unknown output format %q (want text or json)\n", output)
+		os.Exit(1)
+	}
+
 	versions := service.GetVersions()
 	if len(versions) == 0 {
 		fmt.Println("No backup versions found")
@@ -163,6 +369,449 @@ func printVersionList(service *backup.Service) {
 	}
 }
 
+func runPreflight(service *backup.Service) {
+	failed := 0
+	for _, check := range service.Preflight() {
+		result := "PASS"
+		if !check.Passed {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Printf("[%s] %s: %s\n", result, check.Name, check.Detail)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d preflight checks failed.\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("\nAll preflight checks passed.")
+}
+
+func runVerifyRestore(service *backup.Service, id string, args []string) {
+	if len(args) != 1 {
+		fmt.Println("--verify-restore takes a version ID and the directory to check")
+		os.Exit(1)
+	}
+
+	result, err := service.VerifyRestoredTree(context.Background(), id, args[0])
+	if result == nil {
+		fmt.Printf("Restore verification failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nRestored tree %s against version %s\n", result.Dir, result.VersionID)
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Files checked: %d\n", result.Checked)
+	for _, section := range []struct {
+		label string
+		paths []string
+	}{
+		{"Missing", result.Missing},
+		{"Mismatched", result.Mismatched},
+		{"Extra", result.Extra},
+	} {
+		fmt.Printf("%s: %d\n", section.label, len(section.paths))
+		for _, path := range section.paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+
+	if err != nil {
+		fmt.Printf("Restore verification incomplete: %v\n", err)
+		os.Exit(1)
+	}
+	if !result.OK() {
+		os.Exit(1)
+	}
+	fmt.Println("\nRestored tree matches the version.")
+}
+
+func runCheckManifest(service *backup.Service, manifestPath string) {
+	result, err := service.CheckManifest(context.Background(), manifestPath)
+	if result == nil {
+		fmt.Printf("Manifest check failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nManifest check: %s\n", manifestPath)
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Files checked: %d\n", result.Checked)
+	fmt.Printf("Mismatched: %d\n", len(result.Mismatched))
+	for _, path := range result.Mismatched {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Printf("Missing: %d\n", len(result.Missing))
+	for _, path := range result.Missing {
+		fmt.Printf("  %s\n", path)
+	}
+
+	if err != nil {
+		fmt.Printf("Manifest check failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(result.Mismatched) > 0 || len(result.Missing) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runDiffSource(service *backup.Service, id string) {
+	result, err := service.DiffSource(context.Background(), id)
+	if result == nil {
+		fmt.Printf("Source diff failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nSource changes since version %s\n", result.VersionID)
+	fmt.Printf("-------------------------\n")
+	for _, section := range []struct {
+		label string
+		paths []string
+	}{
+		{"New", result.New},
+		{"Modified", result.Modified},
+		{"Deleted", result.Deleted},
+	} {
+		fmt.Printf("%s: %d\n", section.label, len(section.paths))
+		for _, path := range section.paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	fmt.Printf("Unchanged: %d\n", result.Unchanged)
+
+	if err != nil {
+		fmt.Printf("Source diff incomplete: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runDiffVersions(service *backup.Service, id string, args []string) {
+	if len(args) != 1 {
+		fmt.Println("--diff-versions takes two version IDs")
+		os.Exit(1)
+	}
+
+	result, err := service.DiffVersions(id, args[0])
+	if err != nil {
+		fmt.Printf("Version diff failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nChanges from version %s to %s\n", result.From, result.To)
+	fmt.Printf("-------------------------\n")
+	if result.SameRoot {
+		fmt.Println("Root hashes match; the versions have identical contents.")
+		return
+	}
+	for _, section := range []struct {
+		label string
+		paths []string
+	}{
+		{"Added", result.Added},
+		{"Modified", result.Modified},
+		{"Deleted", result.Deleted},
+	} {
+		fmt.Printf("%s: %d\n", section.label, len(section.paths))
+		for _, path := range section.paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	fmt.Printf("Unchanged: %d\n", result.Unchanged)
+}
+
+func runFindDuplicates(service *backup.Service) {
+	report, err := service.FindDuplicates(context.Background())
+	if report == nil {
+		fmt.Printf("Duplicate search failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nDuplicate files in source\n")
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Files scanned: %d (%d hashed)\n", report.FilesScanned, report.FilesHashed)
+	for _, set := range report.Sets {
+		fmt.Printf("\n%d copies of %.2f MB (%.2f MB reclaimable):\n",
+			len(set.Paths), float64(set.Size)/1024/1024, float64(set.Reclaimable())/1024/1024)
+		for _, path := range set.Paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	fmt.Printf("\nDuplicate sets: %d, total reclaimable: %.2f MB\n",
+		len(report.Sets), float64(report.Reclaimable)/1024/1024)
+
+	if err != nil {
+		fmt.Printf("Duplicate search incomplete: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printLifetimeStats(service *backup.Service) {
+	stats, err := service.GetLifetimeStats()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if stats.TotalRuns == 0 {
+		fmt.Println("No completed backups yet")
+		return
+	}
+
+	fmt.Printf("\nLifetime Statistics:\n")
+	fmt.Printf("--------------------\n")
+	fmt.Printf("You've backed up %.2f GB across %d runs\n", float64(stats.TotalBytesCopied)/1024/1024/1024, stats.TotalRuns)
+	fmt.Printf("  Files Processed: %d\n", stats.TotalFiles)
+	fmt.Printf("  Files Copied: %d\n", stats.FilesBackedUp)
+	fmt.Printf("  Total Time: %v\n", stats.TotalDuration)
+	fmt.Printf("  First Run: %s\n", stats.FirstRun.Format(time.RFC3339))
+	fmt.Printf("  Last Run: %s\n", stats.LastRun.Format(time.RFC3339))
+}
+
+func runAudit(service *backup.Service) {
+	result, err := service.AuditLatestVersion()
+	if err != nil {
+		fmt.Printf("Audit failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nAudit of version %s against disk\n", result.VersionID)
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Orphans on target (not in manifest): %d\n", len(result.Orphans))
+	for _, path := range result.Orphans {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Printf("Missing from target (in manifest): %d\n", len(result.Missing))
+	for _, path := range result.Missing {
+		fmt.Printf("  %s\n", path)
+	}
+
+	if len(result.Orphans) > 0 || len(result.Missing) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runRepair(service *backup.Service, id string) {
+	result, err := service.Repair(context.Background(), id)
+	if result == nil {
+		fmt.Printf("Repair failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nRepair of version %s\n", id)
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Files checked: %d\n", result.Verify.Checked)
+	fmt.Printf("Missing: %d, Corrupt: %d\n", len(result.Verify.Missing), len(result.Verify.Corrupt))
+	if len(result.Verify.Quarantined) > 0 {
+		fmt.Printf("Quarantined: %d\n", len(result.Verify.Quarantined))
+		for _, path := range result.Verify.Quarantined {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	fmt.Printf("Repaired: %d\n", result.Repaired)
+	fmt.Printf("Unrepairable (source gone): %d\n", len(result.Unrepairable))
+	for _, path := range result.Unrepairable {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Printf("Unrepairable (source changed): %d\n", len(result.SourceChanged))
+	for _, path := range result.SourceChanged {
+		fmt.Printf("  %s\n", path)
+	}
+
+	if err != nil {
+		fmt.Printf("Repair failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runRestore(service *backup.Service, id, dest string, dryRun, confirmed bool) {
+	// Without a destination, put everything back where it was backed up from
+	if dest == "" {
+		source, err := service.VersionSource(id)
+		if err != nil {
+			fmt.Printf("Restore failed: %v\n", err)
+			os.Exit(1)
+		}
+		if !dryRun && !confirmed {
+			fmt.Printf("This restores version %s over the original source %s.\n", id, source)
+			fmt.Println("Re-run with --yes to confirm, or give --restore-dest to restore elsewhere.")
+			os.Exit(1)
+		}
+		fmt.Printf("Restoring to the original source directory %s\n", source)
+		dest = source
+	}
+
+	if dryRun {
+		if err := service.RestoreDryRun(context.Background(), id, dest); err != nil {
+			fmt.Printf("Restore dry run failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := service.Restore(context.Background(), id, dest); err != nil {
+		fmt.Printf("Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runRebuildIndex(service *backup.Service, quarantineBad bool) {
+	report, err := service.RebuildIndex(quarantineBad)
+	if report == nil {
+		fmt.Printf("Index rebuild failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nVersion index\n")
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Valid version files: %d\n", report.Valid)
+	fmt.Printf("Corrupt version files: %d\n", len(report.Corrupt))
+	for _, line := range report.Corrupt {
+		fmt.Printf("  %s\n", line)
+	}
+	if len(report.Quarantined) > 0 {
+		fmt.Printf("Quarantined:\n")
+		for _, path := range report.Quarantined {
+			fmt.Printf("  %s\n", path)
+		}
+	} else if len(report.Corrupt) > 0 {
+		fmt.Println("Run again with --quarantine-bad to move them aside.")
+	}
+
+	if err != nil {
+		fmt.Printf("Index rebuild failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runChecksum(service *backup.Service, path string, others []string) {
+	if len(others) > 1 {
+		fmt.Println("--checksum takes one file, or two files to compare")
+		os.Exit(1)
+	}
+
+	checksum, err := service.Checksum(path)
+	if err != nil {
+		fmt.Printf("Checksum failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(others) == 0 {
+		fmt.Printf("%s  %s\n", checksum, path)
+		return
+	}
+
+	other, err := service.Checksum(others[0])
+	if err != nil {
+		fmt.Printf("Checksum failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s  %s\n%s  %s\n", checksum, path, other, others[0])
+	if checksum != other {
+		fmt.Printf("Files differ (%s)\n", service.ChecksumAlgorithm())
+		os.Exit(1)
+	}
+	fmt.Printf("Files are identical (%s)\n", service.ChecksumAlgorithm())
+}
+
+func runMigrateChecksums(service *backup.Service) {
+	result, err := service.MigrateChecksums(context.Background())
+	if result == nil {
+		fmt.Printf("Checksum migration failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if result.From == "" || result.From == result.To {
+		fmt.Printf("Version manifests already use %s.\n", result.To)
+	} else {
+		fmt.Printf("\nChecksum migration %s -> %s\n", result.From, result.To)
+		fmt.Printf("-------------------------\n")
+		fmt.Printf("Versions rewritten: %d\n", result.Versions)
+		fmt.Printf("Checksums converted: %d\n", result.Converted)
+		if len(result.Dropped) > 0 {
+			fmt.Printf("Checksums dropped (copy changed or missing; verified by size): %d\n", len(result.Dropped))
+			for _, path := range result.Dropped {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+	}
+
+	if err != nil {
+		fmt.Printf("Checksum migration failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runPatternStats(service *backup.Service) {
+	stats, err := service.PatternStats()
+	if err != nil {
+		fmt.Printf("Pattern stats failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(stats) == 0 {
+		fmt.Println("No exclude_patterns or exclude_paths configured.")
+		return
+	}
+
+	fmt.Printf("\n%-18s %-30s %-8s %8s %12s\n", "Setting", "Pattern", "Effect", "Files", "Size")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, stat := range stats {
+		effect := "exclude"
+		if stat.Include() {
+			effect = "include"
+		}
+		fmt.Printf("%-18s %-30s %-8s %8d %9.2f MB\n",
+			stat.Setting, stat.Pattern, effect, stat.Files, float64(stat.Bytes)/1024/1024)
+	}
+}
+
+func runBenchmark(service *backup.Service) {
+	fmt.Println("Benchmarking copy throughput with synthetic data...")
+	results, err := service.Benchmark(context.Background())
+	if err != nil {
+		fmt.Printf("Benchmark failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%-12s %-12s %12s\n", "Concurrency", "Buffer", "Throughput")
+	fmt.Println("--------------------------------------")
+	var best backup.BenchmarkResult
+	for _, r := range results {
+		fmt.Printf("%-12d %-12s %7.2f MB/s\n", r.Concurrency, fmt.Sprintf("%d KB", r.BufferSize/1024), r.MBps)
+		if r.MBps > best.MBps {
+			best = r
+		}
+	}
+	if best.MBps > 0 {
+		fmt.Printf("\nFastest: concurrency: %d, buffer_size: %d\n", best.Concurrency, best.BufferSize)
+	}
+}
+
+func runTestRestore(service *backup.Service, id string) {
+	result, err := service.TestRestore(context.Background(), id)
+	if result == nil {
+		fmt.Printf("Test restore failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nTest restore of version %s\n", result.VersionID)
+	fmt.Printf("-------------------------\n")
+	fmt.Printf("Files restored: %d\n", result.Restored)
+	fmt.Printf("Checksums verified: %d\n", result.Verified)
+	if result.Unverified > 0 {
+		fmt.Printf("Size checked only (no checksum recorded): %d\n", result.Unverified)
+	}
+	fmt.Printf("Failed: %d\n", len(result.Failed))
+	for _, key := range result.Failed {
+		fmt.Printf("  %s\n", key)
+	}
+
+	if err != nil {
+		fmt.Printf("Test restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	if !result.OK() {
+		os.Exit(1)
+	}
+	fmt.Println("\nRestore verified successfully.")
+}
+
 func printVersionDetails(service *backup.Service, id string) {
 	version, err := service.GetVersion(id)
 	if err != nil {
@@ -175,6 +824,20 @@ func printVersionDetails(service *backup.Service, id string) {
 	fmt.Printf("Timestamp: %s\n", version.Timestamp.Format(time.RFC3339))
 	fmt.Printf("Duration: %v\n", version.Duration)
 	fmt.Printf("Status: %s\n", version.Status)
+	fmt.Printf("Host: %s\n", version.Hostname)
+	fmt.Printf("Tool Version: %s\n", version.ToolVersion)
+	if version.Snapshot != "" {
+		fmt.Printf("Snapshot: %s\n", version.Snapshot)
+	}
+	if version.Archive != "" {
+		fmt.Printf("Archive: %s\n", version.Archive)
+	}
+	if version.RootHash != "" {
+		fmt.Printf("Root Hash: %s\n", version.RootHash)
+	}
+	if version.SinceLast != "" {
+		fmt.Printf("Incremental since: %s\n", version.SinceLast)
+	}
 
 	fmt.Printf("\nStatistics:\n")
 	fmt.Printf("  Total Files Processed: %d\n", version.Stats.TotalFiles)
@@ -183,6 +846,28 @@ func printVersionDetails(service *backup.Service, id string) {
 	fmt.Printf("  Files Failed: %d\n", version.Stats.FilesFailed)
 	fmt.Printf("  Total Size: %.2f MB\n", float64(version.Stats.TotalBytes)/1024/1024)
 	fmt.Printf("  Data Transferred: %.2f MB\n", float64(version.Stats.BytesTransferred)/1024/1024)
+	fmt.Printf("  Average Throughput: %.2f MB/s\n", version.AverageMBps)
+
+	if slowest := version.SlowestFiles(slowestFilesShown); len(slowest) > 0 {
+		fmt.Printf("\nSlowest Files:\n")
+		for _, file := range slowest {
+			fmt.Printf("  %v  %s (%.2f MB)\n", file.Duration, file.Path, float64(file.Size)/1024/1024)
+		}
+	}
+
+	if len(version.Hooks) > 0 {
+		fmt.Printf("\nHooks:\n")
+		for _, hook := range version.Hooks {
+			fmt.Printf("  [%s] %s: exit %d (%v)\n", hook.Phase, hook.Command, hook.ExitCode, hook.Duration)
+		}
+	}
+
+	if len(version.Quarantined) > 0 {
+		fmt.Printf("\nQuarantined Copies:\n")
+		for _, path := range version.Quarantined {
+			fmt.Printf("  %s\n", path)
+		}
+	}
 
 	fmt.Printf("\nConfiguration Used:\n")
 	fmt.Printf("  Source Directory: %s\n", version.ConfigUsed.SourceDirectory)
@@ -3,3 +3,5 @@ module github.com/jack-sneddon/backup-butler
 go 1.23
 
 require gopkg.in/yaml.v3 v3.0.1 // for YAML configuration
+
+require go.uber.org/goleak v1.3.0 // for goroutine leak tests
@@ -0,0 +1,133 @@
+// adaptive.go
+package backup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// adaptiveInterval is how often an adaptive pool measures throughput; tests
+// shorten it
+var adaptiveInterval = 2 * time.Second
+
+// adaptiveTolerance is how far throughput may drop before a step counts as
+// a loss rather than noise
+const adaptiveTolerance = 0.05
+
+// concurrencyLimiter caps how many workers hold a slot at once. Unlike a
+// channel semaphore its limit can change while workers wait on it.
+type concurrencyLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	l := &concurrencyLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until a slot is free
+func (l *concurrencyLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// release frees a slot taken by acquire
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.cond.Broadcast()
+}
+
+// setLimit changes the number of slots. Workers above a lowered limit
+// finish their current task before the cap takes effect.
+func (l *concurrencyLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.cond.Broadcast()
+}
+
+// getLimit returns the current number of slots
+func (l *concurrencyLimiter) getLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// EnableAdaptive lets the pool tune its worker count while it runs, starting
+// from the configured count and staying within minConcurrency and
+// maxConcurrency. report, if set, is called after each adjustment.
+func (p *WorkerPool) EnableAdaptive(report func(workers int, mbps float64)) {
+	p.adaptive = true
+	p.onAdjust = report
+}
+
+// Workers returns the number of workers the pool runs at, which an adaptive
+// pool updates as it tunes itself
+func (p *WorkerPool) Workers() int {
+	if p.limiter != nil {
+		return p.limiter.getLimit()
+	}
+	return p.workers
+}
+
+// adaptConcurrency hill-climbs the limiter toward the worker count with the
+// best throughput until ctx is done. Each interval it compares the bytes
+// completed with the previous interval, keeps stepping in the same
+// direction while throughput holds up, and turns around when it drops.
+// Bytes are counted when a task finishes, so intervals in which nothing
+// finished are not judged.
+func (p *WorkerPool) adaptConcurrency(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	direction := 1
+	lastRate := -1.0
+	lastBytes := p.completedBytes.Load()
+	lastTasks := p.completedTasks.Load()
+	lastTick := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			bytes, tasks := p.completedBytes.Load(), p.completedTasks.Load()
+			elapsed := now.Sub(lastTick).Seconds()
+			if tasks == lastTasks || elapsed <= 0 {
+				continue
+			}
+			rate := float64(bytes-lastBytes) / elapsed
+			lastBytes, lastTasks, lastTick = bytes, tasks, now
+
+			if lastRate >= 0 && rate < lastRate*(1-adaptiveTolerance) {
+				direction = -direction
+			}
+			lastRate = rate
+
+			current := p.limiter.getLimit()
+			next := current + direction
+			if next < minConcurrency || next > maxConcurrency {
+				direction = -direction
+				next = current + direction
+			}
+			if next < minConcurrency || next > maxConcurrency {
+				continue
+			}
+			p.limiter.setLimit(next)
+			if p.onAdjust != nil {
+				p.onAdjust(next, rate/1024/1024)
+			}
+		}
+	}
+}
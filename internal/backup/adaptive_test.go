@@ -0,0 +1,86 @@
+// adaptive_test.go
+package backup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	defer func(original time.Duration) { adaptiveInterval = original }(adaptiveInterval)
+	adaptiveInterval = 50 * time.Millisecond
+
+	tests := []struct {
+		name  string
+		start int // Configured concurrency
+		best  int // Worker count with the highest simulated throughput
+	}{
+		{"ramps up from one worker", 1, 4},
+		{"backs off from too many", 8, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A simulated disk: each copy takes 2ms up to the best worker
+			// count, beyond which contention slows every copy quadratically,
+			// so total throughput peaks at tt.best
+			var active atomic.Int64
+			copyFn := func(task CopyTask) error {
+				n := active.Add(1)
+				defer active.Add(-1)
+				delay := 2 * time.Millisecond
+				if over := float64(n) / float64(tt.best); over > 1 {
+					delay = time.Duration(float64(delay) * over * over)
+				}
+				time.Sleep(delay)
+				return nil
+			}
+
+			var mu sync.Mutex
+			var history []int
+			pool := NewWorkerPool(tt.start, copyFn, 1, 0)
+			pool.EnableAdaptive(func(workers int, mbps float64) {
+				mu.Lock()
+				defer mu.Unlock()
+				history = append(history, workers)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 40*adaptiveInterval)
+			defer cancel()
+			taskCh := make(chan CopyTask)
+			go func() {
+				defer close(taskCh)
+				for {
+					select {
+					case taskCh <- CopyTask{Source: "a", Size: 1 << 20}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			if err := pool.ExecuteStream(ctx, taskCh); err != nil {
+				t.Fatalf("ExecuteStream: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(history) < 15 {
+				t.Fatalf("only %d adjustments in %v", len(history), 40*adaptiveInterval)
+			}
+			// Once settled the pool oscillates around the best count
+			settled := history[len(history)/2:]
+			sum := 0
+			for _, workers := range settled {
+				if workers < minConcurrency || workers > maxConcurrency {
+					t.Fatalf("worker count %d outside %d-%d", workers, minConcurrency, maxConcurrency)
+				}
+				sum += workers
+			}
+			if mean := float64(sum) / float64(len(settled)); mean < float64(tt.best)-1.5 || mean > float64(tt.best)+1.5 {
+				t.Errorf("settled around %.1f workers, want near %d (history %v)", mean, tt.best, history)
+			}
+		})
+	}
+}
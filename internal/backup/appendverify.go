@@ -0,0 +1,147 @@
+// appendverify.go
+package backup
+
+import (
+	"context"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+)
+
+// appendBoundaryBytes is how much of the already-verified part of a grown
+// file incremental verification re-reads, as a check that it is unchanged
+const appendBoundaryBytes = 64 * 1024
+
+// buildChecksumIndex records, for every file in the version history, its
+// newest entry with a checksum, so a copy can tell whether the file only
+// grew since and a skipped file's checksum can be looked up
+func (s *Service) buildChecksumIndex() (map[string]FileMetadata, error) {
+	index := make(map[string]FileMetadata)
+	versions := s.GetVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Archive != "" {
+			continue
+		}
+		version, err := s.GetVersion(versions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for key, metadata := range version.Files {
+			if _, ok := index[key]; !ok && metadata.Checksum != "" {
+				index[key] = metadata
+			}
+		}
+	}
+	return index, nil
+}
+
+// appendHasher splits a copied file at the size it had when last backed
+// up: the part before is hashed to confirm nothing in it changed, and the
+// part after is hashed on its own so verification can check just that
+type appendHasher struct {
+	from     int64 // Size of the previous copy
+	offset   int64 // Bytes seen so far
+	prefix   hash.Hash
+	boundary hash.Hash // Last appendBoundaryBytes before from
+	region   hash.Hash // Everything from from onward
+}
+
+// newAppendHasher returns a hasher for a copy of task when the file has
+// grown since its previous checksum was recorded, or nil when it hasn't
+func (s *Service) newAppendHasher(task CopyTask) (*appendHasher, FileMetadata) {
+	previous, ok := s.appendIndex[s.manifestKey(task.Source)]
+	if !ok || previous.Size <= 0 || previous.Size >= task.Size || task.ModTime.Before(previous.ModTime) {
+		return nil, previous
+	}
+	return &appendHasher{
+		from:     previous.Size,
+		prefix:   s.newHash(),
+		boundary: s.newHash(),
+		region:   s.newHash(),
+	}, previous
+}
+
+func (a *appendHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		switch {
+		case a.offset < a.from:
+			chunk := p[:min(int64(len(p)), a.from-a.offset)]
+			a.prefix.Write(chunk)
+			// The boundary is the tail of the prefix
+			boundaryStart := a.from - appendBoundaryBytes
+			if end := a.offset + int64(len(chunk)); end > boundaryStart {
+				skip := max(boundaryStart-a.offset, 0)
+				a.boundary.Write(chunk[skip:])
+			}
+			a.offset += int64(len(chunk))
+			p = p[len(chunk):]
+		default:
+			a.region.Write(p)
+			a.offset += int64(len(p))
+			p = nil
+		}
+	}
+	return n, nil
+}
+
+// record notes the appended region in metadata when the prefix is exactly
+// the previously recorded file, i.e. the file was only appended to
+func (a *appendHasher) record(metadata *FileMetadata, previous FileMetadata) {
+	if hex.EncodeToString(a.prefix.Sum(nil)) != previous.Checksum {
+		return
+	}
+	metadata.AppendFrom = a.from
+	metadata.AppendBoundary = hex.EncodeToString(a.boundary.Sum(nil))
+	metadata.AppendChecksum = hex.EncodeToString(a.region.Sum(nil))
+}
+
+// verifyAppended checks only the appended region of a backup copy recorded
+// as an append, plus the boundary just before it. checked is false when the
+// boundary no longer matches, meaning earlier bytes may have changed and
+// the whole copy needs verifying.
+func (s *Service) verifyAppended(ctx context.Context, destPath string, metadata FileMetadata) (match, checked bool, err error) {
+	s.files.acquire(1)
+	defer s.files.release(1)
+
+	file, err := os.Open(destPath)
+	if err != nil {
+		return false, false, err
+	}
+	defer file.Close()
+
+	start := max(metadata.AppendFrom-appendBoundaryBytes, 0)
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return false, false, err
+	}
+
+	boundary, region := s.newHash(), s.newHash()
+	buf := make([]byte, s.config.BufferSize)
+	for _, part := range []struct {
+		hash hash.Hash
+		size int64
+	}{
+		{boundary, metadata.AppendFrom - start},
+		{region, metadata.Size - metadata.AppendFrom},
+	} {
+		reader := io.LimitReader(file, part.size)
+		for {
+			if err := ctx.Err(); err != nil {
+				return false, false, err
+			}
+			n, err := reader.Read(buf)
+			part.hash.Write(buf[:n])
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return false, false, err
+			}
+		}
+	}
+
+	if hex.EncodeToString(boundary.Sum(nil)) != metadata.AppendBoundary {
+		return false, false, nil
+	}
+	return hex.EncodeToString(region.Sum(nil)) == metadata.AppendChecksum, true, nil
+}
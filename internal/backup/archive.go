@@ -0,0 +1,358 @@
+// archive.go
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target formats
+const (
+	TargetFiles = "files"  // A plain file tree mirroring the source (default)
+	TargetTar   = "tar"    // One .tar archive per version
+	TargetTarGz = "tar.gz" // One gzipped .tar.gz archive per version
+)
+
+// archiveMode reports whether backups are written to a tar archive
+func (s *Service) archiveMode() bool {
+	return s.config.TargetFormat == TargetTar || s.config.TargetFormat == TargetTarGz
+}
+
+// archiveName returns the archive file name for a version
+func (s *Service) archiveName(versionID string) string {
+	return versionID + "." + s.config.TargetFormat
+}
+
+// requireFileTree rejects operations that work on the target's file tree for
+// a version stored as an archive
+func requireFileTree(op string, version *BackupVersion) error {
+	if version.Archive == "" {
+		return nil
+	}
+	return newBackupError(op, version.ID, fmt.Errorf("version is stored in archive %s, not as a file tree; "+
+		"use --test-restore or --restore instead", version.Archive))
+}
+
+// countingWriter tracks the offset reached in the uncompressed tar stream
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// archiveWriter appends files to a version's tar archive. Workers share it,
+// so entries are written one at a time. The archive is built under a temp
+// name and renamed into place by close.
+type archiveWriter struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	gz        *gzip.Writer
+	counter   *countingWriter
+	tw        *tar.Writer
+	broken    error    // Set once a failed write has left the stream unusable
+	abandoned []string // Entries padded out after their source failed; not in the manifest
+	fsync     bool     // Flush the finished archive and its directory entry to disk
+}
+
+// newArchiveWriter creates the archive at path, gzipped at level when compress
+// is set (0 for gzip's default) and synced to disk on close when fsync is
+func newArchiveWriter(path string, compress bool, level int, fsync bool) (*archiveWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	file, err := os.Create(path + copyTempSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	a := &archiveWriter{path: path, file: file, fsync: fsync}
+	var w io.Writer = file
+	if compress {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gz, err := gzip.NewWriterLevel(file, level)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, fmt.Errorf("failed to create archive: %w", err)
+		}
+		a.gz = gz
+		w = a.gz
+	}
+	a.counter = &countingWriter{w: w}
+	a.tw = tar.NewWriter(a.counter)
+	return a, nil
+}
+
+// add writes src, a file described by info, as entry name, hashing its
+// content with hasher. It returns the offset of the entry's header in the
+// uncompressed stream and the number of bytes archived. A source that fails
+// to read or shrinks partway has its entry padded out to the size in its
+// header and recorded as abandoned, so the archive stays usable; only a
+// failed write to the archive itself ends it.
+func (a *archiveWriter) add(name string, src io.Reader, info os.FileInfo, hasher io.Writer, buf []byte) (int64, int64, error) {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to build archive header: %w", err)
+	}
+	header.Name = name
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.broken != nil {
+		return 0, 0, fmt.Errorf("archive unusable after an earlier failure: %w", a.broken)
+	}
+
+	// Nothing written can be taken back. Flushing pads out the previous
+	// entry first so the offset is where this header starts.
+	if err := a.tw.Flush(); err != nil {
+		a.broken = err
+		return 0, 0, fmt.Errorf("failed to write archive padding: %w", err)
+	}
+	offset := a.counter.n
+	if err := a.tw.WriteHeader(header); err != nil {
+		a.broken = err
+		return 0, 0, fmt.Errorf("failed to write archive header: %w", err)
+	}
+	copied, err := io.CopyBuffer(io.MultiWriter(a.tw, hasher), io.LimitReader(sourceReader{r: src}, header.Size), buf)
+	var readErr *sourceReadError
+	if err != nil && !errors.As(err, &readErr) {
+		a.broken = err
+		return 0, 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err == nil && copied < header.Size {
+		err = fmt.Errorf("source file shrank while archiving")
+	}
+	if err != nil {
+		if padErr := a.pad(header.Size - copied); padErr != nil {
+			a.broken = padErr
+			return 0, 0, fmt.Errorf("failed to write archive: %w", padErr)
+		}
+		a.abandoned = append(a.abandoned, name)
+		return 0, 0, fmt.Errorf("failed to archive file: %w", err)
+	}
+	return offset, copied, nil
+}
+
+// pad fills the rest of the current entry with n zero bytes
+func (a *archiveWriter) pad(n int64) error {
+	zeros := make([]byte, min(n, 32*1024))
+	for n > 0 {
+		written, err := a.tw.Write(zeros[:min(n, int64(len(zeros)))])
+		if err != nil {
+			return err
+		}
+		n -= int64(written)
+	}
+	return nil
+}
+
+// close finishes the archive and moves it into place
+func (a *archiveWriter) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.tw.Close()
+	if a.gz != nil {
+		if gzErr := a.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if a.fsync && err == nil {
+		err = a.file.Sync()
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && a.broken != nil {
+		err = a.broken
+	}
+	if err != nil {
+		os.Remove(a.file.Name())
+		return fmt.Errorf("failed to finish archive %s: %w", a.path, err)
+	}
+	if err := os.Rename(a.file.Name(), a.path); err != nil {
+		return err
+	}
+	if a.fsync {
+		return syncDir(filepath.Dir(a.path))
+	}
+	return nil
+}
+
+// archiveFile writes one task into the run's archive and records it
+func (s *Service) archiveFile(task CopyTask) error {
+	startTime := time.Now()
+	s.files.acquire(1)
+	defer s.files.release(1)
+
+	metadata := s.fileMetadata(task)
+	name := metadata.Path
+	if metadata.TargetKey != "" {
+		name = metadata.TargetKey
+	}
+
+	src, err := os.Open(task.Source)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	hasher := s.newHash()
+	offset, copied, err := s.archive.add(name, src, info, hasher, make([]byte, s.config.BufferSize))
+	if err != nil {
+		return err
+	}
+	s.metrics.IncrementCompleted(copied)
+	if info, err := os.Stat(task.Source); err == nil {
+		s.noteSourceChange(task, info)
+	}
+	s.logFileDone("Archived %s (%.2f MB)", task.Source, float64(copied)/1024/1024)
+
+	if s.versioner != nil {
+		metadata.Size = copied
+		metadata.ModTime = time.Now()
+		metadata.Checksum = hex.EncodeToString(hasher.Sum(nil))
+		metadata.Duration = time.Since(startTime)
+		metadata.ArchiveOffset = offset
+		s.recordFile(metadata)
+	}
+	return nil
+}
+
+// versionRoot returns the directory holding a version's files laid out as
+// on the target. Archived versions are extracted to a temporary directory,
+// which the returned function removes.
+func (s *Service) versionRoot(ctx context.Context, version *BackupVersion) (string, func(), error) {
+	if version.Archive == "" {
+		return s.versionTarget(version), func() {}, nil
+	}
+
+	scratch, err := os.MkdirTemp("", "backup-butler-archive-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(scratch) }
+
+	archivePath := filepath.Join(s.versionTarget(version), version.Archive)
+	if err := s.extractArchive(ctx, archivePath, version.Files, scratch); err != nil {
+		cleanup()
+		return "", nil, newBackupError("ExtractArchive", archivePath, err)
+	}
+	return scratch, cleanup, nil
+}
+
+// extractArchive writes the archive entries recorded in files under root.
+// Uncompressed archives are read entry by entry at their recorded offsets;
+// gzipped ones are read through once.
+func (s *Service) extractArchive(ctx context.Context, archivePath string, files map[string]FileMetadata, root string) error {
+	s.files.acquire(1)
+	defer s.files.release(1)
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	wanted := make(map[string]bool, len(files))
+	for key, metadata := range files {
+		if metadata.TargetKey != "" {
+			key = metadata.TargetKey
+		}
+		wanted[key] = true
+	}
+
+	if !strings.HasSuffix(archivePath, ".gz") {
+		for key, metadata := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if metadata.TargetKey != "" {
+				key = metadata.TargetKey
+			}
+			tr := tar.NewReader(io.NewSectionReader(file, metadata.ArchiveOffset, math.MaxInt64-metadata.ArchiveOffset))
+			header, err := tr.Next()
+			if err != nil {
+				return fmt.Errorf("failed to read entry %s: %w", key, err)
+			}
+			if header.Name != key {
+				return fmt.Errorf("entry at offset %d is %s, expected %s", metadata.ArchiveOffset, header.Name, key)
+			}
+			if err := extractEntry(tr, header, root); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if wanted[header.Name] {
+			if err := extractEntry(tr, header, root); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractEntry writes the current entry of tr below root with its mode and
+// modification time
+func extractEntry(tr *tar.Reader, header *tar.Header, root string) error {
+	path := filepath.Join(root, filepath.FromSlash(header.Name))
+	if rel, err := filepath.Rel(root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("archive entry %s escapes the extraction directory", header.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, tr); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, time.Now(), header.ModTime)
+}
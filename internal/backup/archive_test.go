@@ -0,0 +1,266 @@
+// archive_test.go
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader returns its data, then err
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readArchive returns the content of each entry in the archive at path
+func readArchive(t *testing.T, path string, compressed bool) map[string]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if compressed {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	entries := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		} else if err != nil {
+			t.Fatalf("reading archive: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("reading entry %s: %v", header.Name, err)
+		}
+		entries[header.Name] = string(data)
+	}
+}
+
+func TestArchiveWriterSurvivesFailedSources(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"ten": "0123456789"})
+	info, err := os.Stat(filepath.Join(dir, "ten"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readErr := errors.New("input/output error")
+	tests := []struct {
+		name    string
+		src     func() io.Reader
+		wantErr bool
+	}{
+		{"good/a", func() io.Reader { return strings.NewReader("0123456789") }, false},
+		{"failed/read", func() io.Reader { return &failingReader{data: "012", err: readErr} }, true},
+		{"failed/shrank", func() io.Reader { return strings.NewReader("0123") }, true},
+		{"good/b", func() io.Reader { return strings.NewReader("9876543210") }, false},
+	}
+
+	for _, compress := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "version.tar")
+		a, err := newArchiveWriter(path, compress, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			_, _, err := a.add(tt.name, tt.src(), info, io.Discard, make([]byte, 4))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("compress=%v: add %s error = %v, want error %v", compress, tt.name, err, tt.wantErr)
+			}
+		}
+		if err := a.close(); err != nil {
+			t.Fatalf("compress=%v: close: %v", compress, err)
+		}
+
+		entries := readArchive(t, path, compress)
+		for name, want := range map[string]string{"good/a": "0123456789", "good/b": "9876543210"} {
+			if got := entries[name]; got != want {
+				t.Errorf("compress=%v: entry %s = %q, want %q", compress, name, got, want)
+			}
+		}
+		if got, want := strings.Join(a.abandoned, ","), "failed/read,failed/shrank"; got != want {
+			t.Errorf("compress=%v: abandoned = %s, want %s", compress, got, want)
+		}
+	}
+}
+
+func TestArchiveWriterBreaksOnWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "version.tar")
+	a, err := newArchiveWriter(path, false, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(a.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.file.Close() // Every write to the stream now fails
+
+	if _, _, err := a.add("a", strings.NewReader(""), info, io.Discard, make([]byte, 4)); err == nil {
+		t.Fatal("add succeeded on a closed archive")
+	}
+	if a.broken == nil {
+		t.Error("a failed archive write did not end the archive")
+	}
+	if err := a.close(); err == nil {
+		t.Error("close succeeded for a broken archive")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("broken archive was moved into place")
+	}
+}
+
+func TestArchiveBackupRestore(t *testing.T) {
+	files := map[string]string{"a.txt": "alpha", "sub/b.txt": "bravo"}
+	for _, format := range []string{TargetTar, TargetTarGz} {
+		t.Run(format, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.TargetFormat = format
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+
+			dest := t.TempDir()
+			if err := s.Restore(context.Background(), result.VersionID, dest); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			for name, want := range files {
+				if got := readFile(t, filepath.Join(dest, testFolder, filepath.FromSlash(name))); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   int
+		wantErr bool
+	}{
+		{"default", TargetTarGz, 0, false},
+		{"fastest", TargetTarGz, 1, false},
+		{"smallest", TargetTarGz, 9, false},
+		{"below range", TargetTarGz, -1, true},
+		{"above range", TargetTarGz, 10, true},
+		{"uncompressed archive", TargetTar, 5, true},
+		{"file tree", TargetFiles, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"a.txt": strings.Repeat("alpha ", 100)}
+			cfg := newTestConfig(t, files)
+			cfg.TargetFormat = tt.format
+			cfg.CompressionLevel = tt.level
+			err := Validate(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil || tt.format != TargetTarGz {
+				return
+			}
+
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+			dest := t.TempDir()
+			if err := s.Restore(context.Background(), result.VersionID, dest); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			if got := readFile(t, filepath.Join(dest, testFolder, "a.txt")); got != files["a.txt"] {
+				t.Errorf("restored a.txt = %q, want %q", got, files["a.txt"])
+			}
+		})
+	}
+}
+
+// benchmarkArchiveData is a mix of compressible text and incompressible
+// bytes, as a backup of documents and media would be
+func benchmarkArchiveData(b *testing.B) []byte {
+	b.Helper()
+	var data bytes.Buffer
+	for i := 0; data.Len() < 4<<20; i++ {
+		fmt.Fprintf(&data, "%06d,2024-01-%02d,backup butler,%d bytes,completed\n", i, i%28+1, i*37)
+	}
+	noise := make([]byte, 2<<20)
+	if _, err := rand.New(rand.NewSource(1)).Read(noise); err != nil {
+		b.Fatal(err)
+	}
+	data.Write(noise)
+	return data.Bytes()
+}
+
+// BenchmarkArchiveCompression reports throughput and compression ratio of a
+// tar.gz archive at several compression_level settings
+func BenchmarkArchiveCompression(b *testing.B) {
+	data := benchmarkArchiveData(b)
+	info, err := os.Stat(b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	header := fileInfoWithSize{FileInfo: info, size: int64(len(data))}
+
+	for _, level := range []int{1, 6, 9} {
+		b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "version.tar.gz")
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				a, err := newArchiveWriter(path, true, level, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, _, err := a.add("data.bin", bytes.NewReader(data), header, io.Discard, make([]byte, 256<<10)); err != nil {
+					b.Fatal(err)
+				}
+				if err := a.close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			archived, err := os.Stat(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(len(data))/float64(archived.Size()), "ratio")
+		})
+	}
+}
+
+// fileInfoWithSize reports a regular file of the given size
+type fileInfoWithSize struct {
+	os.FileInfo
+	size int64
+}
+
+func (f fileInfoWithSize) Size() int64       { return f.size }
+func (f fileInfoWithSize) Mode() os.FileMode { return 0644 }
+func (f fileInfoWithSize) IsDir() bool       { return false }
@@ -0,0 +1,91 @@
+// audit.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AuditResult lists inconsistencies between a version manifest and the target
+type AuditResult struct {
+	VersionID string
+	Orphans   []string // Target files not recorded in the manifest
+	Missing   []string // Manifest keys with no file on the target
+}
+
+// AuditLatestVersion compares the latest version's manifest with the files
+// actually present on the target
+func (s *Service) AuditLatestVersion() (*AuditResult, error) {
+	version, err := s.GetLatestVersion()
+	if err != nil {
+		return nil, err
+	}
+	if err := requireFileTree("Audit", version); err != nil {
+		return nil, err
+	}
+
+	result := &AuditResult{VersionID: version.ID}
+	onDisk := make(map[string]bool)
+
+	// Map target-relative paths back to manifest keys, honoring renames
+	expected := make(map[string]string, len(version.Files))
+	for key, metadata := range version.Files {
+		if metadata.TargetKey != "" {
+			expected[metadata.TargetKey] = key
+		} else {
+			expected[key] = key
+		}
+	}
+
+	root := s.versionTarget(version)
+	folders, _ := s.expandFolders(root)
+	for _, folder := range folders {
+		dstPath := filepath.Join(root, folder)
+		err := filepath.Walk(dstPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == dstPath {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			targetKey := filepath.ToSlash(relPath)
+			// A metadata sidecar belongs to the file beside it
+			if base, ok := strings.CutSuffix(targetKey, sidecarSuffix); ok {
+				if _, ok := expected[base]; ok {
+					return nil
+				}
+			}
+			if key, ok := expected[targetKey]; ok {
+				onDisk[key] = true
+			} else {
+				result.Orphans = append(result.Orphans, targetKey)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, newBackupError("Audit", dstPath, err)
+		}
+	}
+
+	for key, metadata := range version.Files {
+		if !onDisk[key] {
+			if _, err := os.Stat(targetPathFor(root, key, metadata)); os.IsNotExist(err) {
+				result.Missing = append(result.Missing, key)
+			}
+		}
+	}
+
+	sort.Strings(result.Orphans)
+	sort.Strings(result.Missing)
+	return result, nil
+}
@@ -0,0 +1,60 @@
+// audit_test.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAuditLatestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(t *testing.T, cfg *Config)
+		orphans []string
+		missing []string
+	}{
+		{name: "consistent"},
+		{
+			name: "orphan from another run",
+			damage: func(t *testing.T, cfg *Config) {
+				writeFiles(t, filepath.Join(cfg.TargetDirectory, testFolder), map[string]string{"stray.txt": "x", "sub/stray.tmp": "y"})
+			},
+			orphans: []string{"data/stray.txt", "data/sub/stray.tmp"},
+		},
+		{
+			name: "manifest entry missing on disk",
+			damage: func(t *testing.T, cfg *Config) {
+				if err := os.Remove(targetPath(cfg, "sub/b.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			missing: []string{"data/sub/b.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha", "sub/b.txt": "bravo"})
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+			if tt.damage != nil {
+				tt.damage(t, cfg)
+			}
+
+			audit, err := s.AuditLatestVersion()
+			if err != nil {
+				t.Fatalf("AuditLatestVersion: %v", err)
+			}
+			if audit.VersionID != result.VersionID {
+				t.Errorf("audited %s, want %s", audit.VersionID, result.VersionID)
+			}
+			if !slices.Equal(audit.Orphans, tt.orphans) {
+				t.Errorf("orphans = %v, want %v", audit.Orphans, tt.orphans)
+			}
+			if !slices.Equal(audit.Missing, tt.missing) {
+				t.Errorf("missing = %v, want %v", audit.Missing, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// autosave.go
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// partialSuffix marks an autosaved, not yet completed version
+const partialSuffix = ".partial.json"
+
+func (vm *VersionManager) partialFilename(id string) string {
+	return filepath.Join(vm.baseDir, ".versions", id+partialSuffix)
+}
+
+// SaveProgress writes the in-progress version to .versions/<id>.partial.json.
+// Only the copy of the version is taken under the lock; marshalling and the
+// atomic write happen outside it so workers aren't stalled.
+func (vm *VersionManager) SaveProgress() error {
+	vm.mu.Lock()
+	if vm.currentVer == nil {
+		vm.mu.Unlock()
+		return nil
+	}
+	snapshot := *vm.currentVer
+	snapshot.Files = make(map[string]FileMetadata, len(vm.currentVer.Files))
+	for key, metadata := range vm.currentVer.Files {
+		snapshot.Files[key] = metadata
+	}
+	vm.mu.Unlock()
+
+	snapshot.Duration = time.Since(snapshot.Timestamp)
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal partial version: %w", err)
+	}
+	if err := writeFileAtomic(vm.partialFilename(snapshot.ID), data, 0644); err != nil {
+		return fmt.Errorf("failed to save partial version: %w", err)
+	}
+	return nil
+}
+
+// LoadPartialVersion returns the most recent autosaved version left behind by
+// an interrupted backup, or nil if there is none
+func (vm *VersionManager) LoadPartialVersion() (*BackupVersion, error) {
+	versionsDir := filepath.Join(vm.baseDir, ".versions")
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory: %w", err)
+	}
+
+	var partials []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), partialSuffix) {
+			partials = append(partials, entry.Name())
+		}
+	}
+	if len(partials) == 0 {
+		return nil, nil
+	}
+	sort.Strings(partials)
+
+	latest := partials[len(partials)-1]
+	data, err := os.ReadFile(filepath.Join(versionsDir, latest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partial version %s: %w", latest, err)
+	}
+
+	var version BackupVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("failed to parse partial version %s: %w", latest, err)
+	}
+	return &version, nil
+}
+
+// ResumeVersion continues an interrupted version under its original ID,
+// keeping only the given manifest entries from the earlier attempt
+func (vm *VersionManager) ResumeVersion(partial *BackupVersion, cfg *Config, keep map[string]FileMetadata) *BackupVersion {
+	version := vm.StartNewVersion(cfg)
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	version.ID = partial.ID
+	for key, metadata := range keep {
+		version.Files[key] = metadata
+		version.Size += metadata.Size
+	}
+	return version
+}
+
+// resumableFiles selects the files an interrupted run finished copying: they
+// have a recorded checksum and an intact-looking copy on the target
+func (s *Service) resumableFiles(partial *BackupVersion, tasks []CopyTask) (map[string]FileMetadata, []CopyTask) {
+	keep := make(map[string]FileMetadata)
+	var remaining []CopyTask
+
+	for _, task := range tasks {
+		key := s.manifestKey(task.Source)
+		metadata, ok := partial.Files[key]
+		if ok && metadata.Checksum != "" && metadata.Size == task.Size {
+			if info, err := os.Stat(task.Destination); err == nil && info.Size() == task.Size {
+				keep[key] = metadata
+				continue
+			}
+		}
+		remaining = append(remaining, task)
+	}
+
+	return keep, remaining
+}
+
+// startAutosave flushes the in-progress version every interval, and after
+// every everyFiles files recorded, until the returned stop function is
+// called. Either may be zero to leave that trigger off.
+func (s *Service) startAutosave(interval time.Duration, everyFiles int) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		tick = ticker.C
+	}
+	if everyFiles > 0 {
+		s.recorded.Store(0)
+		s.autosave = make(chan struct{}, 1)
+	}
+	kick := s.autosave
+
+	go func() {
+		defer close(stopped)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-tick:
+			case <-kick:
+			case <-stop:
+				return
+			}
+			if err := s.versioner.SaveProgress(); err != nil {
+				s.logger.Warn("Autosave failed: %v", err)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		s.autosave = nil
+	}
+}
+
+// recordFile adds a file to the version in progress and, every
+// autosave_every_files files, asks for the version to be flushed. Asking
+// never blocks the worker; a flush already pending covers it.
+func (s *Service) recordFile(metadata FileMetadata) {
+	s.versioner.AddFile(metadata.Path, metadata)
+
+	every := int64(s.config.AutosaveEveryFiles)
+	if s.autosave == nil || every <= 0 || s.recorded.Add(1)%every != 0 {
+		return
+	}
+	select {
+	case s.autosave <- struct{}{}:
+	default:
+	}
+}
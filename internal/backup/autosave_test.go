@@ -0,0 +1,89 @@
+// autosave_test.go
+package backup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// waitForPartial polls for the autosaved version until it holds want files
+// or timeout passes, returning how many files it last held (-1 if none)
+func waitForPartial(t *testing.T, s *Service, want int, timeout time.Duration) int {
+	t.Helper()
+	got := -1
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		partial, err := s.versioner.LoadPartialVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partial != nil {
+			got = len(partial.Files)
+			if got == want {
+				break
+			}
+		}
+	}
+	return got
+}
+
+func TestAutosave(t *testing.T) {
+	tests := []struct {
+		name       string
+		interval   time.Duration
+		everyFiles int
+		files      int
+		want       int // Files in the autosave, -1 for none
+	}{
+		{"every file", 0, 1, 3, 3},
+		{"every two files", 0, 2, 4, 4},
+		{"count not reached", 0, 5, 4, -1},
+		{"interval", 10 * time.Millisecond, 0, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.AutosaveEveryFiles = tt.everyFiles
+			s := newTestService(t, cfg)
+			s.versioner.StartNewVersion(cfg)
+
+			stop := s.startAutosave(tt.interval, tt.everyFiles)
+			defer stop()
+			for i := 0; i < tt.files; i++ {
+				key := fmt.Sprintf("data/%d.txt", i)
+				s.recordFile(FileMetadata{Path: key, Size: 1})
+			}
+
+			// A missing autosave is only given a short wait
+			timeout := 2 * time.Second
+			if tt.want < 0 {
+				timeout = 100 * time.Millisecond
+			}
+			if got := waitForPartial(t, s, tt.want, timeout); got != tt.want {
+				t.Errorf("autosave holds %d files, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutosaveBackupLeavesNoPartial(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha", "b.txt": "bravo", "c.txt": "charlie"})
+	cfg.AutosaveEveryFiles = 1
+	s := newTestService(t, cfg)
+	result := runBackup(t, s)
+
+	partial, err := s.versioner.LoadPartialVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial != nil {
+		t.Errorf("completed version %s left its autosave behind", partial.ID)
+	}
+	version, err := s.GetVersion(result.VersionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(version.Files) != 3 {
+		t.Errorf("version holds %d files, want 3", len(version.Files))
+	}
+}
@@ -0,0 +1,163 @@
+// benchmark.go
+package backup
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// BenchmarkResult is the throughput of one concurrency/buffer combination
+type BenchmarkResult struct {
+	Concurrency int
+	BufferSize  int
+	Files       int
+	Bytes       int64
+	Duration    time.Duration
+	MBps        float64
+}
+
+// benchmarkFileSet describes the synthetic data: many small files, some
+// medium ones and a few large ones, roughly like a mixed photo/document library
+var benchmarkFileSet = []struct {
+	count int
+	size  int64
+}{
+	{200, 16 * 1024},
+	{20, 1024 * 1024},
+	{2, 32 * 1024 * 1024},
+}
+
+// benchmarkBufferSizes are the buffer sizes swept alongside concurrency
+var benchmarkBufferSizes = []int{64 * 1024, 1024 * 1024}
+
+// Benchmark copies a generated set of synthetic files into a scratch
+// directory under the target with several concurrency and buffer size
+// combinations and reports the throughput of each. The synthetic source lives
+// in the system temp directory. All generated data is removed afterward.
+func (s *Service) Benchmark(ctx context.Context) ([]BenchmarkResult, error) {
+	source, err := os.MkdirTemp("", "backup-butler-benchmark-")
+	if err != nil {
+		return nil, newBackupError("Benchmark", "", err)
+	}
+	defer os.RemoveAll(source)
+
+	if err := os.MkdirAll(s.config.TargetDirectory, 0755); err != nil {
+		return nil, newBackupError("Benchmark", s.config.TargetDirectory, err)
+	}
+	scratch, err := os.MkdirTemp(s.config.TargetDirectory, ".benchmark-")
+	if err != nil {
+		return nil, newBackupError("Benchmark", s.config.TargetDirectory, err)
+	}
+	defer os.RemoveAll(scratch)
+
+	files, err := generateBenchmarkFiles(source)
+	if err != nil {
+		return nil, newBackupError("Benchmark", source, err)
+	}
+
+	var results []BenchmarkResult
+	for _, concurrency := range benchmarkConcurrencies(s.config.Concurrency) {
+		for _, bufferSize := range benchmarkBufferSizes {
+			if err := ctx.Err(); err != nil {
+				return results, err
+			}
+
+			result, err := s.benchmarkRun(ctx, files, filepath.Join(scratch, "run"), concurrency, bufferSize)
+			if err != nil {
+				return results, err
+			}
+			s.logger.Info("Benchmark: concurrency %d, buffer %d KB: %.2f MB/s",
+				concurrency, bufferSize/1024, result.MBps)
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// benchmarkRun copies files into dest with one setting and removes the copies
+func (s *Service) benchmarkRun(ctx context.Context, files []CopyTask, dest string, concurrency, bufferSize int) (BenchmarkResult, error) {
+	defer os.RemoveAll(dest)
+
+	cfg := *s.config
+	cfg.Concurrency = concurrency
+	cfg.BufferSize = bufferSize
+	run := &Service{
+		config:  &cfg,
+		logger:  s.logger,
+		files:   s.files,
+		metrics: NewBackupMetrics(len(files), true),
+	}
+	run.metrics.StartTracking(ctx)
+	defer run.metrics.Stop()
+
+	tasks := make([]CopyTask, len(files))
+	var total int64
+	for i, task := range files {
+		task.Destination = filepath.Join(dest, filepath.Base(task.Source))
+		tasks[i] = task
+		total += task.Size
+	}
+
+	start := time.Now()
+	pool := NewWorkerPool(concurrency, run.performCopy, 1, 0)
+	if err := pool.Execute(ctx, tasks); err != nil {
+		return BenchmarkResult{}, newBackupError("Benchmark", dest, err)
+	}
+	duration := time.Since(start)
+
+	result := BenchmarkResult{
+		Concurrency: concurrency,
+		BufferSize:  bufferSize,
+		Files:       len(tasks),
+		Bytes:       total,
+		Duration:    duration,
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		result.MBps = float64(total) / 1024 / 1024 / seconds
+	}
+	return result, nil
+}
+
+// benchmarkConcurrencies returns the worker counts to try: powers of two up
+// to 8 plus the configured value
+func benchmarkConcurrencies(configured int) []int {
+	seen := make(map[int]bool)
+	var counts []int
+	for _, n := range []int{1, 2, 4, 8, configured} {
+		if n >= minConcurrency && n <= maxConcurrency && !seen[n] {
+			seen[n] = true
+			counts = append(counts, n)
+		}
+	}
+	sort.Ints(counts)
+	return counts
+}
+
+// generateBenchmarkFiles writes the synthetic file set into dir. The content
+// is pseudo-random so compressing filesystems can't flatter the results.
+func generateBenchmarkFiles(dir string) ([]CopyTask, error) {
+	rng := rand.New(rand.NewSource(1))
+	var tasks []CopyTask
+	for _, set := range benchmarkFileSet {
+		data := make([]byte, set.size)
+		for i := 0; i < set.count; i++ {
+			rng.Read(data)
+			path := filepath.Join(dir, fmt.Sprintf("file-%d-%d.bin", set.size, i))
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return nil, err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, CopyTask{Source: path, Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,98 @@
+// benchmark_test.go
+package backup
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestBenchmarkConcurrencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured int
+		want       []int
+	}{
+		{"configured is a power of two", 4, []int{1, 2, 4, 8}},
+		{"configured in between", 6, []int{1, 2, 4, 6, 8}},
+		{"configured above 8", 16, []int{1, 2, 4, 8, 16}},
+		{"configured above the cap", maxConcurrency + 1, []int{1, 2, 4, 8}},
+		{"not configured", 0, []int{1, 2, 4, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := benchmarkConcurrencies(tt.configured); !slices.Equal(got, tt.want) {
+				t.Errorf("benchmarkConcurrencies(%d) = %v, want %v", tt.configured, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBenchmarkCleansUp(t *testing.T) {
+	// A small file set keeps the sweep quick
+	fileSet, bufferSizes := benchmarkFileSet, benchmarkBufferSizes
+	benchmarkFileSet = []struct {
+		count int
+		size  int64
+	}{{4, 1024}, {1, 64 * 1024}}
+	benchmarkBufferSizes = []int{4 * 1024}
+	t.Cleanup(func() { benchmarkFileSet, benchmarkBufferSizes = fileSet, bufferSizes })
+
+	tests := []struct {
+		name        string
+		cancel      bool
+		wantResults int
+	}{
+		{"completed", false, len(benchmarkConcurrencies(2))},
+		{"cancelled", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			s := newTestService(t, cfg)
+			scratch := t.TempDir()
+			t.Setenv("TMPDIR", scratch)
+			before := dirNames(t, cfg.TargetDirectory)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			results, err := s.Benchmark(ctx)
+			if (err != nil) != tt.cancel {
+				t.Fatalf("Benchmark error = %v, want error %v", err, tt.cancel)
+			}
+			if len(results) != tt.wantResults {
+				t.Errorf("got %d results, want %d", len(results), tt.wantResults)
+			}
+			for _, result := range results {
+				if result.Files != 5 || result.Bytes != 4*1024+64*1024 {
+					t.Errorf("result = %+v, want 5 files of %d bytes", result, 4*1024+64*1024)
+				}
+			}
+
+			if left := dirNames(t, scratch); len(left) != 0 {
+				t.Errorf("temp directory holds %v after the benchmark, want nothing", left)
+			}
+			if after := dirNames(t, cfg.TargetDirectory); !slices.Equal(after, before) {
+				t.Errorf("target holds %v after the benchmark, want %v", after, before)
+			}
+		})
+	}
+}
+
+// dirNames returns the sorted names of the entries in dir
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
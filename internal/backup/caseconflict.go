@@ -0,0 +1,80 @@
+// caseconflict.go
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Case conflict policies for targets on case-insensitive filesystems
+const (
+	CaseConflictIgnore = "ignore"
+	CaseConflictFail   = "fail"
+	CaseConflictRename = "rename"
+)
+
+// resolveCaseConflicts finds tasks whose destinations differ only by case and
+// applies the configured policy: "fail" returns a report of every conflict,
+// "rename" gives all but the first destination in each group a unique name
+func (s *Service) resolveCaseConflicts(tasks []CopyTask) ([]CopyTask, error) {
+	groups := make(map[string][]int)
+	for i, task := range tasks {
+		lower := strings.ToLower(task.Destination)
+		groups[lower] = append(groups[lower], i)
+	}
+
+	var conflicts []string
+	taken := make(map[string]bool, len(groups))
+	for lower := range groups {
+		taken[lower] = true
+	}
+
+	for _, indices := range groups {
+		if len(indices) < 2 {
+			continue
+		}
+		sort.Slice(indices, func(a, b int) bool {
+			return tasks[indices[a]].Destination < tasks[indices[b]].Destination
+		})
+
+		var names []string
+		for _, i := range indices {
+			names = append(names, tasks[i].Source)
+		}
+		conflicts = append(conflicts, strings.Join(names, " <-> "))
+
+		if s.config.CaseConflictPolicy != CaseConflictRename {
+			continue
+		}
+		for n, i := range indices[1:] {
+			renamed := caseConflictName(tasks[i].Destination, n+2, taken)
+			s.logger.Warn("Case conflict: copying %s to %s", tasks[i].Source, renamed)
+			tasks[i].Destination = renamed
+		}
+	}
+
+	if len(conflicts) > 0 && s.config.CaseConflictPolicy == CaseConflictFail {
+		sort.Strings(conflicts)
+		return nil, withSentinel(fmt.Errorf("%d case conflicts on a case-insensitive target:\n  %s",
+			len(conflicts), strings.Join(conflicts, "\n  ")), ErrCaseConflict)
+	}
+
+	return tasks, nil
+}
+
+// caseConflictName returns "name (case N).ext", bumping N until the result
+// doesn't collide case-insensitively with any other destination
+func caseConflictName(path string, n int, taken map[string]bool) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for {
+		candidate := fmt.Sprintf("%s (case %d)%s", base, n, ext)
+		if lower := strings.ToLower(candidate); !taken[lower] {
+			taken[lower] = true
+			return candidate
+		}
+		n++
+	}
+}
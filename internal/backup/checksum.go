@@ -2,24 +2,121 @@
 package backup
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
 	"os"
+	"sort"
 )
 
-// calculateChecksum computes SHA-256 hash of file
+// defaultChecksumAlgorithm is used when none is configured, and is what every
+// manifest written before checksum_algorithm took effect was hashed with
+const defaultChecksumAlgorithm = "sha256"
+
+// checksumAlgorithms maps each supported checksum_algorithm to its hash
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// supportedChecksumAlgorithms returns the algorithm names in sorted order
+func supportedChecksumAlgorithms() []string {
+	names := make([]string, 0, len(checksumAlgorithms))
+	for name := range checksumAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newHasher returns a hash for algorithm, treating "" as the default
+func newHasher(algorithm string) (hash.Hash, error) {
+	if algorithm == "" {
+		algorithm = defaultChecksumAlgorithm
+	}
+	newFn, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	return newFn(), nil
+}
+
+// newHash returns a hash for the configured algorithm, which validation has
+// already checked
+func (s *Service) newHash() hash.Hash {
+	hasher, err := newHasher(s.config.ChecksumAlgorithm)
+	if err != nil {
+		return sha256.New()
+	}
+	return hasher
+}
+
+// Checksum returns the hash of a file with the configured checksum_algorithm,
+// as recorded in version manifests
+func (s *Service) Checksum(path string) (string, error) {
+	checksum, err := s.calculateChecksum(path)
+	if err != nil {
+		return "", newBackupError("Checksum", path, err)
+	}
+	return checksum, nil
+}
+
+// ChecksumAlgorithm returns the configured checksum algorithm
+func (s *Service) ChecksumAlgorithm() string {
+	return s.configuredAlgorithm()
+}
+
+// calculateChecksum computes the hash of a file with the configured
+// algorithm, giving up if the current backup is cancelled
 func (s *Service) calculateChecksum(filePath string) (string, error) {
+	return s.calculateChecksumContext(s.runContext(), filePath)
+}
+
+// calculateChecksumContext computes the hash of a file with the configured
+// algorithm, checking ctx between chunks so hashing a huge file stops
+// promptly on Ctrl-C or a deadline
+func (s *Service) calculateChecksumContext(ctx context.Context, filePath string) (string, error) {
+	s.files.acquire(1)
+	defer s.files.release(1)
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
+	defer s.diag.addHash(s.diag.now())
+	hash := s.newHash()
+	buf := make([]byte, s.config.BufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := file.Read(buf)
+		hash.Write(buf[:n])
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
 	}
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
+
+// runContext returns the context of the backup in progress, so work started
+// from a worker can notice cancellation; outside a backup nothing cancels it
+func (s *Service) runContext() context.Context {
+	if s.runCtx != nil {
+		return s.runCtx
+	}
+	return context.Background()
+}
@@ -0,0 +1,216 @@
+// checksummarker.go
+package backup
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// targetMarkerFile records settings every version in .versions must share
+const targetMarkerFile = "target.json"
+
+// targetMarker is the content of targetMarkerFile
+type targetMarker struct {
+	ChecksumAlgorithm string `json:"checksum_algorithm"`
+}
+
+// ChecksumAlgorithm returns the algorithm the stored manifests are hashed
+// with: the one in target.json, else sha256 when versions exist from before
+// the marker, else "" for an empty target
+func (vm *VersionManager) ChecksumAlgorithm() (string, error) {
+	data, err := os.ReadFile(filepath.Join(vm.baseDir, ".versions", targetMarkerFile))
+	if err == nil {
+		var marker targetMarker
+		if err := json.Unmarshal(data, &marker); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", targetMarkerFile, err)
+		}
+		return marker.ChecksumAlgorithm, nil
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %w", targetMarkerFile, err)
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if len(vm.versions) > 0 {
+		return defaultChecksumAlgorithm, nil
+	}
+	return "", nil
+}
+
+// SetChecksumAlgorithm records algorithm as the one the stored manifests use
+func (vm *VersionManager) SetChecksumAlgorithm(algorithm string) error {
+	data, err := json.MarshalIndent(targetMarker{ChecksumAlgorithm: algorithm}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(vm.baseDir, ".versions", targetMarkerFile), data, 0644)
+}
+
+// configuredAlgorithm returns checksum_algorithm with the default applied
+func (s *Service) configuredAlgorithm() string {
+	if s.config.ChecksumAlgorithm == "" {
+		return defaultChecksumAlgorithm
+	}
+	return s.config.ChecksumAlgorithm
+}
+
+// checkChecksumAlgorithm refuses to mix checksum algorithms in one target,
+// since manifests hashed differently can't be compared
+func (s *Service) checkChecksumAlgorithm() error {
+	stored, err := s.versioner.ChecksumAlgorithm()
+	if err != nil {
+		return newBackupError("ChecksumAlgorithm", "", err)
+	}
+	configured := s.configuredAlgorithm()
+	if stored != "" && stored != configured {
+		return newBackupError("ChecksumAlgorithm", "", withSentinel(
+			fmt.Errorf("existing versions use %s but checksum_algorithm is %s; "+
+				"run --migrate-checksums to convert them", stored, configured),
+			ErrAlgorithmMismatch))
+	}
+	return nil
+}
+
+// MigrateResult describes a checksum migration
+type MigrateResult struct {
+	From, To  string
+	Versions  int      // Manifests rewritten
+	Converted int      // Checksums recomputed with the new algorithm
+	Dropped   []string // Backup copies that no longer matched their old checksum
+}
+
+// MigrateChecksums rewrites every version manifest to the configured
+// checksum_algorithm. Each backup copy is hashed with both algorithms in one
+// read; a copy that still matches its old checksum gets the new one, and a
+// copy that doesn't (changed or missing since that version) loses its
+// checksum, leaving verify to compare it by size.
+func (s *Service) MigrateChecksums(ctx context.Context) (*MigrateResult, error) {
+	from, err := s.versioner.ChecksumAlgorithm()
+	if err != nil {
+		return nil, newBackupError("MigrateChecksums", "", err)
+	}
+	to := s.configuredAlgorithm()
+	result := &MigrateResult{From: from, To: to}
+	if from == "" || from == to {
+		if err := s.versioner.SetChecksumAlgorithm(to); err != nil {
+			return nil, newBackupError("MigrateChecksums", "", err)
+		}
+		return result, nil
+	}
+
+	versions := s.GetVersions()
+	loaded := make([]*BackupVersion, 0, len(versions))
+	roots := make(map[string]string, len(versions))
+	paths := make(map[string]bool)
+	for _, v := range versions {
+		version, err := s.GetVersion(v.ID)
+		if err != nil {
+			return result, newBackupError("MigrateChecksums", v.ID, err)
+		}
+		loaded = append(loaded, version)
+		root, cleanup, err := s.versionRoot(ctx, version)
+		if err != nil {
+			return result, err
+		}
+		defer cleanup()
+		roots[version.ID] = root
+		for key, metadata := range version.Files {
+			if metadata.Checksum != "" {
+				paths[targetPathFor(root, key, metadata)] = true
+			}
+		}
+	}
+
+	// Hash each backup copy once, however many versions record it
+	type checksums struct{ from, to string }
+	var mu sync.Mutex
+	hashed := make(map[string]checksums, len(paths))
+	hashFn := func(task CopyTask) error {
+		fromSum, toSum, err := s.dualChecksum(task.Source, from, to)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		mu.Lock()
+		hashed[task.Source] = checksums{fromSum, toSum}
+		mu.Unlock()
+		return nil
+	}
+	tasks := make([]CopyTask, 0, len(paths))
+	for path := range paths {
+		tasks = append(tasks, CopyTask{Source: path})
+	}
+	pool := NewWorkerPool(s.config.Concurrency, hashFn, s.config.RetryAttempts, s.config.RetryDelay)
+	if err := pool.Execute(ctx, tasks); err != nil {
+		return result, newBackupError("MigrateChecksums", "", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	dropped := make(map[string]bool)
+	for _, version := range loaded {
+		root := roots[version.ID]
+		files := make(map[string]FileMetadata, len(version.Files))
+		for key, metadata := range version.Files {
+			if metadata.Checksum != "" {
+				path := targetPathFor(root, key, metadata)
+				// Append hashes use the old algorithm; verify whole files until recopied
+				metadata.AppendFrom, metadata.AppendChecksum, metadata.AppendBoundary = 0, "", ""
+				if sums, ok := hashed[path]; ok && sums.from == metadata.Checksum {
+					metadata.Checksum = sums.to
+					result.Converted++
+				} else {
+					metadata.Checksum = ""
+					if !dropped[path] {
+						dropped[path] = true
+						result.Dropped = append(result.Dropped, path)
+					}
+				}
+			}
+			files[key] = metadata
+		}
+		if err := s.versioner.ReplaceFiles(version.ID, files, to); err != nil {
+			return result, newBackupError("MigrateChecksums", version.ID, err)
+		}
+		result.Versions++
+	}
+
+	if err := s.versioner.SetChecksumAlgorithm(to); err != nil {
+		return result, newBackupError("MigrateChecksums", "", err)
+	}
+	return result, nil
+}
+
+// dualChecksum hashes a file with two algorithms in a single read
+func (s *Service) dualChecksum(path, oldAlgorithm, newAlgorithm string) (string, string, error) {
+	oldHash, err := newHasher(oldAlgorithm)
+	if err != nil {
+		return "", "", err
+	}
+	newHash, err := newHasher(newAlgorithm)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.files.acquire(1)
+	defer s.files.release(1)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(io.MultiWriter(oldHash, newHash), file); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(oldHash.Sum(nil)), hex.EncodeToString(newHash.Sum(nil)), nil
+}
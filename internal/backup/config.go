@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -14,49 +17,300 @@ type Options struct {
 	Verbose  bool
 	Quiet    bool
 	LogLevel string
+	Resume   bool // Continue the most recent interrupted version
+	Itemize  bool // Print an rsync-style change line per file instead of the progress bar
+	// Skip every file that already exists at the destination, copying only new ones
+	IgnoreExisting bool
+	Force          bool // Allow mirror deletions beyond max_delete_percent
+	Diagnostics    bool // Report worker utilization and phase timings after a backup
+	Explain        bool // Log why each file is copied or skipped
+	SinceLast      bool // Skip files unmodified since the last completed backup without checking the target
 }
 
 type Config struct {
-	SourceDirectory    string        `json:"source_directory" yaml:"source_directory"`
-	FoldersToBackup    []string      `json:"folders_to_backup" yaml:"folders_to_backup"`
-	TargetDirectory    string        `json:"target_directory" yaml:"target_directory"`
-	DeepDuplicateCheck bool          `json:"deep_duplicate_check" yaml:"deep_duplicate_check"`
-	Concurrency        int           `json:"concurrency" yaml:"concurrency"`
-	BufferSize         int           `json:"buffer_size" yaml:"buffer_size"`
-	RetryAttempts      int           `json:"retry_attempts" yaml:"retry_attempts"`
-	RetryDelay         time.Duration `json:"retry_delay" yaml:"retry_delay"`
-	ExcludePatterns    []string      `json:"exclude_patterns" yaml:"exclude_patterns"`
-	ChecksumAlgorithm  string        `json:"checksum_algorithm" yaml:"checksum_algorithm"`
-	Options            *Options
+	SourceDirectory        string        `json:"source_directory" yaml:"source_directory"`
+	FoldersToBackup        []string      `json:"folders_to_backup" yaml:"folders_to_backup"` // Relative folders below source_directory, never absolute or ".."; entries may be glob patterns such as "Photos/20*"
+	VersionDirectory       string        `json:"version_directory" yaml:"version_directory"` // Where version history and logs live (default: target, or its fixed part when templated)
+	TargetDirectory        string        `json:"target_directory" yaml:"target_directory"`
+	TargetFormat           string        `json:"target_format" yaml:"target_format"`           // "files" (default), or "tar" / "tar.gz" for one archive per version
+	CompressionLevel       int           `json:"compression_level" yaml:"compression_level"`   // gzip level for tar.gz archives, 1 (fastest) to 9 (smallest); 0 uses gzip's default
+	AdditionalTargets      []string      `json:"additional_targets" yaml:"additional_targets"` // Also copy every file here in the same pass; versions, logs and mirror deletions stay with target_directory
+	DeepDuplicateCheck     bool          `json:"deep_duplicate_check" yaml:"deep_duplicate_check"`
+	VerifySampleRate       float64       `json:"verify_sample_rate" yaml:"verify_sample_rate"`   // Fraction of size-matched files to fully checksum when deep_duplicate_check is off
+	VerifySampleSeed       int64         `json:"verify_sample_seed" yaml:"verify_sample_seed"`   // Seed for reproducible sampling (0 = random)
+	DetectMoves            bool          `json:"detect_moves" yaml:"detect_moves"`               // Hard-link files renamed in the source to their existing copy instead of copying again
+	SnapshotMode           bool          `json:"snapshot_mode" yaml:"snapshot_mode"`             // Back up each run to target/<timestamp>/, hard-linking files unchanged since the previous snapshot
+	MetadataSidecar        bool          `json:"metadata_sidecar" yaml:"metadata_sidecar"`       // Record each file's mode, owner, mtime and xattrs in a .fsmeta file beside its copy, reapplied on restore
+	RootHash               bool          `json:"root_hash" yaml:"root_hash"`                     // Record a Merkle root over every file's checksum in each completed version; equal roots mean identical contents
+	IncrementalVerify      bool          `json:"incremental_verify" yaml:"incremental_verify"`   // For files that only grew since their last checksum, verify just the appended bytes
+	UpdateMode             bool          `json:"update_mode" yaml:"update_mode"`                 // Never overwrite a target file newer than its source (like rsync --update); checked before deep_duplicate_check
+	QuickCompareBytes      int           `json:"quick_compare_bytes" yaml:"quick_compare_bytes"` // Without deep_duplicate_check, compare the first and last N bytes of same-size files larger than 2N
+	Concurrency            int           `json:"concurrency" yaml:"concurrency"`
+	ChecksumConcurrency    int           `json:"checksum_concurrency" yaml:"checksum_concurrency"`     // Workers deciding which files to skip, hashing under deep_duplicate_check (0 = concurrency)
+	AdaptiveConcurrency    bool          `json:"adaptive_concurrency" yaml:"adaptive_concurrency"`     // Experimental: tune the copy worker count to the best measured throughput, starting from concurrency
+	AllowHighConcurrency   bool          `json:"allow_high_concurrency" yaml:"allow_high_concurrency"` // Permit concurrency above 2x CPU cores without warning
+	BufferSize             int           `json:"buffer_size" yaml:"buffer_size"`
+	MaxOpenFiles           int           `json:"max_open_files" yaml:"max_open_files"` // Cap on simultaneously open file handles (0 = unlimited)
+	RetryAttempts          int           `json:"retry_attempts" yaml:"retry_attempts"`
+	RetryDelay             time.Duration `json:"retry_delay" yaml:"retry_delay"`
+	PerFileTimeout         time.Duration `json:"per_file_timeout" yaml:"per_file_timeout"`       // Give up on a copy attempt whose reads or writes stall past this, then retry (0 = no limit)
+	SlowFileThreshold      time.Duration `json:"slow_file_threshold" yaml:"slow_file_threshold"` // Warn about and list in the summary any file whose copy takes longer than this (0 = off)
+	WorkerRampUp           time.Duration `json:"worker_ramp_up" yaml:"worker_ramp_up"`           // Start workers one at a time this far apart, e.g. "500ms" for disks that spin down (0 = all at once)
+	LogEveryFile           bool          `json:"log_every_file" yaml:"log_every_file"`           // Log a line for every file copied; off by default, keeping warnings, errors and the summary
+	LogSampleEvery         int           `json:"log_sample_every" yaml:"log_sample_every"`       // Without log_every_file, still log every Nth file copied (0 = none)
+	FailFast               bool          `json:"fail_fast" yaml:"fail_fast"`                     // Stop the whole backup at the first file that fails, saving it as Partial
+	Fsync                  bool          `json:"fsync" yaml:"fsync"`                             // Flush each copy and its directory entry to disk before moving on; survives power loss, but can cut throughput several-fold for many small files
+	ExcludePatterns        []string      `json:"exclude_patterns" yaml:"exclude_patterns"`       // Name globs, or source-relative path globs when they contain "/"; "!" re-includes, last match wins
+	ExcludePaths           []string      `json:"exclude_paths" yaml:"exclude_paths"`             // Subtrees to prune, relative to the source directory (e.g. "Photos/2019/raw")
+	ImmutablePaths         []string      `json:"immutable_paths" yaml:"immutable_paths"`         // Globs like exclude_patterns for files that never change once backed up; skipped on the manifest's word
+	PatternSyntax          string        `json:"pattern_syntax" yaml:"pattern_syntax"`           // "glob" (default) or "regex" for exclude_patterns and immutable_paths; regexes match the source-relative path
+	ExcludeCaseInsensitive bool          `json:"exclude_case_insensitive" yaml:"exclude_case_insensitive"`
+	CaseConflictPolicy     string        `json:"case_conflict_policy" yaml:"case_conflict_policy"` // "ignore" (default), "fail" or "rename" for names differing only by case
+	ZeroByteFiles          string        `json:"zero_byte_files" yaml:"zero_byte_files"`           // "include" (default), "skip" or "warn"
+	OneFileSystem          bool          `json:"one_file_system" yaml:"one_file_system"`           // Don't descend into other filesystems mounted inside a folder
+	SkipHidden             bool          `json:"skip_hidden" yaml:"skip_hidden"`                   // Skip dotfiles and hidden directories
+	ChecksumAlgorithm      string        `json:"checksum_algorithm" yaml:"checksum_algorithm"`
+	QuarantineCorrupt      bool          `json:"quarantine_corrupt" yaml:"quarantine_corrupt"`     // Move corrupt backup copies to .quarantine instead of overwriting them
+	MinExpectedFiles       int           `json:"min_expected_files" yaml:"min_expected_files"`     // Abort if the source has fewer files, e.g. when a mount is missing
+	SafeMode               bool          `json:"safe_mode" yaml:"safe_mode"`                       // Purely additive run: no deletions, changed files go to a .new sidecar
+	MirrorMode             bool          `json:"mirror_mode" yaml:"mirror_mode"`                   // Delete target files no longer in the source
+	MaxDeletePercent       float64       `json:"max_delete_percent" yaml:"max_delete_percent"`     // Refuse mirror deletions above this share of target files without --force (0 = no limit)
+	DeleteToTrash          bool          `json:"delete_to_trash" yaml:"delete_to_trash"`           // Move mirror deletions to .trash instead of removing them
+	TrashRetentionDays     int           `json:"trash_retention_days" yaml:"trash_retention_days"` // Empty trashed runs older than this after each backup
+	CompressVersions       bool          `json:"compress_versions" yaml:"compress_versions"`       // Write version manifests as gzipped .json.gz
+	LatestLink             bool          `json:"latest_link" yaml:"latest_link"`                   // Keep .versions/latest.json pointing at the newest completed manifest
+	LogRetentionCount      int           `json:"log_retention_count" yaml:"log_retention_count"`   // Keep at most this many run logs (0 = all)
+	LogRetentionDays       int           `json:"log_retention_days" yaml:"log_retention_days"`     // Remove run logs older than this (0 = never)
+	RetentionMaxBytes      int64         `json:"retention_max_bytes" yaml:"retention_max_bytes"`   // Prune oldest versions beyond this cumulative size
+	MinFreeSpace           int64         `json:"min_free_space" yaml:"min_free_space"`             // Bytes to keep free on the target
+	PreBackupCommands      []string      `json:"pre_backup_commands" yaml:"pre_backup_commands"`   // Shell commands run before copying; a failure aborts the backup
+	PostBackupCommands     []string      `json:"post_backup_commands" yaml:"post_backup_commands"` // Shell commands run after copying, even on failure
+	HookTimeout            time.Duration `json:"hook_timeout" yaml:"hook_timeout"`                 // Per-command timeout (default 10m)
+	UseSnapshot            bool          `json:"use_snapshot" yaml:"use_snapshot"`                 // Back up from a read-only btrfs/zfs snapshot when supported
+	AutosaveInterval       time.Duration `json:"autosave_interval" yaml:"autosave_interval"`       // Flush the in-progress version this often (0 = off)
+	AutosaveEveryFiles     int           `json:"autosave_every_files" yaml:"autosave_every_files"` // Also flush it after every N files recorded (0 = off)
+	CheckReadable          bool          `json:"check_readable" yaml:"check_readable"`             // Scan source files for readability before copying
+	OnReadError            string        `json:"on_read_error" yaml:"on_read_error"`               // "skip" (default), "fail" or "keep-partial" when a source fails mid-copy
+	UnreadablePolicy       string        `json:"unreadable_policy" yaml:"unreadable_policy"`       // "skip" (default) or "fail"
+	OverwritePolicy        string        `json:"overwrite_policy" yaml:"overwrite_policy"`         // Restore: "never" (default), "always", "if-newer" or "if-different"
+	SummaryTemplate        string        `json:"summary_template" yaml:"summary_template"`         // text/template for the final summary, rendered against RunSummary
+	Options                *Options
+
+	// Keys present in the file this config was decoded from, so Merge can
+	// tell a setting turned off from one left unset
+	present map[string]bool
 }
 
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, newBackupError("ReadConfig", path, err)
-	}
+// Restore overwrite policies for destination files that already exist
+const (
+	OverwriteAlways      = "always"
+	OverwriteNever       = "never"
+	OverwriteIfNewer     = "if-newer"
+	OverwriteIfDifferent = "if-different"
+)
+
+// Zero-byte source file handling
+const (
+	ZeroByteInclude = "include"
+	ZeroByteSkip    = "skip"
+	ZeroByteWarn    = "warn"
+)
 
-	config := &Config{
+// Unreadable source file policies
+const (
+	UnreadableSkip = "skip"
+	UnreadableFail = "fail"
+)
+
+// NewConfig returns a Config populated with the defaults LoadConfig applies,
+// for building a configuration in code
+func NewConfig() *Config {
+	return &Config{
 		Concurrency:       4,
 		BufferSize:        32 * 1024,
 		RetryAttempts:     3,
 		RetryDelay:        time.Second,
 		ChecksumAlgorithm: "sha256",
+		ZeroByteFiles:     ZeroByteInclude,
+		OverwritePolicy:   OverwriteNever,
+		UnreadablePolicy:  UnreadableSkip,
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	config := NewConfig()
+
+	if err := parseConfigFile(path, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadConfigs loads the first file with defaults applied, then merges each
+// following file over it in order
+func LoadConfigs(paths ...string) (*Config, error) {
+	if len(paths) == 0 {
+		return nil, newBackupError("LoadConfig", "", fmt.Errorf("no configuration files given"))
+	}
+
+	config, err := LoadConfig(paths[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths[1:] {
+		layer := &Config{}
+		if err := parseConfigFile(path, layer); err != nil {
+			return nil, err
+		}
+		config.Merge(layer)
+	}
+
+	return config, nil
+}
+
+// parseConfigFile decodes a JSON or YAML file into config and expands its paths
+func parseConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return newBackupError("ReadConfig", path, err)
 	}
 
+	// The top-level keys are decoded too, to record which settings the
+	// file contains
+	var keys map[string]any
 	ext := filepath.Ext(path)
 	switch ext {
 	case ".json":
-		err = json.Unmarshal(data, config)
+		if err = json.Unmarshal(data, config); err == nil {
+			err = json.Unmarshal(data, &keys)
+		}
 	case ".yaml", ".yml":
-		err = yaml.Unmarshal(data, config)
+		if err = yaml.Unmarshal(data, config); err == nil {
+			err = yaml.Unmarshal(data, &keys)
+		}
 	default:
-		return nil, newBackupError("LoadConfig", path, fmt.Errorf("unsupported format: %s", ext))
+		return newBackupError("LoadConfig", path, fmt.Errorf("unsupported format: %s", ext))
 	}
 
 	if err != nil {
-		return nil, newBackupError("ParseConfig", path, err)
+		return newBackupError("ParseConfig", path, err)
+	}
+	config.present = make(map[string]bool, len(keys))
+	for key := range keys {
+		// encoding/json matches keys to tags regardless of case; the tags
+		// are all lower case
+		if ext == ".json" {
+			key = strings.ToLower(key)
+		}
+		config.present[key] = true
 	}
 
-	return config, nil
+	if err := config.expandPaths(); err != nil {
+		return newBackupError("ExpandConfig", path, err)
+	}
+
+	return nil
+}
+
+// Merge overlays other onto c field by field; slices are replaced rather
+// than appended. A config loaded from a file overlays exactly the settings
+// in its file, so a later layer can turn a boolean off or set a number to
+// zero. For a config built in code, only its non-zero fields are set.
+func (c *Config) Merge(other *Config) {
+	dst := reflect.ValueOf(c).Elem()
+	src := reflect.ValueOf(other).Elem()
+	fields := src.Type()
+	for i := 0; i < src.NumField(); i++ {
+		field := fields.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		set := !src.Field(i).IsZero()
+		if key := field.Tag.Get("yaml"); key != "" && other.present != nil {
+			set = other.present[key] || other.present[field.Tag.Get("json")]
+		}
+		if set {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+
+	if other.present != nil {
+		if c.present == nil {
+			c.present = make(map[string]bool, len(other.present))
+		}
+		for key := range other.present {
+			c.present[key] = true
+		}
+	}
+}
+
+// checksumConcurrency returns the number of skip-check workers, defaulting
+// to the copy concurrency
+func (c *Config) checksumConcurrency() int {
+	if c.ChecksumConcurrency > 0 {
+		return c.ChecksumConcurrency
+	}
+	return c.Concurrency
+}
+
+// expandPaths resolves ~, ~user and environment variables in the directory
+// fields. Entries in folders_to_backup are names below the source, which may
+// legitimately contain $ or start with ~, so they are left as written.
+func (c *Config) expandPaths() error {
+	var err error
+	if c.SourceDirectory, err = expandPath(c.SourceDirectory); err != nil {
+		return fmt.Errorf("source_directory: %w", err)
+	}
+	if c.TargetDirectory, err = expandPath(c.TargetDirectory); err != nil {
+		return fmt.Errorf("target_directory: %w", err)
+	}
+	if c.VersionDirectory, err = expandPath(c.VersionDirectory); err != nil {
+		return fmt.Errorf("version_directory: %w", err)
+	}
+	for i, target := range c.AdditionalTargets {
+		if c.AdditionalTargets[i], err = expandPath(target); err != nil {
+			return fmt.Errorf("additional_targets: %w", err)
+		}
+	}
+	return nil
+}
+
+// expandPath expands a leading ~ or ~user and any $VAR or ${VAR} references.
+// Unset variables are reported as errors rather than silently expanding to "".
+func expandPath(path string) (string, error) {
+	var missing []string
+	path = os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+
+	name, rest, _ := strings.Cut(path[1:], string(filepath.Separator))
+	var home string
+	if name == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~: %w", err)
+		}
+		home = dir
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~%s: %w", name, err)
+		}
+		home = u.HomeDir
+	}
+
+	return filepath.Join(home, rest), nil
 }
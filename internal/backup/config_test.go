@@ -0,0 +1,220 @@
+// config_test.go
+package backup
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigsMerge(t *testing.T) {
+	base := `
+source_directory: /src
+target_directory: /tgt
+folders_to_backup: [Documents, Photos]
+exclude_patterns: ["*.tmp"]
+deep_duplicate_check: true
+mirror_mode: true
+concurrency: 8
+max_open_files: 64
+`
+	tests := []struct {
+		name  string
+		file  string
+		layer string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name:  "scalar override",
+			file:  "machine.yaml",
+			layer: "concurrency: 2\ntarget_directory: /mnt/backup\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Concurrency != 2 || cfg.TargetDirectory != "/mnt/backup" {
+					t.Errorf("concurrency %d, target %s; want 2, /mnt/backup", cfg.Concurrency, cfg.TargetDirectory)
+				}
+				if cfg.SourceDirectory != "/src" || !cfg.MirrorMode {
+					t.Error("settings absent from the layer were not kept")
+				}
+			},
+		},
+		{
+			name:  "slice replaced",
+			file:  "machine.yaml",
+			layer: "folders_to_backup: [Music]\n",
+			check: func(t *testing.T, cfg *Config) {
+				if !slices.Equal(cfg.FoldersToBackup, []string{"Music"}) {
+					t.Errorf("folders_to_backup = %v, want [Music]", cfg.FoldersToBackup)
+				}
+				if !slices.Equal(cfg.ExcludePatterns, []string{"*.tmp"}) {
+					t.Errorf("exclude_patterns = %v, want it kept", cfg.ExcludePatterns)
+				}
+			},
+		},
+		{
+			name:  "false and zero override",
+			file:  "machine.yaml",
+			layer: "deep_duplicate_check: false\nmirror_mode: false\nmax_open_files: 0\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.DeepDuplicateCheck || cfg.MirrorMode {
+					t.Error("a false setting in the later file did not win")
+				}
+				if cfg.MaxOpenFiles != 0 {
+					t.Errorf("max_open_files = %d, want 0", cfg.MaxOpenFiles)
+				}
+				if cfg.Concurrency != 8 {
+					t.Errorf("concurrency = %d, want 8 kept", cfg.Concurrency)
+				}
+			},
+		},
+		{
+			name:  "JSON keys in another case",
+			file:  "machine.json",
+			layer: `{"Concurrency": 2, "MIRROR_MODE": false}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Concurrency != 2 || cfg.MirrorMode {
+					t.Errorf("concurrency %d, mirror_mode %v; want 2, false", cfg.Concurrency, cfg.MirrorMode)
+				}
+				if cfg.MaxOpenFiles != 64 {
+					t.Errorf("max_open_files = %d, want 64 kept", cfg.MaxOpenFiles)
+				}
+			},
+		},
+		{
+			name:  "false override from JSON",
+			file:  "machine.json",
+			layer: `{"mirror_mode": false, "exclude_patterns": []}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.MirrorMode {
+					t.Error("a false setting in the later file did not win")
+				}
+				if len(cfg.ExcludePatterns) != 0 {
+					t.Errorf("exclude_patterns = %v, want it emptied", cfg.ExcludePatterns)
+				}
+				if !cfg.DeepDuplicateCheck {
+					t.Error("deep_duplicate_check absent from the layer was not kept")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"base.yaml": base, tt.file: tt.layer})
+			cfg, err := LoadConfigs(filepath.Join(dir, "base.yaml"), filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("LoadConfigs: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestMergeInCode(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MirrorMode = true
+	// Fields of a config built in code count as set only when non-zero
+	cfg.Merge(&Config{Concurrency: 2})
+	if cfg.Concurrency != 2 || !cfg.MirrorMode || cfg.BufferSize != NewConfig().BufferSize {
+		t.Errorf("Merge = concurrency %d, mirror %v, buffer %d", cfg.Concurrency, cfg.MirrorMode, cfg.BufferSize)
+	}
+}
+
+func TestLoadConfigsMissingLayer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"base.yaml": "concurrency: 2\n"})
+	if _, err := LoadConfigs(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "missing.yaml")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadConfigs with a missing layer: %v, want not-exist", err)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	t.Setenv("BB_TEST_ROOT", "/mnt/backup")
+	t.Setenv("BB_TEST_EMPTY", "")
+	os.Unsetenv("BB_TEST_UNSET")
+
+	type testCase struct {
+		name    string
+		path    string
+		want    string
+		wantErr string
+	}
+	tests := []testCase{
+		{name: "plain", path: "/data/photos", want: "/data/photos"},
+		{name: "home", path: "~", want: home},
+		{name: "under home", path: "~/Pictures", want: filepath.Join(home, "Pictures")},
+		{name: "variable", path: "$BB_TEST_ROOT/photos", want: "/mnt/backup/photos"},
+		{name: "braced variable", path: "${BB_TEST_ROOT}-2024", want: "/mnt/backup-2024"},
+		{name: "set but empty", path: "/x$BB_TEST_EMPTY/y", want: "/x/y"},
+		{name: "unset variable", path: "$BB_TEST_UNSET/photos", wantErr: "BB_TEST_UNSET"},
+		{name: "unknown user", path: "~bb-no-such-user/photos", wantErr: "~bb-no-such-user"},
+	}
+	if current, err := user.Current(); err == nil && current.HomeDir != "" {
+		tests = append(tests, testCase{name: "named user", path: "~" + current.Username + "/docs", want: filepath.Join(current.HomeDir, "docs")})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPath(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandPath(%q) error = %v, want one naming %s", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandPath(%q): %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("expandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigExpandsPaths(t *testing.T) {
+	t.Setenv("BB_TEST_ROOT", "/mnt/backup")
+	os.Unsetenv("BB_TEST_UNSET")
+	tests := []struct {
+		name        string
+		config      string
+		want        string
+		wantFolders []string
+		wantErr     string
+	}{
+		{"expanded", "source_directory: /src\ntarget_directory: $BB_TEST_ROOT/tgt\n", "/mnt/backup/tgt", nil, ""},
+		{"unset variable", "source_directory: /src\ntarget_directory: ${BB_TEST_UNSET}/tgt\n", "", nil, "target_directory: environment variable BB_TEST_UNSET is not set"},
+		// Folder names are taken literally
+		{"folders not expanded", "source_directory: /src\ntarget_directory: /tgt\nfolders_to_backup: [\"$RECYCLE.BIN\", \"~notes\", \"$BB_TEST_ROOT\"]\n",
+			"/tgt", []string{"$RECYCLE.BIN", "~notes", "$BB_TEST_ROOT"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"config.yaml": tt.config})
+			cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.TargetDirectory != tt.want {
+				t.Errorf("target_directory = %q, want %q", cfg.TargetDirectory, tt.want)
+			}
+			if !slices.Equal(cfg.FoldersToBackup, tt.wantFolders) {
+				t.Errorf("folders_to_backup = %q, want %q", cfg.FoldersToBackup, tt.wantFolders)
+			}
+		})
+	}
+}
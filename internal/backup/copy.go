@@ -2,58 +2,166 @@
 package backup
 
 import (
-	"crypto/sha256"
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"time"
 )
 
-// performCopy executes a single copy operation
-func (s *Service) copyFile(task CopyTask) error {
-	// First check if we should skip this file
-	if skip, err := s.shouldSkipFile(task); err != nil {
+// checkFile decides whether task needs copying, recording it as skipped
+// when it does not. Runs on the checksum pool ahead of copyFile.
+func (s *Service) checkFile(task CopyTask) (needsCopy bool, err error) {
+	defer s.diag.addCheck(s.diag.now())
+
+	// Every archive holds the whole source
+	if s.archiveMode() {
+		s.explain(task.Source, decisionCopy, "target_format %s writes every file", s.config.TargetFormat)
+		return true, nil
+	}
+
+	s.metrics.StartFile(task.Source)
+	defer s.metrics.FinishFile(task.Source)
+
+	// Files under a directory blocked by a file fail without being examined
+	if err := s.blockedDestDir(task); err != nil {
 		s.metrics.IncrementFailed()
-		return err
-	} else if skip {
+		s.metrics.RecordTarget(s.config.TargetDirectory, 0, err)
+		return false, err
+	}
+
+	// Files under immutable_paths are trusted once backed up, and with
+	// --since-last so are files unmodified since the previous backup
+	skip := s.immutable != nil && s.trustImmutable(task) ||
+		s.sinceLast != nil && s.trustSinceLast(task)
+	if !skip {
+		if skip, err = s.shouldSkipFile(task); err != nil {
+			s.metrics.IncrementFailed()
+			s.metrics.RecordTarget(s.config.TargetDirectory, 0, err)
+			return false, err
+		}
+		// A new snapshot links what hasn't changed since the previous one
+		if !skip && s.linkDest != "" {
+			skip = s.linkUnchanged(task)
+		}
+	}
+	if skip {
 		s.metrics.IncrementSkipped(task.Size) // Keep only this increment
+		if s.config.Options.IgnoreExisting {
+			// Only an existing destination is skipped in this mode
+			s.metrics.IncrementExisting()
+		}
 		// Add file to version manager as skipped
 		if s.versioner != nil {
-			metadata := FileMetadata{
-				Path:    task.Source,
-				Size:    task.Size,
-				ModTime: task.ModTime,
-			}
-			s.versioner.AddFile(task.Source, metadata)
+			metadata := s.fileMetadata(task)
+			s.recordFile(metadata)
+		}
+		s.recordAttributes(task)
+		// Additional targets may still be missing the file
+		if replicas := s.replicasFor(task); len(replicas) > 0 {
+			return false, s.replicate(task, replicas)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// copyFile copies a file checkFile found changed or missing
+func (s *Service) copyFile(task CopyTask) error {
+	defer s.diag.addBusy(s.diag.now())
+	s.metrics.StartFile(task.Source)
+	defer s.metrics.FinishFile(task.Source)
+
+	if s.archive != nil {
+		if err := s.archiveFile(task); err != nil {
+			s.metrics.IncrementFailed()
+			return err
 		}
 		return nil
 	}
 
-	if err := s.performCopy(task); err != nil {
+	code := ""
+	if s.config.Options.Itemize {
+		code = itemizeCode(task)
+	}
+
+	// A file renamed in the source may already be on the target
+	if s.moves != nil && s.linkMovedFile(task) {
+		s.metrics.IncrementCompleted(0)
+		s.metrics.IncrementMoved()
+		s.metrics.RecordTarget(s.config.TargetDirectory, 0, nil)
+		s.itemize(code, task.Destination)
+		s.recordAttributes(task)
+		if replicas := s.replicasFor(task); len(replicas) > 0 {
+			return s.replicate(task, replicas)
+		}
+		return nil
+	}
+
+	// Replicas apply safe mode to their own copies, so they are found from
+	// the task's real destination
+	replicas := s.replicasFor(task)
+	task = s.safeModeDestination(task)
+
+	if err := s.copyWithReplicas(task, replicas); err != nil {
 		s.metrics.IncrementFailed()
+		s.metrics.RecordTarget(s.config.TargetDirectory, 0, err)
 		return err
 	}
+	s.metrics.RecordTarget(s.config.TargetDirectory, task.Size, nil)
+	s.itemize(code, task.Destination)
 
 	return nil
 }
 
+// openSource opens a file for copying; tests replace it to simulate a source
+// that fails partway through
+var openSource = func(name string) (io.ReadCloser, error) { return os.Open(name) }
+
 func (s *Service) performCopy(task CopyTask) error {
+	return s.copyWithReplicas(task, nil)
+}
+
+// copyWithReplicas copies task and writes the same data to any replicas in
+// the one pass over the source
+func (s *Service) copyWithReplicas(task CopyTask, replicas []*replica) error {
 	startTime := time.Now()
 
-	src, err := os.Open(task.Source)
+	// Source and destinations are acquired together to avoid hold-and-wait
+	handles := 2 + len(replicas)
+	s.files.acquire(handles)
+	defer s.files.release(handles)
+
+	src, err := openSource(task.Source)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %w", err)
 	}
 	defer src.Close()
 
 	// Create destination directory if needed
-	if err := os.MkdirAll(filepath.Dir(task.Destination), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	if err := s.prepareDestDir(filepath.Dir(task.Destination)); err != nil {
+		return err
+	}
+
+	// Copy into a temp file beside the destination. A temp file left by an
+	// interrupted attempt is continued rather than restarted, unless replicas
+	// need the whole file.
+	tempPath := task.Destination + copyTempSuffix
+	var offset int64
+	if len(replicas) == 0 {
+		offset = resumeOffset(task, tempPath)
 	}
 
-	dst, err := os.Create(task.Destination)
+	var dst *os.File
+	if offset > 0 {
+		dst, err = os.OpenFile(tempPath, os.O_RDWR, 0644)
+	} else {
+		dst, err = os.Create(tempPath)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
@@ -61,42 +169,189 @@ func (s *Service) performCopy(task CopyTask) error {
 
 	// Copy with progress tracking and checksum calculation
 	buf := make([]byte, s.config.BufferSize)
-	hasher := sha256.New()
-	writer := io.MultiWriter(dst, hasher)
+	hasher := s.newHash()
+	writers := append([]io.Writer{dst, hasher}, openReplicas(replicas)...)
+	// A grown file also hashes its appended bytes, unless resumed midway
+	var appended *appendHasher
+	var previous FileMetadata
+	if s.appendIndex != nil && offset == 0 {
+		if appended, previous = s.newAppendHasher(task); appended != nil {
+			writers = append(writers, appended)
+		}
+	}
+	writer := io.Writer(io.MultiWriter(writers...))
+
+	// A stall past per_file_timeout, reading the source or writing to any
+	// destination, isn't a read error; the attempt fails and the retry
+	// continues from the temp file
+	deadline := newDeadlineIO(task)
+	defer deadline.close()
 
-	copied, err := io.CopyBuffer(writer, src, buf)
+	// When resuming, hash the part already copied and the matching part of
+	// the source; reading both leaves them positioned at the offset
+	var sourceHasher hash.Hash
+	if offset > 0 {
+		s.logger.Info("Resuming copy of %s at byte %d", task.Source, offset)
+		sourceHasher = s.newHash()
+		if _, err := io.CopyBuffer(hasher, io.LimitReader(dst, offset), buf); err != nil {
+			return fmt.Errorf("failed to read partial copy: %w", err)
+		}
+		if _, err := io.CopyBuffer(sourceHasher, io.LimitReader(deadline.reader(src), offset), buf); err != nil {
+			return fmt.Errorf("failed to read source file: %w", err)
+		}
+		writer = io.MultiWriter(dst, hasher, sourceHasher)
+	}
+
+	copyStart := s.diag.now()
+	copied, err := io.CopyBuffer(deadline.writer(writer), deadline.reader(sourceReader{r: src, diag: s.diag}), buf)
+	s.diag.addCopy(copyStart)
 	if err != nil {
+		// A failed write to target_directory also stops the read, so the
+		// replicas are incomplete either way
+		s.abortReplicas(replicas, err)
+		var readErr *sourceReadError
+		if errors.As(err, &readErr) {
+			dst.Close()
+			return s.onReadError(task, tempPath, offset+copied, readErr)
+		}
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
+	// The replicas now hold the whole file, so from here a failure on
+	// target_directory alone still lets them finish; each destination's
+	// outcome is recorded separately
+	failPrimary := func(err error) error {
+		sourceInfo, _ := os.Stat(task.Source)
+		s.finishReplicas(replicas, sourceInfo, copied)
+		return err
+	}
+	if s.config.Fsync {
+		if err := dst.Sync(); err != nil {
+			return failPrimary(fmt.Errorf("failed to sync destination file: %w", err))
+		}
+	}
+	if err := dst.Close(); err != nil {
+		return failPrimary(fmt.Errorf("failed to close destination file: %w", err))
+	}
+
+	// A resumed copy must hash the same as the source, or the kept part was stale
+	if sourceHasher != nil && !bytes.Equal(hasher.Sum(nil), sourceHasher.Sum(nil)) {
+		os.Remove(tempPath)
+		return fmt.Errorf("resumed copy of %s does not match the source: %w", task.Source, ErrChecksumMismatch)
+	}
+
+	if err := os.Rename(tempPath, task.Destination); err != nil {
+		return failPrimary(fmt.Errorf("failed to move copy into place: %w", err))
+	}
+	if s.config.Fsync {
+		if err := syncDir(filepath.Dir(task.Destination)); err != nil {
+			return failPrimary(fmt.Errorf("failed to sync destination directory: %w", err))
+		}
+	}
 
 	// Calculate operation duration and speed
 	duration := time.Since(startTime)
-	speedMBps := float64(copied) / 1024 / 1024 / duration.Seconds()
+	speedMBps := 0.0
+	if duration > 0 {
+		speedMBps = float64(copied) / 1024 / 1024 / duration.Seconds()
+	}
+	if threshold := s.config.SlowFileThreshold; threshold > 0 && duration > threshold {
+		s.logger.Warn("Slow copy: %s took %v (threshold %v)", task.Source, duration.Round(time.Millisecond), threshold)
+		s.metrics.RecordSlowFile(task.Source, duration)
+	}
+	copied += offset // Count any resumed part toward the file's size
 
 	// Update metrics only once here
 	s.metrics.IncrementCompleted(copied)
 
-	// Preserve file mode
-	if sourceInfo, err := os.Stat(task.Source); err == nil {
+	// Preserve file mode and modification time; update mode relies on the
+	// latter to tell edits on the target from ordinary copies
+	sourceInfo, err := os.Stat(task.Source)
+	if err == nil {
 		if err := os.Chmod(task.Destination, sourceInfo.Mode()); err != nil {
 			s.logger.Warn("Failed to preserve file mode for %s: %v", task.Destination, err)
 		}
+		if err := os.Chtimes(task.Destination, time.Now(), sourceInfo.ModTime()); err != nil {
+			s.logger.Warn("Failed to preserve modification time for %s: %v", task.Destination, err)
+		}
+		s.noteSourceChange(task, sourceInfo)
+		if s.config.MetadataSidecar {
+			s.writeSidecar(task, sourceInfo)
+		}
+	} else {
+		sourceInfo = nil
 	}
+	s.finishReplicas(replicas, sourceInfo, copied)
 
-	s.logger.Info("Copied %s (%.2f MB) at %.2f MB/s",
+	s.logFileDone("Copied %s (%.2f MB) at %.2f MB/s",
 		task.Source,
 		float64(copied)/1024/1024,
 		speedMBps)
 
 	if s.versioner != nil {
-		metadata := FileMetadata{
-			Path:     task.Source,
-			Size:     copied,
-			ModTime:  time.Now(),
-			Checksum: hex.EncodeToString(hasher.Sum(nil)),
+		metadata := s.fileMetadata(task)
+		metadata.Size = copied
+		metadata.ModTime = time.Now()
+		metadata.Checksum = hex.EncodeToString(hasher.Sum(nil))
+		metadata.Duration = duration
+		if appended != nil {
+			appended.record(&metadata, previous)
 		}
-		s.versioner.AddFile(task.Source, metadata)
+		s.recordFile(metadata)
 	}
 
 	return nil
 }
+
+// noteSourceChange warns about and counts a source file whose size or
+// modification time no longer matches what the scan recorded: it was
+// modified while being backed up, so its copy may mix old and new content
+func (s *Service) noteSourceChange(task CopyTask, info os.FileInfo) {
+	if task.ModTime.IsZero() {
+		return
+	}
+	if info.Size() == task.Size && info.ModTime().Equal(task.ModTime) {
+		return
+	}
+	s.logger.Warn("%s changed during the backup (size %d -> %d, modified %s -> %s); the copy may be inconsistent",
+		task.Source, task.Size, info.Size(),
+		task.ModTime.Format(time.RFC3339), info.ModTime().Format(time.RFC3339))
+	s.metrics.IncrementChanged()
+}
+
+// copyTempSuffix marks a copy in progress beside its destination
+const copyTempSuffix = ".backup-butler-part"
+
+// resumeOffset returns how much of an interrupted copy in tempPath can be
+// kept: its size, provided it is shorter than the source and the source has
+// not been modified since the task was created or the temp file last written
+func resumeOffset(task CopyTask, tempPath string) int64 {
+	tempInfo, err := os.Stat(tempPath)
+	if err != nil || tempInfo.Size() == 0 || tempInfo.Size() >= task.Size {
+		return 0
+	}
+
+	sourceInfo, err := os.Stat(task.Source)
+	if err != nil || sourceInfo.Size() != task.Size || !sourceInfo.ModTime().Equal(task.ModTime) {
+		return 0
+	}
+	if sourceInfo.ModTime().After(tempInfo.ModTime()) {
+		return 0
+	}
+	return tempInfo.Size()
+}
+
+// fileMetadata builds the manifest entry for a task, recording the target
+// path only when it differs from the manifest key
+func (s *Service) fileMetadata(task CopyTask) FileMetadata {
+	metadata := FileMetadata{
+		Path:    s.manifestKey(task.Source),
+		Size:    task.Size,
+		ModTime: task.ModTime,
+	}
+	if relPath, err := filepath.Rel(s.config.TargetDirectory, task.Destination); err == nil {
+		if targetKey := filepath.ToSlash(relPath); targetKey != metadata.Path {
+			metadata.TargetKey = targetKey
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,126 @@
+// copy_test.go
+package backup
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumePartialCopy(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	half := len(content) / 2
+
+	tests := []struct {
+		name    string
+		partial string        // Temp file left by the failed attempt
+		age     time.Duration // How much older than the source the temp file is; negative is newer
+		wantErr error
+	}{
+		{"resumed from a good partial copy", content[:half], -time.Minute, nil},
+		// Resuming trusts the kept part, and the final hash catches a stale one
+		{"stale partial copy", "X" + content[1:half], -time.Minute, ErrChecksumMismatch},
+		// A temp file older than the source predates its last change, so the
+		// copy restarts and the bad prefix is overwritten
+		{"partial copy older than the source", "X" + content[1:half], time.Minute, nil},
+		{"temp file as large as the source", content, -time.Minute, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"big.bin": content})
+			cfg.BufferSize = minBufferSize
+			s := newTestService(t, cfg)
+			s.metrics = NewBackupMetrics(1, true)
+
+			info, err := os.Stat(sourcePath(cfg, "big.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			task := CopyTask{Source: sourcePath(cfg, "big.bin"), Destination: targetPath(cfg, "big.bin"), Size: info.Size(), ModTime: info.ModTime()}
+			tempPath := task.Destination + copyTempSuffix
+			writeFiles(t, targetPath(cfg, ""), map[string]string{"big.bin" + copyTempSuffix: tt.partial})
+			tempTime := info.ModTime().Add(-tt.age)
+			if err := os.Chtimes(tempPath, tempTime, tempTime); err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.performCopy(task)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("performCopy error = %v, want %v", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(tempPath); !os.IsNotExist(statErr) {
+				t.Error("temp file left behind")
+			}
+			if tt.wantErr != nil {
+				if _, statErr := os.Stat(task.Destination); !os.IsNotExist(statErr) {
+					t.Error("a mismatched resumed copy was moved into place")
+				}
+				return
+			}
+			if got := readFile(t, task.Destination); got != content {
+				t.Errorf("copy has %d bytes, differs from the source", len(got))
+			}
+		})
+	}
+}
+
+func TestSourceChangedDuringBackup(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(t *testing.T, path string, scanned os.FileInfo)
+		noModTime   bool // The task carries no scanned modification time
+		wantChanged int
+	}{
+		{"unchanged", func(t *testing.T, path string, scanned os.FileInfo) {}, false, 0},
+		{"content grew", func(t *testing.T, path string, scanned os.FileInfo) {
+			if err := os.WriteFile(path, []byte("alpha, edited"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, false, 1},
+		{"same size, touched", func(t *testing.T, path string, scanned os.FileInfo) {
+			later := scanned.ModTime().Add(time.Minute)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}, false, 1},
+		{"nothing scanned to compare", func(t *testing.T, path string, scanned os.FileInfo) {
+			if err := os.WriteFile(path, []byte("alpha, edited"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+			s := newTestService(t, cfg)
+			s.metrics = NewBackupMetrics(1, true)
+
+			// The task records the file as the scan saw it; it changes before
+			// the copy reaches it
+			source := sourcePath(cfg, "a.txt")
+			scanned, err := os.Stat(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task := CopyTask{Source: source, Destination: targetPath(cfg, "a.txt"), Size: scanned.Size(), ModTime: scanned.ModTime()}
+			if tt.noModTime {
+				task.ModTime = time.Time{}
+			}
+			tt.modify(t, source, scanned)
+
+			if err := s.performCopy(task); err != nil {
+				t.Fatalf("performCopy: %v", err)
+			}
+			stats := s.metrics.GetStats()
+			if stats.FilesChanged != tt.wantChanged {
+				t.Errorf("FilesChanged = %d, want %d", stats.FilesChanged, tt.wantChanged)
+			}
+			// The copy still goes ahead with the content read at copy time
+			if got, want := readFile(t, task.Destination), readFile(t, source); got != want {
+				t.Errorf("a.txt = %q, want %q", got, want)
+			}
+		})
+	}
+}
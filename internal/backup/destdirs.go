@@ -0,0 +1,106 @@
+// destdirs.go
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// destDirs tracks the destination directories a run has prepared, so each
+// is created, or found blocked by a file, once however many files it holds.
+// The outcome is kept, and every copy into a blocked directory fails with
+// the same conflict without touching the target again.
+type destDirs struct {
+	mu       sync.Mutex
+	ready    map[string]error
+	blockers map[string]bool // Files found standing in a directory's place
+}
+
+func newDestDirs() *destDirs {
+	return &destDirs{ready: make(map[string]error), blockers: make(map[string]bool)}
+}
+
+// prepareDestDirs creates the destination directories of every task before
+// any is copied, so directory conflicts are reported up front
+func (s *Service) prepareDestDirs(tasks []CopyTask) {
+	for _, task := range tasks {
+		s.prepareDestDir(filepath.Dir(task.Destination))
+	}
+}
+
+// prepareDestDir makes sure dir exists as a directory. Outside a backup run
+// it is simply created; during one, each directory is prepared once and
+// counted in the run's stats.
+func (s *Service) prepareDestDir(dir string) error {
+	d := s.dirs
+	if d == nil {
+		_, _, err := createDestDir(dir)
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err, ok := d.ready[dir]; ok {
+		return err
+	}
+	created, blocker, err := createDestDir(dir)
+	d.ready[dir] = err
+	if blocker != "" {
+		if !d.blockers[blocker] {
+			d.blockers[blocker] = true
+			s.logger.Error("Directory conflict: %v; nothing below it will be copied", err)
+			s.metrics.IncrementDirConflict()
+		}
+	} else if err != nil {
+		s.logger.Error("Failed to create destination directory %s: %v", dir, err)
+	}
+	s.metrics.AddDirsCreated(created)
+	return err
+}
+
+// blockedDestDir returns the directory conflict found for task's
+// destination directory, if the run has prepared it and found one
+func (s *Service) blockedDestDir(task CopyTask) error {
+	d := s.dirs
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.ready[filepath.Dir(task.Destination)]; errors.Is(err, ErrDirectoryConflict) {
+		return err
+	}
+	return nil
+}
+
+// createDestDir creates dir and any missing parents, returning how many it
+// created. A file standing where one of them should be is returned as the
+// blocker, with a directory conflict naming it instead of MkdirAll's bare
+// error.
+func createDestDir(dir string) (int, string, error) {
+	missing := 0
+	for p := dir; ; p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		if err == nil {
+			if !info.IsDir() {
+				return 0, p, withSentinel(fmt.Errorf("%s is a file where a directory is needed", p), ErrDirectoryConflict)
+			}
+			break
+		}
+		// Missing, or below a file (not a directory): look further up
+		missing++
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	if missing == 0 {
+		return 0, "", nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	return missing, "", nil
+}
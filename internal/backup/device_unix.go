@@ -0,0 +1,18 @@
+//go:build !windows
+
+// device_unix.go
+package backup
+
+import (
+	"os"
+	"syscall"
+)
+
+// deviceID returns the id of the device a file lives on
+func deviceID(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
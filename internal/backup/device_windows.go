@@ -0,0 +1,12 @@
+//go:build windows
+
+// device_windows.go
+package backup
+
+import "os"
+
+// deviceID is not available on Windows, where mounts don't nest in the same
+// way; one_file_system has no effect there
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
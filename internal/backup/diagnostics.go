@@ -0,0 +1,106 @@
+// diagnostics.go
+package backup
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// diagnostics collects timings for --diagnostics. Every method is a no-op on
+// a nil receiver, so runs without the flag only pay for a nil check.
+type diagnostics struct {
+	statNanos  atomic.Int64 // Stat calls in the skip check
+	hashNanos  atomic.Int64 // Whole-file checksums outside the copy itself
+	copyNanos  atomic.Int64 // Copy loops, including the hash computed while copying
+	readCalls  atomic.Int64 // Reads from sources during copies
+	readBytes  atomic.Int64 // Bytes those reads returned
+	checkNanos atomic.Int64 // Time skip-check workers spent on tasks
+	busyNanos  atomic.Int64 // Time copy workers spent on tasks
+	wallNanos  atomic.Int64 // Duration of the check and copy phase
+}
+
+// now returns the start of a measurement, or the zero time when off
+func (d *diagnostics) now() time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// record adds the time since start to counter
+func (d *diagnostics) record(counter *atomic.Int64, start time.Time) {
+	counter.Add(int64(time.Since(start)))
+}
+
+func (d *diagnostics) addStat(start time.Time) {
+	if d != nil {
+		d.record(&d.statNanos, start)
+	}
+}
+
+func (d *diagnostics) addHash(start time.Time) {
+	if d != nil {
+		d.record(&d.hashNanos, start)
+	}
+}
+
+func (d *diagnostics) addCopy(start time.Time) {
+	if d != nil {
+		d.record(&d.copyNanos, start)
+	}
+}
+
+func (d *diagnostics) addCheck(start time.Time) {
+	if d != nil {
+		d.record(&d.checkNanos, start)
+	}
+}
+
+func (d *diagnostics) addBusy(start time.Time) {
+	if d != nil {
+		d.record(&d.busyNanos, start)
+	}
+}
+
+func (d *diagnostics) addWall(start time.Time) {
+	if d != nil {
+		d.record(&d.wallNanos, start)
+	}
+}
+
+// read records one source read of n bytes
+func (d *diagnostics) read(n int) {
+	if d == nil {
+		return
+	}
+	d.readCalls.Add(1)
+	d.readBytes.Add(int64(n))
+}
+
+// report formats the diagnostics block for a run with the given worker
+// counts and retries
+func (d *diagnostics) report(copyWorkers, checkWorkers int, retries int64) string {
+	wall := time.Duration(d.wallNanos.Load())
+	utilization := func(busy int64, workers int) float64 {
+		if wall <= 0 || workers <= 0 {
+			return 0
+		}
+		return float64(busy) / float64(int64(wall)*int64(workers)) * 100
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Diagnostics:\n")
+	fmt.Fprintf(&b, "  Copy workers: %d, busy %.1f%%\n", copyWorkers, utilization(d.busyNanos.Load(), copyWorkers))
+	fmt.Fprintf(&b, "  Check workers: %d, busy %.1f%%\n", checkWorkers, utilization(d.checkNanos.Load(), checkWorkers))
+	if calls := d.readCalls.Load(); calls > 0 {
+		fmt.Fprintf(&b, "  Average bytes per read: %d (%d reads)\n", d.readBytes.Load()/calls, calls)
+	}
+	fmt.Fprintf(&b, "  Retries: %d\n", retries)
+	fmt.Fprintf(&b, "  Time stat-ing: %v\n", time.Duration(d.statNanos.Load()).Round(time.Microsecond))
+	fmt.Fprintf(&b, "  Time hashing: %v\n", time.Duration(d.hashNanos.Load()).Round(time.Microsecond))
+	fmt.Fprintf(&b, "  Time copying (with inline hashing): %v\n", time.Duration(d.copyNanos.Load()).Round(time.Microsecond))
+	fmt.Fprintf(&b, "  Worker times are summed across workers over %v\n", wall.Round(time.Microsecond))
+	return b.String()
+}
@@ -0,0 +1,76 @@
+// diskspace.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// freeSpaceCheckInterval is how often the target's free space is re-checked
+const freeSpaceCheckInterval = 5 * time.Second
+
+// errFreeSpaceUnknown is returned by freeSpace on platforms that can't report it
+var errFreeSpaceUnknown = errors.New("free space unknown on this platform")
+
+// checkFreeSpace returns an error when the target has less free space than
+// the configured reserve. Where free space can't be measured the check is
+// skipped.
+func (s *Service) checkFreeSpace() error {
+	free, err := freeSpace(s.config.TargetDirectory)
+	if errors.Is(err, errFreeSpaceUnknown) {
+		return nil
+	}
+	if err != nil {
+		return newBackupError("CheckFreeSpace", s.config.TargetDirectory, err)
+	}
+	if free < s.config.MinFreeSpace {
+		return newBackupError("CheckFreeSpace", s.config.TargetDirectory,
+			withSentinel(fmt.Errorf("only %.2f MB free, below reserve of %.2f MB",
+				float64(free)/1024/1024, float64(s.config.MinFreeSpace)/1024/1024), ErrInsufficientSpace))
+	}
+	return nil
+}
+
+// startSpaceMonitor checks the target's free space periodically and cancels
+// the run when it drops below the reserve. The returned function stops the
+// monitor and reports the low-space error, if any.
+func (s *Service) startSpaceMonitor(ctx context.Context, cancel context.CancelFunc) func() error {
+	var mu sync.Mutex
+	var lowSpaceErr error
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(freeSpaceCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.checkFreeSpace(); err != nil {
+					s.logger.Error("Stopping backup: %v", err)
+					mu.Lock()
+					lowSpaceErr = err
+					mu.Unlock()
+					cancel()
+					return
+				}
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		close(stop)
+		<-stopped
+		mu.Lock()
+		defer mu.Unlock()
+		return lowSpaceErr
+	}
+}
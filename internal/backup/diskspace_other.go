@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+// diskspace_other.go
+package backup
+
+// freeSpace is not implemented on this platform, where Statfs_t lacks the
+// fields it needs
+func freeSpace(path string) (int64, error) {
+	return 0, errFreeSpaceUnknown
+}
@@ -0,0 +1,38 @@
+// diskspace_test.go
+package backup
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCheckFreeSpace(t *testing.T) {
+	free, err := freeSpace(t.TempDir())
+	if err != nil {
+		t.Fatalf("freeSpace: %v", err)
+	}
+	if free <= 0 {
+		t.Fatalf("freeSpace = %d, want a positive amount", free)
+	}
+
+	tests := []struct {
+		name    string
+		reserve int64
+		wantErr error
+	}{
+		{"reserve available", 1, nil},
+		{"reserve beyond the disk", math.MaxInt64, ErrInsufficientSpace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.MinFreeSpace = tt.reserve
+			s := newTestService(t, cfg)
+
+			if err := s.checkFreeSpace(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkFreeSpace = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
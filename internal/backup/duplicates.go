@@ -0,0 +1,91 @@
+// duplicates.go
+package backup
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DuplicateSet is a group of source files with identical content
+type DuplicateSet struct {
+	Checksum string
+	Size     int64    // Size of each file in the set
+	Paths    []string // Source paths, sorted
+}
+
+// Reclaimable returns the bytes freed by keeping only one file of the set
+func (d DuplicateSet) Reclaimable() int64 {
+	return d.Size * int64(len(d.Paths)-1)
+}
+
+// DuplicateReport lists the duplicate sets found in the source
+type DuplicateReport struct {
+	FilesScanned int
+	FilesHashed  int
+	Sets         []DuplicateSet // Largest reclaimable first
+	Reclaimable  int64          // Total bytes reclaimable across all sets
+}
+
+// FindDuplicates walks the source and groups identical files by checksum.
+// Only files sharing a size with another file are hashed. Nothing is changed.
+func (s *Service) FindDuplicates(ctx context.Context) (*DuplicateReport, error) {
+	tasks, totalFiles, err := s.createTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	bySize := make(map[int64][]CopyTask)
+	for _, task := range tasks {
+		if task.Size > 0 {
+			bySize[task.Size] = append(bySize[task.Size], task)
+		}
+	}
+
+	var candidates []CopyTask
+	for _, group := range bySize {
+		if len(group) > 1 {
+			candidates = append(candidates, group...)
+		}
+	}
+
+	var mu sync.Mutex
+	byChecksum := make(map[string][]CopyTask)
+	hashFn := func(task CopyTask) error {
+		checksum, err := s.calculateChecksum(task.Source)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		byChecksum[checksum] = append(byChecksum[checksum], task)
+		return nil
+	}
+
+	pool := NewWorkerPool(s.config.Concurrency, hashFn, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, candidates)
+
+	report := &DuplicateReport{FilesScanned: totalFiles}
+	for checksum, group := range byChecksum {
+		report.FilesHashed += len(group)
+		if len(group) < 2 {
+			continue
+		}
+
+		set := DuplicateSet{Checksum: checksum, Size: group[0].Size}
+		for _, task := range group {
+			set.Paths = append(set.Paths, task.Source)
+		}
+		sort.Strings(set.Paths)
+		report.Sets = append(report.Sets, set)
+		report.Reclaimable += set.Reclaimable()
+	}
+
+	sort.Slice(report.Sets, func(i, j int) bool {
+		if report.Sets[i].Reclaimable() != report.Sets[j].Reclaimable() {
+			return report.Sets[i].Reclaimable() > report.Sets[j].Reclaimable()
+		}
+		return report.Sets[i].Paths[0] < report.Sets[j].Paths[0]
+	})
+	return report, err
+}
@@ -1,6 +1,13 @@
 package backup
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+)
 
 type BackupError struct {
 	Op   string
@@ -15,6 +22,10 @@ func (e *BackupError) Error() string {
 	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
 }
 
+func (e *BackupError) Unwrap() error {
+	return e.Err
+}
+
 func newBackupError(op, path string, err error) error {
 	return &BackupError{
 		Op:   op,
@@ -22,3 +33,227 @@ func newBackupError(op, path string, err error) error {
 		Err:  err,
 	}
 }
+
+// ErrorOp returns the operation of the first BackupError in err's chain, or
+// "" if there is none
+func ErrorOp(err error) string {
+	var backupErr *BackupError
+	if errors.As(err, &backupErr) {
+		return backupErr.Op
+	}
+	return ""
+}
+
+// Sentinel errors for telling failures apart with errors.Is
+var (
+	ErrInvalidConfig     = errors.New("invalid configuration")
+	ErrSourceNotFound    = errors.New("source not found")
+	ErrInsufficientSpace = errors.New("insufficient free space")
+	ErrCopyFailed        = errors.New("copy failed")
+	ErrChecksumMismatch  = errors.New("checksum mismatch")
+	ErrUnreadableSource  = errors.New("unreadable source")
+	ErrCaseConflict      = errors.New("case conflict")
+	ErrHookFailed        = errors.New("hook failed")
+	ErrTooFewFiles       = errors.New("too few source files")
+	ErrAlgorithmMismatch = errors.New("checksum algorithm mismatch")
+	ErrTooManyDeletions  = errors.New("too many mirror deletions")
+	ErrFileTimeout       = errors.New("file copy timed out")
+	ErrTargetNotWritable = errors.New("target not writable")
+	ErrDirectoryConflict = errors.New("directory conflict")
+)
+
+// sentinelError tags an error with a sentinel for errors.Is while keeping
+// the original message
+type sentinelError struct {
+	err      error
+	sentinel error
+}
+
+func (e *sentinelError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sentinelError) Unwrap() []error {
+	return []error{e.err, e.sentinel}
+}
+
+func withSentinel(err, sentinel error) error {
+	return &sentinelError{err: err, sentinel: sentinel}
+}
+
+// ValidationErrors collects every problem found in a configuration so they
+// can be reported together
+type ValidationErrors []error
+
+func (v ValidationErrors) Error() string {
+	if len(v) == 1 {
+		return v[0].Error()
+	}
+	lines := make([]string, len(v))
+	for i, err := range v {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("%d configuration problems:\n%s", len(v), strings.Join(lines, "\n"))
+}
+
+func (v ValidationErrors) Unwrap() []error {
+	return v
+}
+
+// Error categories used to group failures in reports
+const (
+	CategoryPermission = "permission"
+	CategoryNotFound   = "not-found"
+	CategoryIO         = "I/O"
+	CategoryChecksum   = "checksum"
+	CategoryDirectory  = "directory-conflict"
+)
+
+// maxErrorExamples limits how many example paths are kept per category
+const maxErrorExamples = 3
+
+// categorizeError maps an error to one of the report categories
+func categorizeError(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return CategoryPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return CategoryNotFound
+	case errors.Is(err, ErrChecksumMismatch):
+		return CategoryChecksum
+	case errors.Is(err, ErrDirectoryConflict):
+		return CategoryDirectory
+	default:
+		return CategoryIO
+	}
+}
+
+// CategorySummary holds the failure count and a few example paths for a category
+type CategorySummary struct {
+	Count    int
+	Examples []string
+}
+
+// ErrorSummary groups task failures by category. It implements error so it
+// can be returned directly from the worker pool.
+type ErrorSummary struct {
+	mu         sync.Mutex
+	Categories map[string]*CategorySummary
+	paths      []string // Every failed path, beyond the examples kept per category
+}
+
+func newErrorSummary() *ErrorSummary {
+	return &ErrorSummary{
+		Categories: make(map[string]*CategorySummary),
+	}
+}
+
+// Add records a failure for the given path
+func (s *ErrorSummary) Add(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category := categorizeError(err)
+	cs, ok := s.Categories[category]
+	if !ok {
+		cs = &CategorySummary{}
+		s.Categories[category] = cs
+	}
+	cs.Count++
+	if len(cs.Examples) < maxErrorExamples {
+		cs.Examples = append(cs.Examples, path)
+	}
+	s.paths = append(s.paths, path)
+}
+
+// merge adds the failures recorded in other
+func (s *ErrorSummary) merge(other *ErrorSummary) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for category, oc := range other.Categories {
+		cs, ok := s.Categories[category]
+		if !ok {
+			cs = &CategorySummary{}
+			s.Categories[category] = cs
+		}
+		cs.Count += oc.Count
+		for _, example := range oc.Examples {
+			if len(cs.Examples) < maxErrorExamples {
+				cs.Examples = append(cs.Examples, example)
+			}
+		}
+	}
+	s.paths = append(s.paths, other.paths...)
+}
+
+// Paths returns every failed path, in the order the failures were added
+func (s *ErrorSummary) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+// Is reports the summary as a copy failure
+func (s *ErrorSummary) Is(target error) bool {
+	return target == ErrCopyFailed
+}
+
+// Total returns the number of failures across all categories
+func (s *ErrorSummary) Total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, cs := range s.Categories {
+		total += cs.Count
+	}
+	return total
+}
+
+// Counts returns the number of failures in each category
+func (s *ErrorSummary) Counts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int, len(s.Categories))
+	for category, cs := range s.Categories {
+		counts[category] = cs.Count
+	}
+	return counts
+}
+
+// Report formats the failures grouped by category, largest first
+func (s *ErrorSummary) Report() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	categories := make([]string, 0, len(s.Categories))
+	for category := range s.Categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		ci, cj := s.Categories[categories[i]], s.Categories[categories[j]]
+		if ci.Count != cj.Count {
+			return ci.Count > cj.Count
+		}
+		return categories[i] < categories[j]
+	})
+
+	var b strings.Builder
+	for _, category := range categories {
+		cs := s.Categories[category]
+		fmt.Fprintf(&b, "  %s: %d", category, cs.Count)
+		if len(cs.Examples) > 0 {
+			fmt.Fprintf(&b, " (e.g. %s)", strings.Join(cs.Examples, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (s *ErrorSummary) Error() string {
+	return fmt.Sprintf("%d files failed:\n%s", s.Total(), strings.TrimRight(s.Report(), "\n"))
+}
@@ -0,0 +1,22 @@
+// explain.go
+package backup
+
+import "fmt"
+
+// Decisions reported by explain
+const (
+	decisionCopy = "copy"
+	decisionSkip = "skip"
+)
+
+// explain records why a file is copied or skipped. With --explain the
+// reason goes to the operation log at info level; otherwise it is a debug
+// message, so the same wording appears either way.
+func (s *Service) explain(path, decision, format string, args ...any) {
+	reason := fmt.Sprintf(format, args...)
+	if s.config.Options.Explain {
+		s.logger.Info("Explain: %s %s: %s", decision, path, reason)
+		return
+	}
+	s.logger.Debug("Decision: %s %s: %s", decision, path, reason)
+}
@@ -0,0 +1,24 @@
+// failfast.go
+package backup
+
+// SetFailFast makes the pool stop at its first failed task: stop is called,
+// and should cancel the context the pool runs under, and that failure is
+// returned in place of the summary. Nil lets every task run.
+func (p *WorkerPool) SetFailFast(stop func()) {
+	p.failFast = stop
+}
+
+// failFastError is what a fail-fast pool returns: the failure that stopped
+// it, still carrying every failure recorded by then for reporting
+type failFastError struct {
+	err      error
+	failures *ErrorSummary
+}
+
+func (e *failFastError) Error() string {
+	return e.err.Error()
+}
+
+func (e *failFastError) Unwrap() []error {
+	return []error{e.err, e.failures}
+}
@@ -0,0 +1,112 @@
+// failfast_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolFailFast(t *testing.T) {
+	const count = 100
+	errBad := errors.New("bad sector")
+	tests := []struct {
+		name     string
+		failFast bool
+		wantRan  func(ran int64) bool
+	}{
+		{"every task runs", false, func(ran int64) bool { return ran == count }},
+		{"stops at the first failure", true, func(ran int64) bool { return ran < 10 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// The third task fails; the rest take a moment each
+			var ran atomic.Int64
+			pool := NewWorkerPool(2, func(task CopyTask) error {
+				ran.Add(1)
+				if task.Source == "bad" {
+					return errBad
+				}
+				time.Sleep(2 * time.Millisecond)
+				return nil
+			}, 1, 0)
+			if tt.failFast {
+				pool.SetFailFast(cancel)
+			}
+			tasks := make([]CopyTask, count)
+			for i := range tasks {
+				tasks[i].Source = fmt.Sprintf("file%03d", i)
+			}
+			tasks[2].Source = "bad"
+
+			err := pool.Execute(ctx, tasks)
+			if err == nil || !strings.Contains(err.Error(), "bad") {
+				t.Fatalf("Execute error = %v, want the failure of bad", err)
+			}
+			if !tt.wantRan(ran.Load()) {
+				t.Errorf("%d of %d tasks ran", ran.Load(), count)
+			}
+			// A stopped pool returns the failure itself rather than a summary
+			if errors.Is(err, errBad) != tt.failFast {
+				t.Errorf("error %q is the task's own failure = %v, want %v", err, !tt.failFast, tt.failFast)
+			}
+		})
+	}
+}
+
+func TestFailFastBackup(t *testing.T) {
+	files := map[string]string{"a.txt": "alpha"}
+	for i := 0; i < 30; i++ {
+		files[fmt.Sprintf("b%02d.txt", i)] = "bravo"
+	}
+	// a.txt, walked first, can't be opened
+	defer func(original func(string) (io.ReadCloser, error)) { openSource = original }(openSource)
+	openSource = func(name string) (io.ReadCloser, error) {
+		if filepath.Base(name) == "a.txt" {
+			return nil, os.ErrPermission
+		}
+		return os.Open(name)
+	}
+
+	tests := []struct {
+		failFast   bool
+		wantStatus string
+	}{
+		{false, StatusCompleted},
+		{true, StatusPartial},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("fail_fast=%v", tt.failFast), func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.Concurrency = 1
+			cfg.FailFast = tt.failFast
+			result, err := newTestService(t, cfg).BackupWithResult(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "a.txt") {
+				t.Fatalf("backup error = %v, want the a.txt failure", err)
+			}
+			if result == nil {
+				t.Fatal("no result")
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			if result.Stats.FilesFailed != 1 {
+				t.Errorf("FilesFailed = %d, want 1", result.Stats.FilesFailed)
+			}
+			copied := result.Stats.FilesBackedUp
+			if stopped := copied < len(files)/2; stopped != tt.failFast {
+				t.Errorf("%d of %d files copied, want stopped %v", copied, len(files), tt.failFast)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// filelimit.go
+package backup
+
+import "sync"
+
+// minOpenFiles is the smallest useful limit: a copy holds source and destination
+const minOpenFiles = 2
+
+// fileLimiter caps the number of file handles held open at once across all
+// workers. Handles are acquired in one step per operation, so a copy never
+// holds its source while waiting for its destination.
+type fileLimiter struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	available int
+}
+
+// newFileLimiter returns a limiter for max handles, or nil for no limit
+func newFileLimiter(max int) *fileLimiter {
+	if max <= 0 {
+		return nil
+	}
+	l := &fileLimiter{available: max}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until n handles are available. A nil limiter never blocks.
+func (l *fileLimiter) acquire(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.available < n {
+		l.cond.Wait()
+	}
+	l.available -= n
+}
+
+// release returns n handles to the limiter
+func (l *fileLimiter) release(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.available += n
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
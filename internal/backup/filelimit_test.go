@@ -0,0 +1,123 @@
+// filelimit_test.go
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFileLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int
+		workers int
+		take    int
+	}{
+		{"single handles", 3, 16, 1},
+		{"pairs", 4, 16, 2},
+		{"pairs at the minimum", minOpenFiles, 8, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newFileLimiter(tt.max)
+			var held, peak atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < tt.workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						l.acquire(tt.take)
+						now := held.Add(int64(tt.take))
+						for {
+							old := peak.Load()
+							if now <= old || peak.CompareAndSwap(old, now) {
+								break
+							}
+						}
+						runtime.Gosched()
+						held.Add(-int64(tt.take))
+						l.release(tt.take)
+					}
+				}()
+			}
+			wg.Wait()
+			if got := peak.Load(); got > int64(tt.max) {
+				t.Errorf("%d handles held at once, limit %d", got, tt.max)
+			}
+		})
+	}
+
+	// No limit never blocks
+	unlimited := newFileLimiter(0)
+	unlimited.acquire(1000)
+	unlimited.release(1000)
+}
+
+// openDataFiles counts this process's descriptors open on .txt files (or
+// their temp copies) under dir. Descriptors are listed and then resolved one
+// by one, so a pass can count a number closed and reused meanwhile twice;
+// the lower of two passes is taken to stay clear of that.
+func openDataFiles(dir string) int {
+	pass := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			return 0
+		}
+		count := 0
+		for _, entry := range entries {
+			target, err := os.Readlink(filepath.Join("/proc/self/fd", entry.Name()))
+			if err == nil && strings.HasPrefix(target, dir) && strings.HasSuffix(strings.TrimSuffix(target, copyTempSuffix), ".txt") {
+				count++
+			}
+		}
+		return count
+	}
+	return min(pass(), pass())
+}
+
+func TestMaxOpenFilesBackup(t *testing.T) {
+	if _, err := os.ReadDir("/proc/self/fd"); err != nil {
+		t.Skipf("open descriptors can't be listed: %v", err)
+	}
+	files := make(map[string]string, 400)
+	for i := 0; i < 400; i++ {
+		files[fmt.Sprintf("dir%d/file%03d.txt", i%8, i)] = fmt.Sprintf("tiny file %d", i)
+	}
+	const maxOpen = 4
+	cfg := newTestConfig(t, files)
+	cfg.Concurrency = 16
+	cfg.MaxOpenFiles = maxOpen
+	cfg.DeepDuplicateCheck = true // Hash source and target as well
+	s := newTestService(t, cfg)
+	root := filepath.Dir(cfg.SourceDirectory)
+
+	stop := make(chan struct{})
+	peaked := make(chan int)
+	go func() {
+		peak := 0
+		for {
+			select {
+			case <-stop:
+				peaked <- peak
+				return
+			default:
+				peak = max(peak, openDataFiles(root))
+			}
+		}
+	}()
+	runBackup(t, s)
+	// A second run hashes both sides of every file
+	runBackup(t, newTestService(t, cfg))
+	close(stop)
+
+	if peak := <-peaked; peak > maxOpen {
+		t.Errorf("%d files open at once, max_open_files %d", peak, maxOpen)
+	}
+}
@@ -0,0 +1,18 @@
+// filelog.go
+package backup
+
+// logFileDone records one file written by a backup. Runs log every file at
+// info level only with log_every_file; otherwise every log_sample_every-th
+// file is, and the rest are debug messages, keeping large runs' logs small
+// while warnings, errors and the summary still appear.
+func (s *Service) logFileDone(format string, args ...any) {
+	if s.config.LogEveryFile {
+		s.logger.Info(format, args...)
+		return
+	}
+	if every := int64(s.config.LogSampleEvery); every > 0 && s.filesLogged.Add(1)%every == 1%every {
+		s.logger.Info(format, args...)
+		return
+	}
+	s.logger.Debug(format, args...)
+}
@@ -0,0 +1,73 @@
+// folders.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// globMeta holds the characters that make a folders_to_backup entry a
+// filepath.Match pattern, e.g. "Photos/20*"
+const globMeta = "*?["
+
+// isFolderGlob reports whether a folders_to_backup entry is a glob pattern
+func isFolderGlob(folder string) bool {
+	return strings.ContainsAny(folder, globMeta)
+}
+
+// globBase returns the fixed directory above a pattern's first wildcard,
+// e.g. "Photos" for "Photos/20*"
+func globBase(pattern string) string {
+	return filepath.Dir(pattern[:strings.IndexAny(pattern, globMeta)])
+}
+
+// expandFolders resolves glob entries in folders_to_backup to the
+// directories they match below root, relative to it, so new matching folders
+// are picked up on every run. Plain entries are kept as written, so one that
+// doesn't exist still fails the walk. It also returns the patterns that
+// matched nothing.
+func (s *Service) expandFolders(root string) (folders []string, unmatched []string) {
+	seen := make(map[string]bool)
+	add := func(folder string) {
+		if !seen[folder] {
+			seen[folder] = true
+			folders = append(folders, folder)
+		}
+	}
+
+	for _, folder := range s.config.FoldersToBackup {
+		if !isFolderGlob(folder) {
+			add(folder)
+			continue
+		}
+		// Patterns are checked by validation, so Glob can't fail here
+		matches, _ := filepath.Glob(filepath.Join(root, folder))
+		found := false
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			relPath, err := filepath.Rel(root, match)
+			if err != nil {
+				continue
+			}
+			add(relPath)
+			found = true
+		}
+		if !found {
+			unmatched = append(unmatched, folder)
+		}
+	}
+	return folders, unmatched
+}
+
+// sourceFolders returns the folders to back up this run, warning about
+// patterns that match nothing
+func (s *Service) sourceFolders() []string {
+	folders, unmatched := s.expandFolders(s.sourceDirectory())
+	for _, pattern := range unmatched {
+		s.logger.Warn("folders_to_backup pattern %s matches no folders", pattern)
+	}
+	return folders
+}
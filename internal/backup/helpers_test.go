@@ -0,0 +1,89 @@
+// helpers_test.go
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testFolder is the folder under the source directory that test configs back up
+const testFolder = "data"
+
+// newTestConfig returns a quiet config backing up a fresh source folder to a
+// fresh target, with files written under source/data
+func newTestConfig(t testing.TB, files map[string]string) *Config {
+	t.Helper()
+	root := t.TempDir()
+
+	cfg := NewConfig()
+	cfg.SourceDirectory = filepath.Join(root, "src")
+	cfg.TargetDirectory = filepath.Join(root, "tgt")
+	cfg.FoldersToBackup = []string{testFolder}
+	cfg.Concurrency = 2
+	cfg.RetryAttempts = 1
+	cfg.RetryDelay = time.Second // the minimum; unused with a single attempt
+	cfg.Options = &Options{Quiet: true}
+
+	writeFiles(t, filepath.Join(cfg.SourceDirectory, testFolder), files)
+	return cfg
+}
+
+// newTestService creates a Service for cfg, closing its log when the test ends
+func newTestService(t testing.TB, cfg *Config) *Service {
+	t.Helper()
+	s, err := NewService(cfg)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { s.logger.Close() })
+	return s
+}
+
+// runBackup runs a backup that is expected to succeed
+func runBackup(t testing.TB, s *Service) *BackupResult {
+	t.Helper()
+	result, err := s.BackupWithResult(context.Background())
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	return result
+}
+
+// writeFiles creates each file under root, keyed by slash-separated path
+func writeFiles(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns a file's content, failing the test if it can't be read
+func readFile(t testing.TB, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// sourcePath and targetPath locate a test file by its path under testFolder
+func sourcePath(cfg *Config, name string) string {
+	return filepath.Join(cfg.SourceDirectory, testFolder, filepath.FromSlash(name))
+}
+
+func targetPath(cfg *Config, name string) string {
+	return filepath.Join(cfg.TargetDirectory, testFolder, filepath.FromSlash(name))
+}
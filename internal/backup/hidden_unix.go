@@ -0,0 +1,14 @@
+//go:build !windows
+
+// hidden_unix.go
+package backup
+
+import (
+	"os"
+	"strings"
+)
+
+// isHidden reports whether a file or directory is hidden (dot-prefixed name)
+func isHidden(path string, info os.FileInfo) bool {
+	return strings.HasPrefix(info.Name(), ".")
+}
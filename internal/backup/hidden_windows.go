@@ -0,0 +1,22 @@
+//go:build windows
+
+// hidden_windows.go
+package backup
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// isHidden reports whether a file or directory is hidden, either by a
+// dot-prefixed name or by the Windows hidden attribute
+func isHidden(path string, info os.FileInfo) bool {
+	if strings.HasPrefix(info.Name(), ".") {
+		return true
+	}
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return data.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// hooks.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Hook phases
+const (
+	HookPre  = "pre"
+	HookPost = "post"
+)
+
+// defaultHookTimeout applies when HookTimeout is not configured
+const defaultHookTimeout = 10 * time.Minute
+
+// HookResult records the outcome of a single pre- or post-backup command
+type HookResult struct {
+	Phase    string
+	Command  string
+	ExitCode int
+	Output   string
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+// runHooks executes commands in order through the system shell. Pre-backup
+// hooks stop at the first failure; post-backup hooks always all run.
+func (s *Service) runHooks(ctx context.Context, phase string, commands []string) ([]HookResult, error) {
+	timeout := s.config.HookTimeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	var results []HookResult
+	var firstErr error
+	for _, command := range commands {
+		result, err := runHook(ctx, phase, command, timeout)
+		results = append(results, result)
+
+		if err != nil {
+			s.logger.Error("Hook [%s] %q failed after %v: %v\n%s", phase, command, result.Duration, err, result.Output)
+			if firstErr == nil {
+				firstErr = newBackupError("Hook", command, withSentinel(fmt.Errorf("%s-backup command failed: %w", phase, err), ErrHookFailed))
+			}
+			if phase == HookPre {
+				break
+			}
+			continue
+		}
+		s.logger.Info("Hook [%s] %q completed in %v\n%s", phase, command, result.Duration, result.Output)
+	}
+
+	return results, firstErr
+}
+
+// runHook runs one command with a timeout and captures its combined output
+func runHook(ctx context.Context, phase, command string, timeout time.Duration) (HookResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	result := HookResult{
+		Phase:    phase,
+		Command:  command,
+		Output:   strings.TrimSpace(string(output)),
+		Duration: time.Since(start),
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		result.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		result.Error = err.Error()
+	}
+
+	return result, err
+}
@@ -0,0 +1,115 @@
+// hooks_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands below use sh")
+	}
+	tests := []struct {
+		name      string
+		phase     string
+		commands  []string
+		timeout   time.Duration
+		wantCodes []int
+		wantErr   bool
+	}{
+		{"pre succeeds", HookPre, []string{"echo one", "echo two"}, 0, []int{0, 0}, false},
+		{"pre stops at failure", HookPre, []string{"echo one", "false", "echo three"}, 0, []int{0, 1}, true},
+		{"post runs every command", HookPost, []string{"echo one", "exit 3", "echo three"}, 0, []int{0, 3, 0}, true},
+		{"timeout", HookPre, []string{"exec sleep 5"}, 100 * time.Millisecond, []int{-1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.HookTimeout = tt.timeout
+			s := newTestService(t, cfg)
+
+			results, err := s.runHooks(context.Background(), tt.phase, tt.commands)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runHooks error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrHookFailed) {
+				t.Errorf("runHooks error = %v, want ErrHookFailed", err)
+			}
+			var codes []int
+			for _, result := range results {
+				codes = append(codes, result.ExitCode)
+				if result.Phase != tt.phase {
+					t.Errorf("%q phase = %s, want %s", result.Command, result.Phase, tt.phase)
+				}
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("exit codes = %v, want %v", codes, tt.wantCodes)
+			}
+			if results[0].ExitCode == 0 && results[0].Output != "one" {
+				t.Errorf("output = %q, want %q", results[0].Output, "one")
+			}
+		})
+	}
+}
+
+func TestBackupHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands below use sh")
+	}
+	tests := []struct {
+		name    string
+		pre     []string
+		wantErr bool
+	}{
+		{"pre succeeds", []string{"echo ready"}, false},
+		{"pre fails", []string{"false"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+			marker := filepath.Join(t.TempDir(), "post-ran")
+			cfg.PreBackupCommands = tt.pre
+			cfg.PostBackupCommands = []string{"echo done > " + marker}
+			s := newTestService(t, cfg)
+
+			result, err := s.BackupWithResult(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("backup error = %v, want error %v", err, tt.wantErr)
+			}
+			// Post-backup commands run whether or not the backup did
+			if got := readFile(t, marker); strings.TrimSpace(got) != "done" {
+				t.Errorf("post hook output = %q", got)
+			}
+
+			_, statErr := os.Stat(targetPath(cfg, "a.txt"))
+			if tt.wantErr {
+				if statErr == nil {
+					t.Error("a failed pre-backup command did not stop the copy")
+				}
+				return
+			}
+			if statErr != nil {
+				t.Errorf("a.txt not backed up: %v", statErr)
+			}
+			version, err := s.GetVersion(result.VersionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var phases []string
+			for _, hook := range version.Hooks {
+				phases = append(phases, hook.Phase)
+			}
+			if strings.Join(phases, ",") != "pre,post" {
+				t.Errorf("recorded hooks %v, want pre and post", phases)
+			}
+		})
+	}
+}
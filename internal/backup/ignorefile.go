@@ -0,0 +1,71 @@
+// ignorefile.go
+package backup
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreFileName is a per-directory file of exclude patterns kept with the
+// data. Its patterns use the exclude_patterns syntax, are taken relative to
+// the directory holding the file and apply only within that subtree.
+const ignoreFileName = ".foldersitterignore"
+
+// ignoreFiles caches the parsed ignore file of each directory
+type ignoreFiles struct {
+	logger *Logger
+	mu     sync.Mutex
+	rules  map[string][]string // Directory to its patterns; nil when it has no ignore file
+}
+
+func newIgnoreFiles(logger *Logger) *ignoreFiles {
+	return &ignoreFiles{logger: logger, rules: make(map[string][]string)}
+}
+
+// patterns returns the patterns of dir's ignore file, reading it on first use
+func (f *ignoreFiles) patterns(dir string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if patterns, ok := f.rules[dir]; ok {
+		return patterns
+	}
+	patterns := f.read(filepath.Join(dir, ignoreFileName))
+	f.rules[dir] = patterns
+	return patterns
+}
+
+// read parses an ignore file: one pattern per line, blank lines and lines
+// starting with # are ignored. Invalid patterns are logged and dropped.
+func (f *ignoreFiles) read(filename string) []string {
+	file, err := os.Open(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.logger.Warn("Cannot read %s: %v", filename, err)
+		}
+		return nil
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(strings.TrimPrefix(line, "!"), "test"); err != nil {
+			f.logger.Warn("Ignoring invalid pattern %q in %s: %v", line, filename, err)
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		f.logger.Warn("Cannot read %s: %v", filename, err)
+	}
+	return patterns
+}
@@ -0,0 +1,85 @@
+// ignorefile_test.go
+package backup
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIgnoreFiles(t *testing.T) {
+	files := map[string]string{
+		"a.txt":           "a",
+		"b.log":           "b",
+		"y.tmp":           "y",
+		"cache/z.txt":     "z",
+		"sub/c.log":       "c",
+		"sub/keep.log":    "k",
+		"sub/x.tmp":       "x",
+		"sub/cache/z.txt": "z",
+	}
+	tests := []struct {
+		name    string
+		root    string            // Ignore file at the source root
+		nested  map[string]string // Ignore files by folder-relative directory
+		exclude []string          // exclude_patterns
+		want    []string
+	}{
+		{
+			name: "no ignore files",
+			want: []string{"data/a.txt", "data/b.log", "data/cache/z.txt", "data/sub/c.log",
+				"data/sub/cache/z.txt", "data/sub/keep.log", "data/sub/x.tmp", "data/y.tmp"},
+		},
+		{
+			name: "root file applies to the whole tree",
+			root: "*.log\n",
+			want: []string{"data/a.txt", "data/cache/z.txt", "data/sub/cache/z.txt", "data/sub/x.tmp", "data/y.tmp"},
+		},
+		{
+			name:   "nested file applies only to its subtree",
+			nested: map[string]string{"sub": "*.tmp\n"},
+			want: []string{"data/a.txt", "data/b.log", "data/cache/z.txt", "data/sub/.foldersitterignore",
+				"data/sub/c.log", "data/sub/cache/z.txt", "data/sub/keep.log", "data/y.tmp"},
+		},
+		{
+			name:   "nested path patterns are relative to their directory",
+			nested: map[string]string{"sub": "cache/*\n"},
+			want: []string{"data/a.txt", "data/b.log", "data/cache/z.txt", "data/sub/.foldersitterignore",
+				"data/sub/c.log", "data/sub/keep.log", "data/sub/x.tmp", "data/y.tmp"},
+		},
+		{
+			name:   "nested negation overrides the root file",
+			root:   "*.log\n",
+			nested: map[string]string{"sub": "!keep.log\n"},
+			want: []string{"data/a.txt", "data/cache/z.txt", "data/sub/.foldersitterignore",
+				"data/sub/cache/z.txt", "data/sub/keep.log", "data/sub/x.tmp", "data/y.tmp"},
+		},
+		{
+			name:    "ignore file applies after exclude_patterns",
+			exclude: []string{"*.log"},
+			nested:  map[string]string{"sub": "!c.log\n"},
+			want: []string{"data/a.txt", "data/cache/z.txt", "data/sub/.foldersitterignore", "data/sub/c.log",
+				"data/sub/cache/z.txt", "data/sub/x.tmp", "data/y.tmp"},
+		},
+		{
+			name: "comments, blank and invalid lines are skipped",
+			root: "# logs\n\n[bad\n*.tmp  \n",
+			want: []string{"data/a.txt", "data/b.log", "data/cache/z.txt", "data/sub/c.log",
+				"data/sub/cache/z.txt", "data/sub/keep.log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.ExcludePatterns = tt.exclude
+			if tt.root != "" {
+				writeFiles(t, cfg.SourceDirectory, map[string]string{ignoreFileName: tt.root})
+			}
+			for dir, content := range tt.nested {
+				writeFiles(t, sourcePath(cfg, dir), map[string]string{ignoreFileName: content})
+			}
+			if got := walkKeys(t, newTestService(t, cfg)); !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
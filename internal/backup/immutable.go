@@ -0,0 +1,55 @@
+// immutable.go
+package backup
+
+// buildImmutableIndex records the size of every file matching
+// immutable_paths in the newest completed version that wrote to the current
+// target, so those files can be skipped on the manifest's word
+func (s *Service) buildImmutableIndex() (map[string]int64, error) {
+	index := make(map[string]int64)
+	seen := make(map[string]bool)
+
+	versions := s.GetVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Status != StatusCompleted || versions[i].Archive != "" ||
+			s.versionTarget(&versions[i]) != s.config.TargetDirectory {
+			continue
+		}
+		version, err := s.GetVersion(versions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for key, metadata := range version.Files {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if s.isImmutable(key) {
+				index[key] = metadata.Size
+			}
+		}
+	}
+	return index, nil
+}
+
+// isImmutable reports whether a manifest key matches immutable_paths, which
+// use the same syntax as exclude_patterns
+func (s *Service) isImmutable(key string) bool {
+	for _, pattern := range s.config.ImmutablePaths {
+		if s.patternMatches(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustImmutable reports whether task is an immutable file already backed
+// up at its current size. Neither the source nor the target is examined
+// beyond the size the walk found.
+func (s *Service) trustImmutable(task CopyTask) bool {
+	size, ok := s.immutable[s.manifestKey(task.Source)]
+	if !ok || size != task.Size {
+		return false
+	}
+	s.explain(task.Source, decisionSkip, "immutable path already backed up at %d bytes; target not checked", size)
+	return true
+}
@@ -0,0 +1,46 @@
+// immutable_test.go
+package backup
+
+import (
+	"os"
+	"testing"
+)
+
+func TestImmutablePaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		immutable  []string
+		file       string
+		write      string // Source content written before the second run, if any
+		wantCopied bool   // Whether the second run copies the file again
+	}{
+		{"immutable and backed up", []string{"data/photos/*"}, "photos/a.jpg", "", false},
+		{"matched by name", []string{"*.jpg"}, "photos/a.jpg", "", false},
+		{"not immutable", []string{"data/photos/*"}, "notes.txt", "", true},
+		{"no immutable_paths", nil, "photos/a.jpg", "", true},
+		{"immutable but resized", []string{"data/photos/*"}, "photos/a.jpg", "alpha, re-encoded", true},
+		{"immutable and new", []string{"data/photos/*"}, "photos/new.jpg", "new", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"photos/a.jpg": "alpha", "notes.txt": "notes"})
+			cfg.ImmutablePaths = tt.immutable
+			runBackup(t, newTestService(t, cfg))
+
+			if tt.write != "" {
+				writeFiles(t, sourcePath(cfg, ""), map[string]string{tt.file: tt.write})
+			}
+			// With its target copy gone, a file is only copied again if the
+			// second run looks at the target
+			if err := os.Remove(targetPath(cfg, tt.file)); err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			runBackup(t, newTestService(t, cfg))
+
+			_, err := os.Stat(targetPath(cfg, tt.file))
+			if copied := err == nil; copied != tt.wantCopied {
+				t.Errorf("%s copied again = %v, want %v", tt.file, copied, tt.wantCopied)
+			}
+		})
+	}
+}
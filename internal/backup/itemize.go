@@ -0,0 +1,46 @@
+// itemize.go
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// itemizeCode builds an rsync-style (3.x) change code for a file about to be
+// copied, e.g. ">f+++++++++" for a new file or ">f.st......" for a size and
+// time change. It must be called before the copy replaces the destination.
+// A same-size file only reaches the copy when the skip check found different
+// content, so it is marked with "c".
+func itemizeCode(task CopyTask) string {
+	destInfo, err := os.Stat(task.Destination)
+	if err != nil {
+		return ">f+++++++++"
+	}
+
+	code := []byte(">f.........")
+	if destInfo.Size() != task.Size {
+		code[3] = 's'
+	} else {
+		code[2] = 'c'
+	}
+	if !destInfo.ModTime().Equal(task.ModTime) {
+		code[4] = 't'
+	}
+	if sourceInfo, err := os.Stat(task.Source); err == nil && sourceInfo.Mode().Perm() != destInfo.Mode().Perm() {
+		code[5] = 'p'
+	}
+	return string(code)
+}
+
+// itemize prints one rsync-style change line when itemized output is on
+func (s *Service) itemize(code, path string) {
+	if !s.config.Options.Itemize {
+		return
+	}
+	relPath, err := filepath.Rel(s.config.TargetDirectory, path)
+	if err != nil {
+		relPath = path
+	}
+	fmt.Printf("%s %s\n", code, filepath.ToSlash(relPath))
+}
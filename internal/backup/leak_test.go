@@ -0,0 +1,158 @@
+// leak_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// verifyNoLeaks fails the test if goroutines started after it was called are
+// still running once the test ends. os/signal keeps one receiver for the
+// life of the process after the first Notify.
+func verifyNoLeaks(t *testing.T) {
+	t.Helper()
+	opts := []goleak.Option{goleak.IgnoreCurrent(), goleak.IgnoreTopFunction("os/signal.signal_recv")}
+	t.Cleanup(func() { goleak.VerifyNone(t, opts...) })
+}
+
+func TestWorkerPoolNoLeaks(t *testing.T) {
+	tests := []struct {
+		name     string
+		adaptive bool
+		rampUp   time.Duration
+	}{
+		{"fixed", false, 0},
+		{"adaptive", true, 0},
+		{"ramp up", false, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyNoLeaks(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			started := make(chan struct{}, 1)
+			pool := NewWorkerPool(4, func(task CopyTask) error {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			}, 1, time.Second)
+			pool.SetRampUp(tt.rampUp)
+			if tt.adaptive {
+				pool.EnableAdaptive(nil)
+			}
+
+			// The producer stops sending without ever closing the channel
+			taskCh := make(chan CopyTask)
+			go func() {
+				select {
+				case taskCh <- CopyTask{Source: "a"}:
+				case <-ctx.Done():
+				}
+			}()
+
+			done := make(chan error, 1)
+			go func() { done <- pool.ExecuteStream(ctx, taskCh) }()
+			<-started
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("ExecuteStream did not return after cancellation")
+			}
+		})
+	}
+}
+
+func TestDeadlineIONoLeaks(t *testing.T) {
+	tests := []struct {
+		name  string
+		stall bool
+	}{
+		{"completed copy", false},
+		{"stalled read", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyNoLeaks(t)
+			stall := stallingIO{release: make(chan struct{})}
+			var src io.Reader = strings.NewReader("backup butler")
+			if tt.stall {
+				src = stall
+			}
+
+			deadline := newDeadlineIO(CopyTask{deadline: time.Now().Add(20 * time.Millisecond)})
+			_, err := io.Copy(deadline.writer(io.Discard), deadline.reader(src))
+			if tt.stall != errors.Is(err, ErrFileTimeout) {
+				t.Fatalf("copy error = %v", err)
+			}
+			deadline.close()
+			// A call stuck in the source returns when the mount recovers,
+			// and its goroutine exits then
+			close(stall.release)
+		})
+	}
+}
+
+func TestProgressDisplayNoLeaks(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel bool
+	}{
+		{"stopped", false},
+		{"cancelled", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyNoLeaks(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stop := startProgressDisplay(ctx, func() {})
+			if tt.cancel {
+				cancel()
+			}
+			stop()
+			stop() // Safe to call twice
+		})
+	}
+}
+
+func TestBackupNoLeaks(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *Config)
+		cancelled bool
+	}{
+		{"completed", nil, false},
+		{"autosave", func(cfg *Config) { cfg.AutosaveEveryFiles = 1 }, false},
+		{"adaptive", func(cfg *Config) { cfg.AdaptiveConcurrency = true }, false},
+		{"cancelled", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyNoLeaks(t)
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha", "sub/b.txt": "bravo"})
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			s := newTestService(t, cfg)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelled {
+				cancel()
+			}
+			_, err := s.BackupWithResult(ctx)
+			cancel()
+			if err != nil && !tt.cancelled {
+				t.Fatalf("backup: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// lifetime.go
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lifetimeStatsFile holds cumulative statistics in the versions directory
+const lifetimeStatsFile = "lifetime.json"
+
+// LifetimeStats accumulates statistics across every backup into a target
+type LifetimeStats struct {
+	TotalRuns        int
+	TotalFiles       int
+	FilesBackedUp    int
+	TotalBytesCopied int64
+	TotalDuration    time.Duration
+	FirstRun         time.Time
+	LastRun          time.Time
+}
+
+// LifetimeStats reads the cumulative statistics; a target with no completed
+// backups returns zero stats
+func (vm *VersionManager) LifetimeStats() (*LifetimeStats, error) {
+	filename := filepath.Join(vm.baseDir, ".versions", lifetimeStatsFile)
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return &LifetimeStats{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read lifetime stats: %w", err)
+	}
+
+	var stats LifetimeStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse lifetime stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// updateLifetimeStats adds a completed version to the cumulative statistics
+func (vm *VersionManager) updateLifetimeStats(ver *BackupVersion) error {
+	stats, err := vm.LifetimeStats()
+	if err != nil {
+		return err
+	}
+
+	stats.TotalRuns++
+	stats.TotalFiles += ver.Stats.TotalFiles
+	stats.FilesBackedUp += ver.Stats.FilesBackedUp
+	stats.TotalBytesCopied += ver.Stats.BytesTransferred
+	stats.TotalDuration += ver.Duration
+	if stats.FirstRun.IsZero() {
+		stats.FirstRun = ver.Timestamp
+	}
+	stats.LastRun = ver.Timestamp
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lifetime stats: %w", err)
+	}
+
+	filename := filepath.Join(vm.baseDir, ".versions", lifetimeStatsFile)
+	if err := writeFileAtomic(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to save lifetime stats: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it into place, so readers never see a partially written file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
@@ -25,6 +25,7 @@ type Logger struct {
 	logger   *log.Logger
 	level    LogLevel
 	basePath string
+	path     string // This run's log file
 }
 
 func NewLogger(basePath string) (*Logger, error) {
@@ -35,7 +36,7 @@ func NewLogger(basePath string) (*Logger, error) {
 	}
 
 	// Create log file with timestamp
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	timestamp := time.Now().Format(logTimeFormat)
 	logFile := filepath.Join(logDir, fmt.Sprintf("backup_%s.log", timestamp))
 
 	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
@@ -48,6 +49,7 @@ func NewLogger(basePath string) (*Logger, error) {
 		logger:   log.New(file, "", log.LstdFlags),
 		level:    InfoLevel,
 		basePath: basePath,
+		path:     logFile,
 	}, nil
 }
 
@@ -88,6 +90,12 @@ func (l *Logger) Error(format string, v ...interface{}) {
 	}
 }
 
+// Record writes a tagged line regardless of the log level, for structured
+// records such as the end-of-run summary
+func (l *Logger) Record(tag, msg string) {
+	l.log(tag, "%s", msg)
+}
+
 func (l *Logger) log(level, format string, v ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -0,0 +1,72 @@
+// logretention.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logTimeFormat is the timestamp embedded in backup_<timestamp>.log names
+const logTimeFormat = "2006-01-02_15-04-05"
+
+// CleanupLogs removes run logs beyond log_retention_count and older than
+// log_retention_days. The current run's log is never removed. It returns the
+// number of logs removed.
+func (s *Service) CleanupLogs() (int, error) {
+	keep := s.config.LogRetentionCount
+	maxAge := time.Duration(s.config.LogRetentionDays) * 24 * time.Hour
+	if keep <= 0 && maxAge <= 0 {
+		return 0, nil
+	}
+
+	logDir := filepath.Join(s.logger.basePath, "logs")
+	entries, err := os.ReadDir(logDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, newBackupError("CleanupLogs", logDir, err)
+	}
+
+	// Timestamped names sort chronologically; newest first
+	var logs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, "backup_") && strings.HasSuffix(name, ".log") {
+			logs = append(logs, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(logs)))
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for i, name := range logs {
+		path := filepath.Join(logDir, name)
+		if path == s.logger.path {
+			continue
+		}
+
+		expired := keep > 0 && i >= keep
+		if maxAge > 0 {
+			stamp := strings.TrimSuffix(strings.TrimPrefix(name, "backup_"), ".log")
+			if loggedAt, err := time.ParseInLocation(logTimeFormat, stamp, time.Local); err == nil && loggedAt.Before(cutoff) {
+				expired = true
+			}
+		}
+		if !expired {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, newBackupError("CleanupLogs", path, err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		s.logger.Info("Removed %d old log files", removed)
+	}
+	return removed, nil
+}
@@ -0,0 +1,114 @@
+// manifest.go
+package backup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ManifestCheckResult describes how the source compares to an external checksum manifest
+type ManifestCheckResult struct {
+	Checked    int      // Number of manifest entries checked
+	Mismatched []string // Source files whose checksum differs from the manifest
+	Missing    []string // Manifest entries not present in the source
+}
+
+// manifestEntry is one line of a sha256sum-style manifest
+type manifestEntry struct {
+	Checksum string
+	Path     string
+}
+
+// readManifest parses a sha256sum-style manifest ("<hash>  <path>" or
+// "<hash> *<path>"). Relative paths are resolved against the source directory.
+func (s *Service) readManifest(path string) ([]manifestEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		checksum, filePath, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"<checksum>  <path>\"", lineNum)
+		}
+		filePath = strings.TrimPrefix(strings.TrimLeft(filePath, " "), "*")
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(s.config.SourceDirectory, filePath)
+		}
+
+		entries = append(entries, manifestEntry{
+			Checksum: strings.ToLower(checksum),
+			Path:     filePath,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// CheckManifest hashes every file listed in an external manifest in parallel
+// and reports mismatches and missing files
+func (s *Service) CheckManifest(ctx context.Context, manifestPath string) (*ManifestCheckResult, error) {
+	entries, err := s.readManifest(manifestPath)
+	if err != nil {
+		return nil, newBackupError("CheckManifest", manifestPath, err)
+	}
+
+	expected := make(map[string]string, len(entries))
+	tasks := make([]CopyTask, 0, len(entries))
+	for _, entry := range entries {
+		expected[entry.Path] = entry.Checksum
+		tasks = append(tasks, CopyTask{Source: entry.Path})
+	}
+
+	var mu sync.Mutex
+	result := &ManifestCheckResult{}
+	hashFn := func(task CopyTask) error {
+		checksum, err := s.calculateChecksum(task.Source)
+		if errors.Is(err, os.ErrNotExist) {
+			mu.Lock()
+			result.Checked++
+			result.Missing = append(result.Missing, task.Source)
+			mu.Unlock()
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.Checked++
+		if checksum != expected[task.Source] {
+			s.logger.Warn("Manifest mismatch for %s", task.Source)
+			result.Mismatched = append(result.Mismatched, task.Source)
+		}
+		return nil
+	}
+
+	pool := NewWorkerPool(s.config.Concurrency, hashFn, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, tasks)
+
+	sort.Strings(result.Mismatched)
+	sort.Strings(result.Missing)
+	return result, err
+}
@@ -4,6 +4,8 @@ package backup
 import (
 	"context"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,35 +13,103 @@ import (
 
 // metrics.go
 type BackupMetrics struct {
-	mu            sync.RWMutex
-	totalFiles    int
-	filesComplete int
-	bytesComplete int64
-	filesSkipped  int
-	filesFailed   int
-	startTime     time.Time
-	quiet         bool
-	updates       chan metricsUpdate // Add this
+	mu                sync.RWMutex
+	totalFiles        int
+	filesComplete     int
+	bytesComplete     int64
+	filesSkipped      int
+	filesFailed       int
+	filesDeleted      int
+	bytesDeleted      int64
+	filesWrittenAsNew int     // Changed files written to a .new sidecar in safe mode
+	filesExisting     int     // Skipped because they already existed (--ignore-existing)
+	filesChanged      int     // Source files modified between the scan and the end of their copy
+	filesMoved        int     // Files hard-linked from an identical copy instead of copied
+	dirsCreated       int     // Destination directories created
+	dirConflicts      int     // Destination directories blocked by a file
+	bytesCopied       int64   // Bytes actually copied, excluding skipped files
+	peakMBps          float64 // Highest copy throughput seen over a sampling interval
+	startTime         time.Time
+	quiet             bool
+	updates           chan metricsUpdate      // Add this
+	errorSummary      *ErrorSummary           // Failures grouped by category
+	unreadable        []string                // Source files skipped as unreadable
+	spinnerFrame      int                     // Current frame of the indeterminate indicator
+	active            map[string]time.Time    // Files being processed, with their start times
+	targets           map[string]*TargetStats // Per-target outcomes when copying to additional targets
+	slowFiles         []SlowFile              // Copies that took longer than SlowFileThreshold
+	stopped           bool                    // Set by Stop; later updates are dropped
+	tracker           chan struct{}           // Closed when the StartTracking goroutine exits
 }
 
+// TargetStats counts the copies written to one target directory
+type TargetStats struct {
+	Target      string `json:"target"`
+	FilesCopied int    `json:"files_copied"`
+	FilesFailed int    `json:"files_failed"`
+	BytesCopied int64  `json:"bytes_copied"`
+}
+
+// SlowFile records a copy that took longer than SlowFileThreshold
+type SlowFile struct {
+	Path     string        `json:"path"`
+	Duration time.Duration `json:"duration"`
+}
+
+// spinnerFrames animate the indeterminate progress indicator
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// throughputSampleInterval is how often copy throughput is sampled for the peak
+const throughputSampleInterval = time.Second
+
+// unknownTotalBuffer sizes the updates channel when the file count isn't known up front
+const unknownTotalBuffer = 1024
+
 type metricsUpdate struct {
 	operation string
 	bytes     int64
 }
 
+// NewBackupMetrics creates metrics for a run. A totalFiles of zero or less
+// means the total is unknown and progress is shown as a spinner.
 func NewBackupMetrics(totalFiles int, quiet bool) *BackupMetrics {
+	buffer := totalFiles
+	if buffer <= 0 {
+		buffer = unknownTotalBuffer
+	}
 	return &BackupMetrics{
 		totalFiles: totalFiles,
 		startTime:  time.Now(),
 		quiet:      quiet,
-		updates:    make(chan metricsUpdate, totalFiles), // Buffered channel
+		updates:    make(chan metricsUpdate, buffer), // Buffered channel
+		active:     make(map[string]time.Time),
 	}
 }
 
+// StartTracking applies updates in a background goroutine until Stop is
+// called or ctx is cancelled
 func (m *BackupMetrics) StartTracking(ctx context.Context) {
+	m.tracker = make(chan struct{})
 	go func() {
+		defer close(m.tracker)
+		sampler := time.NewTicker(throughputSampleInterval)
+		defer sampler.Stop()
+		lastSample := time.Now()
+		var lastCopied int64
+
 		for {
 			select {
+			case now := <-sampler.C:
+				m.mu.Lock()
+				if seconds := now.Sub(lastSample).Seconds(); seconds > 0 {
+					mbps := float64(m.bytesCopied-lastCopied) / 1024 / 1024 / seconds
+					if mbps > m.peakMBps {
+						m.peakMBps = mbps
+					}
+				}
+				lastCopied = m.bytesCopied
+				lastSample = now
+				m.mu.Unlock()
 			case update, ok := <-m.updates:
 				if !ok {
 					return // Channel was closed
@@ -49,6 +119,7 @@ func (m *BackupMetrics) StartTracking(ctx context.Context) {
 				case "completed":
 					m.filesComplete++
 					m.bytesComplete += update.bytes
+					m.bytesCopied += update.bytes
 				case "skipped":
 					m.filesSkipped++
 					m.bytesComplete += update.bytes
@@ -63,28 +134,161 @@ func (m *BackupMetrics) StartTracking(ctx context.Context) {
 	}()
 }
 
-func (m *BackupMetrics) IncrementCompleted(bytes int64) {
-	select {
-	case m.updates <- metricsUpdate{"completed", bytes}:
-	default:
-		// If channel is full, don't block
+// Stop closes the updates channel and waits for the tracking goroutine to
+// apply what is still queued, so the stats are final when it returns. It is
+// safe to call more than once, and updates sent afterwards are dropped.
+func (m *BackupMetrics) Stop() {
+	m.mu.Lock()
+	if !m.stopped {
+		m.stopped = true
+		close(m.updates)
+	}
+	m.mu.Unlock()
+
+	if m.tracker != nil {
+		<-m.tracker
 	}
 }
 
-func (m *BackupMetrics) IncrementSkipped(bytes int64) {
+// send queues an update without blocking. The read lock keeps Stop from
+// closing the channel mid-send.
+func (m *BackupMetrics) send(update metricsUpdate) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.stopped {
+		return
+	}
 	select {
-	case m.updates <- metricsUpdate{"skipped", bytes}:
+	case m.updates <- update:
 	default:
 		// If channel is full, don't block
 	}
 }
 
+func (m *BackupMetrics) IncrementCompleted(bytes int64) {
+	m.send(metricsUpdate{"completed", bytes})
+}
+
+func (m *BackupMetrics) IncrementSkipped(bytes int64) {
+	m.send(metricsUpdate{"skipped", bytes})
+}
+
 func (m *BackupMetrics) IncrementFailed() {
-	select {
-	case m.updates <- metricsUpdate{"failed", 0}:
-	default:
-		// If channel is full, don't block
+	m.send(metricsUpdate{"failed", 0})
+}
+
+// RecordTarget records the outcome of writing one file to a target directory
+func (m *BackupMetrics) RecordTarget(target string, bytes int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.targets == nil {
+		m.targets = make(map[string]*TargetStats)
+	}
+	stats, ok := m.targets[target]
+	if !ok {
+		stats = &TargetStats{Target: target}
+		m.targets[target] = stats
+	}
+	if err != nil {
+		stats.FilesFailed++
+		return
+	}
+	stats.FilesCopied++
+	stats.BytesCopied += bytes
+}
+
+// RecordSlowFile notes a file whose copy exceeded the slow file threshold
+func (m *BackupMetrics) RecordSlowFile(path string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.slowFiles = append(m.slowFiles, SlowFile{Path: path, Duration: duration})
+}
+
+// SlowFiles returns the slow copies, slowest first
+func (m *BackupMetrics) SlowFiles() []SlowFile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sortedSlowFiles()
+}
+
+// sortedSlowFiles copies the slow copies slowest first; callers must hold m.mu
+func (m *BackupMetrics) sortedSlowFiles() []SlowFile {
+	slow := append([]SlowFile(nil), m.slowFiles...)
+	sort.SliceStable(slow, func(i, j int) bool { return slow[i].Duration > slow[j].Duration })
+	return slow
+}
+
+// TargetStats returns the per-target outcomes sorted by target
+func (m *BackupMetrics) TargetStats() []TargetStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sortedTargets()
+}
+
+// sortedTargets copies the per-target outcomes; callers must hold m.mu
+func (m *BackupMetrics) sortedTargets() []TargetStats {
+	stats := make([]TargetStats, 0, len(m.targets))
+	for _, target := range m.targets {
+		stats = append(stats, *target)
 	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
+
+// IncrementExisting records a file skipped by --ignore-existing. The file is
+// also counted as skipped through the updates channel.
+func (m *BackupMetrics) IncrementExisting() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesExisting++
+}
+
+// IncrementWrittenAsNew records a changed file written to a safe mode sidecar.
+// It is counted directly so the copy itself still goes through the updates
+// channel as a completed file.
+func (m *BackupMetrics) IncrementWrittenAsNew() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesWrittenAsNew++
+}
+
+// IncrementChanged records a source file that changed while it was backed
+// up. It is counted directly; the copy itself is still counted as completed.
+func (m *BackupMetrics) IncrementChanged() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesChanged++
+}
+
+// IncrementMoved records a file linked from an identical copy on the target.
+// The file itself is counted as completed through the updates channel.
+func (m *BackupMetrics) IncrementMoved() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesMoved++
+}
+
+// AddDirsCreated records destination directories created by the run
+func (m *BackupMetrics) AddDirsCreated(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dirsCreated += n
+}
+
+// IncrementDirConflict records a destination directory blocked by a file
+func (m *BackupMetrics) IncrementDirConflict() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dirConflicts++
+}
+
+// IncrementDeleted records a mirror deletion. Deletions happen after the copy
+// phase, so they are counted directly rather than through the updates channel.
+func (m *BackupMetrics) IncrementDeleted(bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesDeleted++
+	m.bytesDeleted += bytes
 }
 
 // Add method to get metrics for version manager
@@ -93,12 +297,20 @@ func (m *BackupMetrics) GetStats() BackupStats {
 	defer m.mu.RUnlock()
 
 	return BackupStats{
-		TotalFiles:       m.totalFiles,
-		FilesBackedUp:    m.filesComplete,
-		FilesSkipped:     m.filesSkipped,
-		FilesFailed:      m.filesFailed,
-		TotalBytes:       m.bytesComplete,
-		BytesTransferred: m.bytesComplete,
+		TotalFiles:        m.totalFiles,
+		FilesBackedUp:     m.filesComplete,
+		FilesSkipped:      m.filesSkipped,
+		FilesFailed:       m.filesFailed,
+		TotalBytes:        m.bytesComplete,
+		BytesTransferred:  m.bytesComplete,
+		FilesDeleted:      m.filesDeleted,
+		BytesDeleted:      m.bytesDeleted,
+		FilesWrittenAsNew: m.filesWrittenAsNew,
+		FilesExisting:     m.filesExisting,
+		FilesChanged:      m.filesChanged,
+		FilesMoved:        m.filesMoved,
+		DirsCreated:       m.dirsCreated,
+		DirConflicts:      m.dirConflicts,
 	}
 }
 
@@ -107,6 +319,14 @@ func (m *BackupMetrics) DisplayProgress() {
 		return
 	}
 
+	m.mu.RLock()
+	unknownTotal := m.totalFiles <= 0
+	m.mu.RUnlock()
+	if unknownTotal {
+		m.displaySpinner()
+		return
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -145,6 +365,27 @@ func (m *BackupMetrics) DisplayProgress() {
 	fmt.Print("\x1b[u") // Restore cursor position
 }
 
+// displaySpinner shows running counts and throughput when the total is unknown
+func (m *BackupMetrics) displaySpinner() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	frame := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
+	m.spinnerFrame++
+
+	fmt.Print("\x1b[s")     // Save cursor position
+	fmt.Print("\x1b[1000D") // Move cursor far left
+	fmt.Print("\x1b[K")     // Clear line
+	fmt.Printf("[%s] %3d copied, %3d skipped, %3d failed | %6.2f MB | %6.2f MB/s",
+		frame,
+		m.filesComplete,
+		m.filesSkipped,
+		m.filesFailed,
+		float64(m.bytesComplete)/1024/1024,
+		float64(m.bytesComplete)/time.Since(m.startTime).Seconds()/1024/1024)
+	fmt.Print("\x1b[u") // Restore cursor position
+}
+
 // metrics.go
 func (m *BackupMetrics) GetStartTime() time.Time {
 	m.mu.RLock()
@@ -166,6 +407,95 @@ func (m *BackupMetrics) IsBackupInProgress() bool {
 	return m.filesComplete > 0 || m.bytesComplete > 0
 }
 
+// StartFile marks a file as being processed
+func (m *BackupMetrics) StartFile(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active[path] = time.Now()
+}
+
+// FinishFile marks a file as no longer being processed
+func (m *BackupMetrics) FinishFile(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, path)
+}
+
+// WriteSnapshot writes a one-off progress report: counts, throughput, an ETA
+// based on the file rate so far, and the files currently being processed
+func (m *BackupMetrics) WriteSnapshot(w io.Writer) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	elapsed := time.Since(m.startTime)
+	done := m.filesComplete + m.filesSkipped + m.filesFailed
+
+	fmt.Fprintf(w, "\nProgress snapshot after %v\n", elapsed.Round(time.Second))
+	if m.totalFiles > 0 {
+		fmt.Fprintf(w, "  Files: %d of %d (%d copied, %d skipped, %d failed)\n",
+			done, m.totalFiles, m.filesComplete, m.filesSkipped, m.filesFailed)
+	} else {
+		fmt.Fprintf(w, "  Files: %d so far, total still being counted (%d copied, %d skipped, %d failed)\n",
+			done, m.filesComplete, m.filesSkipped, m.filesFailed)
+	}
+	fmt.Fprintf(w, "  Data: %.2f MB (%.2f MB copied)\n",
+		float64(m.bytesComplete)/1024/1024, float64(m.bytesCopied)/1024/1024)
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		fmt.Fprintf(w, "  Throughput: %.2f MB/s\n", float64(m.bytesCopied)/1024/1024/seconds)
+	}
+	if m.totalFiles > 0 && done > 0 && done < m.totalFiles {
+		eta := time.Duration(float64(elapsed) / float64(done) * float64(m.totalFiles-done))
+		fmt.Fprintf(w, "  ETA: %v\n", eta.Round(time.Second))
+	}
+
+	if len(m.active) > 0 {
+		paths := make([]string, 0, len(m.active))
+		for path := range m.active {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		fmt.Fprintf(w, "  In progress:\n")
+		for _, path := range paths {
+			fmt.Fprintf(w, "    %s (%v)\n", path, time.Since(m.active[path]).Round(time.Millisecond))
+		}
+	}
+}
+
+// SetTotalFiles fixes the file total once it becomes known, switching the
+// progress display from the spinner to a progress bar
+func (m *BackupMetrics) SetTotalFiles(total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totalFiles = total
+}
+
+// PeakMBps returns the highest sampled copy throughput. Runs shorter than one
+// sampling interval fall back to the overall average.
+func (m *BackupMetrics) PeakMBps() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.peakMBps == 0 {
+		if seconds := time.Since(m.startTime).Seconds(); seconds > 0 {
+			return float64(m.bytesCopied) / 1024 / 1024 / seconds
+		}
+	}
+	return m.peakMBps
+}
+
+// SetErrorSummary attaches the categorized failures for the final report
+func (m *BackupMetrics) SetErrorSummary(summary *ErrorSummary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errorSummary = summary
+}
+
+// SetUnreadable records the source files skipped by the readability scan
+func (m *BackupMetrics) SetUnreadable(paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unreadable = paths
+}
+
 // Add this to metrics.go
 func (m *BackupMetrics) DisplayFinalSummary() {
 	if m.quiet {
@@ -182,4 +512,51 @@ func (m *BackupMetrics) DisplayFinalSummary() {
 		m.filesSkipped,
 		m.filesFailed,
 		float64(m.bytesComplete)/1024/1024)
+	if m.filesDeleted > 0 {
+		fmt.Printf("Files deleted: %d (%.2f MB reclaimed)\n",
+			m.filesDeleted, float64(m.bytesDeleted)/1024/1024)
+	}
+	if m.filesExisting > 0 {
+		fmt.Printf("Skipped as already existing: %d\n", m.filesExisting)
+	}
+	if len(m.targets) > 1 {
+		fmt.Printf("\nPer target:\n")
+		for _, stats := range m.sortedTargets() {
+			fmt.Printf("  %s: %d copied (%.2f MB), %d failed\n",
+				stats.Target, stats.FilesCopied, float64(stats.BytesCopied)/1024/1024, stats.FilesFailed)
+		}
+	}
+	if m.filesWrittenAsNew > 0 {
+		fmt.Printf("Changed files written as .new for review (safe mode): %d\n", m.filesWrittenAsNew)
+	}
+	if m.filesMoved > 0 {
+		fmt.Printf("Moved files linked instead of copied: %d\n", m.filesMoved)
+	}
+	if m.dirsCreated > 0 {
+		fmt.Printf("Directories created: %d\n", m.dirsCreated)
+	}
+	if m.dirConflicts > 0 {
+		fmt.Printf("Directories blocked by a file (contents not copied): %d\n", m.dirConflicts)
+	}
+	if m.filesChanged > 0 {
+		fmt.Printf("Files changed during the backup (copies may be inconsistent): %d\n", m.filesChanged)
+	}
+
+	if len(m.slowFiles) > 0 {
+		fmt.Printf("\nSlow files: %d\n", len(m.slowFiles))
+		for _, slow := range m.sortedSlowFiles() {
+			fmt.Printf("  %s (%v)\n", slow.Path, slow.Duration.Round(time.Millisecond))
+		}
+	}
+
+	if len(m.unreadable) > 0 {
+		fmt.Printf("\nUnreadable source files (skipped): %d\n", len(m.unreadable))
+		for _, path := range m.unreadable {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+
+	if m.errorSummary != nil && m.errorSummary.Total() > 0 {
+		fmt.Printf("\nFailures by category:\n%s", m.errorSummary.Report())
+	}
 }
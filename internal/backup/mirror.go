@@ -0,0 +1,201 @@
+// mirror.go
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// deleteCandidate is a target file that no longer exists in the source
+type deleteCandidate struct {
+	Path string
+	Size int64
+}
+
+// findDeletions walks each backed-up folder on the target and returns the files
+// that are no longer in the source, their total size, and how many files
+// were considered. A file is kept if the source walk found it, whether or not
+// it was queued for copying. Excluded and skipped hidden names are left alone
+// so an exclusion never causes deletions on its own, as are the target's own
+// version history, logs, trash and quarantine. When the walk couldn't read
+// part of the source, nothing is deleted.
+func (s *Service) findDeletions(tasks []CopyTask) ([]deleteCandidate, int64, int, error) {
+	if len(s.unreadable) > 0 {
+		s.logger.Warn("Skipping mirror deletions: %d source paths could not be read", len(s.unreadable))
+		return nil, 0, 0, nil
+	}
+
+	expected := make(map[string]bool, len(tasks)+len(s.withheld))
+	for _, task := range tasks {
+		expected[task.Destination] = true
+	}
+	for _, destination := range s.withheld {
+		expected[destination] = true
+	}
+
+	var candidates []deleteCandidate
+	var totalSize int64
+	existing := 0
+
+	// A pattern covers the folders it matches on the target too, so a
+	// folder removed from the source is removed from the target
+	folders, _ := s.expandFolders(s.sourceDirectory())
+	targetFolders, _ := s.expandFolders(s.config.TargetDirectory)
+	for _, folder := range targetFolders {
+		if !slices.Contains(folders, folder) {
+			folders = append(folders, folder)
+		}
+	}
+
+	for _, folder := range folders {
+		dstPath := filepath.Join(s.config.TargetDirectory, folder)
+
+		err := filepath.Walk(dstPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == dstPath {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if info.IsDir() && s.reservedTargetDir(path) {
+				return filepath.SkipDir
+			}
+
+			hidden := s.config.SkipHidden && path != dstPath && isHidden(path, info)
+			if hidden || s.isExcludedPath(s.config.TargetDirectory, path) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if s.isExcluded(s.config.TargetDirectory, path) {
+				// Keep walking when "!" patterns may re-include files inside
+				if info.IsDir() && !s.hasIncludeOverrides() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !info.IsDir() {
+				existing++
+			}
+			// Partial copies kept by on_read_error are left for recovery, and
+			// metadata sidecars go with their file
+			if !info.IsDir() && !expected[path] && !strings.HasSuffix(path, partialCopySuffix) && !isSidecarOf(path, expected) {
+				candidates = append(candidates, deleteCandidate{Path: path, Size: info.Size()})
+				totalSize += info.Size()
+			}
+			return nil
+		})
+
+		if err != nil {
+			return nil, 0, 0, newBackupError("FindDeletions", dstPath, err)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Path < candidates[j].Path
+	})
+	return candidates, totalSize, existing, nil
+}
+
+// checkDeleteLimit refuses a mirror pass that would delete more than
+// max_delete_percent of the target's files, which points at a source problem
+// rather than ordinary churn, unless --force was given
+func (s *Service) checkDeleteLimit(deletions, existing int) error {
+	if s.config.MaxDeletePercent <= 0 || s.config.Options.Force || existing == 0 {
+		return nil
+	}
+	percent := float64(deletions) / float64(existing) * 100
+	if percent <= s.config.MaxDeletePercent {
+		return nil
+	}
+	return newBackupError("MirrorDeletions", s.config.TargetDirectory, withSentinel(
+		fmt.Errorf("would delete %d of %d target files (%.1f%%), more than max_delete_percent (%g%%); "+
+			"check the source or re-run with --force", deletions, existing, percent, s.config.MaxDeletePercent),
+		ErrTooManyDeletions))
+}
+
+// mirrorDeletions removes target files that no longer exist in the source and
+// prunes any directories left empty, stopping at the folder root
+func (s *Service) mirrorDeletions(ctx context.Context, tasks []CopyTask) error {
+	candidates, _, existing, err := s.findDeletions(tasks)
+	if err != nil {
+		return err
+	}
+	if err := s.checkDeleteLimit(len(candidates), existing); err != nil {
+		return err
+	}
+
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if s.config.DeleteToTrash {
+			trashPath, err := s.moveToTrash(candidate.Path)
+			if err != nil {
+				s.logger.Error("Failed to trash %s: %v", candidate.Path, err)
+				continue
+			}
+			s.logger.Info("Trashed %s -> %s (%.2f MB)", candidate.Path, trashPath, float64(candidate.Size)/1024/1024)
+		} else {
+			if err := os.Remove(candidate.Path); err != nil {
+				s.logger.Error("Failed to delete %s: %v", candidate.Path, err)
+				continue
+			}
+			s.logger.Info("Deleted %s (%.2f MB)", candidate.Path, float64(candidate.Size)/1024/1024)
+		}
+		s.metrics.IncrementDeleted(candidate.Size)
+		s.itemize("*deleting  ", candidate.Path)
+
+		s.pruneEmptyDirs(filepath.Dir(candidate.Path))
+	}
+
+	return nil
+}
+
+// pruneEmptyDirs removes dir and its parents while they are empty, never
+// removing a backed-up folder root, a reserved directory or anything above
+// them. A folder matched
+// by a pattern only in the target is no longer backed up and may go; the
+// directory holding the pattern's matches stays.
+func (s *Service) pruneEmptyDirs(dir string) {
+	folders, _ := s.expandFolders(s.sourceDirectory())
+	roots := make(map[string]bool, len(folders))
+	for _, folder := range folders {
+		roots[filepath.Join(s.config.TargetDirectory, folder)] = true
+	}
+	for _, folder := range s.config.FoldersToBackup {
+		if isFolderGlob(folder) {
+			roots[filepath.Join(s.config.TargetDirectory, globBase(folder))] = true
+		}
+	}
+
+	for !roots[dir] && !s.reservedTargetDir(dir) && dir != s.config.TargetDirectory && dir != filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		s.logger.Debug("Removed empty directory %s", dir)
+		dir = filepath.Dir(dir)
+	}
+}
+
+// reservedTargetDir reports whether dir holds the backup's own bookkeeping
+// rather than backed-up files. These lie inside the mirrored tree when "." is
+// among the folders to back up.
+func (s *Service) reservedTargetDir(dir string) bool {
+	switch dir {
+	case filepath.Join(s.versioner.baseDir, ".versions"),
+		filepath.Join(s.logger.basePath, "logs"),
+		filepath.Join(s.config.TargetDirectory, trashDir),
+		filepath.Join(s.config.TargetDirectory, quarantineDir):
+		return true
+	}
+	return false
+}
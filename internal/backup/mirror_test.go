@@ -0,0 +1,115 @@
+// mirror_test.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMirrorDeletions(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    map[string]string
+		target    map[string]string // Backups already on the target
+		configure func(t *testing.T, cfg *Config)
+		kept      []string
+		deleted   []string
+	}{
+		{
+			name:    "file removed from the source",
+			source:  map[string]string{"a.txt": "alpha"},
+			target:  map[string]string{"a.txt": "alpha", "gone.txt": "old"},
+			kept:    []string{"a.txt"},
+			deleted: []string{"gone.txt"},
+		},
+		{
+			name:   "zero-byte file skipped by zero_byte_files",
+			source: map[string]string{"a.txt": "alpha", "empty.txt": ""},
+			target: map[string]string{"a.txt": "alpha", "empty.txt": "earlier content", "gone.txt": "old"},
+			configure: func(t *testing.T, cfg *Config) {
+				cfg.ZeroByteFiles = ZeroByteSkip
+			},
+			kept:    []string{"a.txt", "empty.txt"},
+			deleted: []string{"gone.txt"},
+		},
+		{
+			name:   "unreadable source skips every deletion",
+			source: map[string]string{"a.txt": "alpha"},
+			target: map[string]string{"a.txt": "alpha", "link": "earlier content", "gone.txt": "old"},
+			configure: func(t *testing.T, cfg *Config) {
+				// A dangling symlink can't be opened, even by root
+				if err := os.Symlink("missing", sourcePath(cfg, "link")); err != nil {
+					t.Skipf("symlinks unavailable: %v", err)
+				}
+				cfg.CheckReadable = true
+			},
+			kept: []string{"a.txt", "link", "gone.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.source)
+			cfg.MirrorMode = true
+			writeFiles(t, filepath.Join(cfg.TargetDirectory, testFolder), tt.target)
+			if tt.configure != nil {
+				tt.configure(t, cfg)
+			}
+			s := newTestService(t, cfg)
+			runBackup(t, s)
+
+			for _, name := range tt.kept {
+				if _, err := os.Stat(targetPath(cfg, name)); err != nil {
+					t.Errorf("%s was deleted from the target: %v", name, err)
+				}
+			}
+			for _, name := range tt.deleted {
+				if _, err := os.Stat(targetPath(cfg, name)); !os.IsNotExist(err) {
+					t.Errorf("%s is still on the target", name)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorWholeSourceKeepsBookkeeping(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha", "gone.txt": "old"})
+	cfg.FoldersToBackup = []string{"."}
+	cfg.MirrorMode = true
+	cfg.DeleteToTrash = true
+	first := runBackup(t, newTestService(t, cfg))
+
+	quarantined := filepath.Join(cfg.TargetDirectory, quarantineDir, "earlier", "b.txt")
+	writeFiles(t, filepath.Dir(quarantined), map[string]string{"b.txt": "corrupt"})
+	if err := os.Remove(sourcePath(cfg, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// Version IDs are named to the second
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	second := runBackup(t, newTestService(t, cfg))
+
+	if _, err := os.Stat(targetPath(cfg, "gone.txt")); !os.IsNotExist(err) {
+		t.Error("gone.txt is still on the target")
+	}
+	if _, err := os.Stat(targetPath(cfg, "a.txt")); err != nil {
+		t.Errorf("a.txt was deleted from the target: %v", err)
+	}
+	// A fresh service reads the version history as saved
+	s := newTestService(t, cfg)
+	for _, id := range []string{first.VersionID, second.VersionID} {
+		if _, err := s.GetVersion(id); err != nil {
+			t.Errorf("version %s: %v", id, err)
+		}
+	}
+	if logs := dirNames(t, filepath.Join(cfg.TargetDirectory, "logs")); len(logs) == 0 {
+		t.Error("the logs were deleted")
+	}
+	if _, err := os.Stat(quarantined); err != nil {
+		t.Errorf("the quarantined copy was deleted: %v", err)
+	}
+	trashed := filepath.Join(cfg.TargetDirectory, trashDir, second.VersionID, testFolder, "gone.txt")
+	if got := readFile(t, trashed); got != "old" {
+		t.Errorf("trashed gone.txt = %q, want %q", got, "old")
+	}
+}
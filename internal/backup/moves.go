@@ -0,0 +1,132 @@
+// moves.go
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// moveCandidate is a file already on the target that a new destination may
+// be an identical, renamed copy of
+type moveCandidate struct {
+	path     string // Location on the target
+	key      string // Target-relative path, as recorded in MovedFrom
+	checksum string
+}
+
+// moveIndex finds files already on the target by size, then checksum
+type moveIndex struct {
+	bySize map[int64][]moveCandidate
+}
+
+// buildMoveIndex indexes every file with a recorded checksum across the
+// version history, newest entry per path first, so files renamed in the
+// source can be linked instead of copied again
+func (s *Service) buildMoveIndex() (*moveIndex, error) {
+	index := &moveIndex{bySize: make(map[int64][]moveCandidate)}
+	seen := make(map[string]bool)
+
+	versions := s.GetVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Archive != "" {
+			continue
+		}
+		version, err := s.GetVersion(versions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for key, metadata := range version.Files {
+			targetKey := key
+			if metadata.TargetKey != "" {
+				targetKey = metadata.TargetKey
+			}
+			if seen[targetKey] {
+				continue
+			}
+			seen[targetKey] = true
+			if metadata.Checksum == "" {
+				continue
+			}
+			index.bySize[metadata.Size] = append(index.bySize[metadata.Size], moveCandidate{
+				path:     targetPathFor(s.config.TargetDirectory, key, metadata),
+				key:      targetKey,
+				checksum: metadata.Checksum,
+			})
+		}
+	}
+	return index, nil
+}
+
+// linkMovedFile hard-links a missing destination to an identical file
+// already on the target. It reports whether it did; any failure to link
+// just means the file is copied as usual.
+func (s *Service) linkMovedFile(task CopyTask) bool {
+	candidates := s.moves.bySize[task.Size]
+	if len(candidates) == 0 {
+		return false
+	}
+	if _, err := os.Lstat(task.Destination); !os.IsNotExist(err) {
+		return false
+	}
+
+	checksum, err := s.calculateChecksum(task.Source)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range candidates {
+		if candidate.checksum != checksum || candidate.path == task.Destination {
+			continue
+		}
+		if !s.candidateIntact(candidate, task.Size) {
+			continue
+		}
+
+		if err := s.prepareDestDir(filepath.Dir(task.Destination)); err != nil {
+			return false
+		}
+		if err := os.Link(candidate.path, task.Destination); err != nil {
+			s.logger.Debug("Cannot link %s to %s, copying instead: %v", task.Destination, candidate.path, err)
+			return false
+		}
+		s.logFileDone("Linked %s to identical %s instead of copying", task.Destination, candidate.path)
+		s.explain(task.Source, decisionCopy, "moved, linked to identical %s", candidate.path)
+
+		if s.versioner != nil {
+			metadata := s.fileMetadata(task)
+			metadata.ModTime = time.Now()
+			metadata.Checksum = checksum
+			metadata.MovedFrom = candidate.key
+			s.recordFile(metadata)
+		}
+		return true
+	}
+	return false
+}
+
+// candidateIntact checks that a candidate still holds what its manifest
+// says: by size, and by checksum under deep_duplicate_check
+func (s *Service) candidateIntact(candidate moveCandidate, size int64) bool {
+	info, err := os.Stat(candidate.path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return false
+	}
+	if !s.config.DeepDuplicateCheck {
+		return true
+	}
+	checksum, err := s.calculateChecksum(candidate.path)
+	if err != nil {
+		return false
+	}
+	return checksum == candidate.checksum
+}
+
+// String describes the index size for logging
+func (m *moveIndex) String() string {
+	count := 0
+	for _, candidates := range m.bySize {
+		count += len(candidates)
+	}
+	return fmt.Sprintf("%d files", count)
+}
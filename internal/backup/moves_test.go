@@ -0,0 +1,86 @@
+// moves_test.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectMoves(t *testing.T) {
+	files := map[string]string{"old/a.jpg": "alpha", "old/b.jpg": "bravo"}
+	tests := []struct {
+		name       string
+		detect     bool
+		deepCheck  bool
+		damage     func(t *testing.T, cfg *Config) // Between the runs
+		wantLinked bool
+	}{
+		{name: "disabled", detect: false},
+		{name: "renamed folder linked", detect: true, wantLinked: true},
+		{name: "renamed and edited", detect: true, damage: func(t *testing.T, cfg *Config) {
+			writeFiles(t, sourcePath(cfg, "new"), map[string]string{"a.jpg": "ALPHA", "b.jpg": "BRAVO"})
+		}},
+		{name: "earlier copy resized", detect: true, damage: func(t *testing.T, cfg *Config) {
+			writeFiles(t, targetPath(cfg, "old"), map[string]string{"a.jpg": "alpha!", "b.jpg": "bravo!"})
+		}},
+		// Same size, so only deep_duplicate_check notices the old copy changed
+		{name: "earlier copy altered, deep check", detect: true, deepCheck: true, damage: func(t *testing.T, cfg *Config) {
+			writeFiles(t, targetPath(cfg, "old"), map[string]string{"a.jpg": "ALPHA", "b.jpg": "BRAVO"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.DetectMoves = tt.detect
+			cfg.DeepDuplicateCheck = tt.deepCheck
+			runBackup(t, newTestService(t, cfg))
+
+			if err := os.Rename(sourcePath(cfg, "old"), sourcePath(cfg, "new")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.damage != nil {
+				tt.damage(t, cfg)
+			}
+			result := runBackup(t, newTestService(t, cfg))
+
+			wantMoved := 0
+			if tt.wantLinked {
+				wantMoved = len(files)
+			}
+			if result.Stats.FilesMoved != wantMoved {
+				t.Errorf("FilesMoved = %d, want %d", result.Stats.FilesMoved, wantMoved)
+			}
+			// A fresh service reads the manifest as saved
+			version, err := newTestService(t, cfg).GetVersion(result.VersionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a.jpg", "b.jpg"} {
+				newCopy := targetPath(cfg, filepath.Join("new", name))
+				if got, want := readFile(t, newCopy), readFile(t, sourcePath(cfg, filepath.Join("new", name))); got != want {
+					t.Errorf("new/%s = %q, want %q", name, got, want)
+				}
+				newInfo, err := os.Stat(newCopy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				oldInfo, err := os.Stat(targetPath(cfg, filepath.Join("old", name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if linked := os.SameFile(newInfo, oldInfo); linked != tt.wantLinked {
+					t.Errorf("new/%s linked to old/%s = %v, want %v", name, name, linked, tt.wantLinked)
+				}
+
+				wantFrom := ""
+				if tt.wantLinked {
+					wantFrom = testFolder + "/old/" + name
+				}
+				if got := version.Files[testFolder+"/new/"+name].MovedFrom; got != wantFrom {
+					t.Errorf("new/%s MovedFrom = %q, want %q", name, got, wantFrom)
+				}
+			}
+		})
+	}
+}
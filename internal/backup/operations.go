@@ -3,80 +3,418 @@ package backup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Backup runs a backup and reports how it went through the returned error.
+// Callers that want the run's figures use BackupWithResult.
 func (s *Service) Backup(ctx context.Context) error {
-	// Create backup tasks
-	tasks, totalFiles, err := s.createTasks()
+	_, err := s.BackupWithResult(ctx)
+	return err
+}
+
+// BackupWithResult runs a backup and returns its outcome: the version,
+// status, stats, duration and the files that failed. The result is nil only
+// when the run stopped before copying began; otherwise it is returned
+// alongside any error, so a partial run can still be inspected.
+func (s *Service) BackupWithResult(ctx context.Context) (*BackupResult, error) {
+	if err := s.requireWritableTargets(); err != nil {
+		return nil, err
+	}
+
+	// Record the algorithm so later runs can't mix in a different one
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return nil, err
+	}
+	if err := s.versioner.SetChecksumAlgorithm(s.configuredAlgorithm()); err != nil {
+		return nil, newBackupError("Backup", "", err)
+	}
+
+	// Run pre-backup hooks; post-backup hooks run however the backup ends
+	var hookResults []HookResult
+	postHooksDone := false
+	runPostHooks := func() {
+		if postHooksDone {
+			return
+		}
+		postHooksDone = true
+		results, _ := s.runHooks(context.WithoutCancel(ctx), HookPost, s.config.PostBackupCommands)
+		hookResults = append(hookResults, results...)
+	}
+	defer runPostHooks()
+
+	preResults, err := s.runHooks(ctx, HookPre, s.config.PreBackupCommands)
+	hookResults = append(hookResults, preResults...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Back up from a read-only snapshot of the source when requested
+	if s.config.UseSnapshot {
+		snapshot, err := s.takeSnapshot(ctx)
+		if err != nil {
+			s.logger.Warn("Snapshot unavailable, copying from the live source: %v", err)
+		} else {
+			s.logger.Info("Backing up from %s snapshot %s", snapshot.kind, snapshot.path)
+			s.snapshot = snapshot
+			defer func() {
+				if err := snapshot.release(context.WithoutCancel(ctx)); err != nil {
+					s.logger.Error("Failed to release snapshot %s: %v", snapshot.path, err)
+				}
+				s.snapshot = nil
+			}()
+		}
+	}
+
+	// Build the task list up front only when a feature needs all of it;
+	// otherwise tasks are streamed to the workers as the walk finds them
+	streaming := s.canStreamTasks()
+	var tasks []CopyTask
+	totalFiles := 0
+	if !streaming {
+		if tasks, totalFiles, err = s.createTasks(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Index what is already on the target so renamed files can be linked
+	if s.config.DetectMoves {
+		moves, err := s.buildMoveIndex()
+		if err != nil {
+			return nil, newBackupError("Backup", s.config.TargetDirectory, err)
+		}
+		s.logger.Info("Move detection indexed %v on the target", moves)
+		s.moves = moves
+		defer func() { s.moves = nil }()
+	}
+
+	// Files under immutable_paths that are already backed up are skipped
+	// without looking at the target
+	if len(s.config.ImmutablePaths) > 0 {
+		index, err := s.buildImmutableIndex()
+		if err != nil {
+			return nil, newBackupError("Backup", s.config.TargetDirectory, err)
+		}
+		s.immutable = index
+		defer func() { s.immutable = nil }()
+	}
+
+	// With --since-last, files unmodified since the previous backup are
+	// skipped without looking at the target
+	if s.config.Options.SinceLast {
+		index, err := s.buildSinceLastIndex()
+		if err != nil {
+			return nil, newBackupError("Backup", s.config.TargetDirectory, err)
+		}
+		if index == nil {
+			s.logger.Warn("--since-last: no completed backup of %s yet; checking every file", s.config.TargetDirectory)
+		} else {
+			s.logger.Info("--since-last: trusting files unmodified since version %s", index.base)
+			s.sinceLast = index
+			defer func() { s.sinceLast = nil }()
+		}
+	}
+
+	// Recognize files that only grew, so verify can check just the new bytes
+	if s.config.IncrementalVerify && !s.archiveMode() {
+		index, err := s.buildChecksumIndex()
+		if err != nil {
+			return nil, newBackupError("Backup", s.config.TargetDirectory, err)
+		}
+		s.appendIndex = index
+		defer func() { s.appendIndex = nil }()
+	}
+
+	// Each snapshot links unchanged files to the one before it
+	if s.config.SnapshotMode {
+		if previous := s.previousSnapshot(); previous != "" {
+			s.logger.Info("Linking unchanged files to previous snapshot %s", previous)
+			s.linkDest = previous
+			defer func() { s.linkDest = "" }()
+		}
+	}
+
+	if s.config.Options.Diagnostics {
+		s.diag = &diagnostics{}
+		defer func() { s.diag = nil }()
+	}
+
+	// on_read_error fail stops the run at the first source that can't be
+	// read, and fail_fast at the first file that fails for any reason
+	if s.config.OnReadError == ReadErrorFail || s.config.FailFast {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		s.cancelRun = cancel
+		defer func() { s.cancelRun = nil }()
+	}
+
+	// Stop the run cleanly if the target runs low on space
+	var stopSpaceMonitor func() error
+	if s.config.MinFreeSpace > 0 {
+		if err := s.checkFreeSpace(); err != nil {
+			return nil, err
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		stopSpaceMonitor = s.startSpaceMonitor(ctx, cancel)
 	}
 
 	if !s.config.Options.Quiet {
-		fmt.Printf("Starting backup of %d files...\n", totalFiles)
+		if streaming {
+			fmt.Printf("Starting backup while scanning the source...\n")
+		} else {
+			fmt.Printf("Starting backup of %d files...\n", totalFiles)
+		}
 	}
 
 	// Initialize metrics and start tracking
 	s.metrics = NewBackupMetrics(totalFiles, s.config.Options.Quiet)
-	s.metrics.StartTracking(ctx)
+	s.metrics.SetUnreadable(s.unreadable)
+	// Stop ends tracking, so a stopped run still counts the failure that
+	// stopped it
+	s.metrics.StartTracking(context.WithoutCancel(ctx))
+	defer s.metrics.Stop()
+
+	// Print a progress snapshot on SIGUSR1, even in quiet mode
+	stopProgressSignal := s.startProgressSignal()
+	defer stopProgressSignal()
+
+	// Start new backup version, or continue an interrupted one. Only pending
+	// tasks are executed; mirror mode still needs the full task list.
+	pending := tasks
+	var version *BackupVersion
+	var partial *BackupVersion
+	if s.config.Options.Resume {
+		if partial, err = s.versioner.LoadPartialVersion(); err != nil {
+			s.logger.Warn("Cannot resume: %v", err)
+		} else if partial == nil {
+			s.logger.Warn("No interrupted backup to resume")
+		}
+	}
+	if partial != nil {
+		var keep map[string]FileMetadata
+		keep, pending = s.resumableFiles(partial, tasks)
+		version = s.versioner.ResumeVersion(partial, s.config, keep)
+		for _, metadata := range keep {
+			s.metrics.IncrementSkipped(metadata.Size)
+		}
+		s.logger.Info("Resuming version %s: %d files already copied", partial.ID, len(keep))
+	} else {
+		version = s.versioner.StartNewVersion(s.config)
+	}
+	if s.snapshot != nil {
+		version.Snapshot = s.snapshot.kind
+	}
+	if s.sinceLast != nil {
+		version.SinceLast = s.sinceLast.base
+	}
 
-	// Start new backup version
-	s.versioner.StartNewVersion(s.config)
+	// Archive targets get a new archive holding every file of the version
+	if s.archiveMode() {
+		name := s.archiveName(version.ID)
+		archive, archiveErr := newArchiveWriter(filepath.Join(s.config.TargetDirectory, name), s.config.TargetFormat == TargetTarGz, s.config.CompressionLevel, s.config.Fsync)
+		if archiveErr != nil {
+			return nil, newBackupError("Backup", s.config.TargetDirectory, archiveErr)
+		}
+		s.archive = archive
+		version.Archive = name
+	}
 
-	// Create a done channel for the display goroutine
-	done := make(chan struct{})
-	defer close(done)
+	// Periodically save the in-progress version so a crash loses little
+	stopAutosave := func() {}
+	if s.config.AutosaveInterval > 0 || s.config.AutosaveEveryFiles > 0 {
+		stopAutosave = s.startAutosave(s.config.AutosaveInterval, s.config.AutosaveEveryFiles)
+	}
 
-	// Start progress display in a separate goroutine
-	if !s.config.Options.Quiet {
-		go func() {
-			ticker := time.NewTicker(200 * time.Millisecond)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ticker.C:
-					s.metrics.DisplayProgress()
-				case <-done:
-					s.metrics.DisplayProgress() // One final update
-					return
-				case <-ctx.Done():
-					return
-				}
-			}
-		}()
+	// Start progress display in a separate goroutine; itemized lines replace it
+	stopProgress := func() {}
+	if !s.config.Options.Quiet && !s.config.Options.Itemize {
+		stopProgress = startProgressDisplay(ctx, s.metrics.DisplayProgress)
+	}
+	defer stopProgress()
+
+	// File trees get each destination directory created once, ahead of the
+	// copies, so a file in the way is reported before its files fail
+	if !s.archiveMode() {
+		s.dirs = newDestDirs()
+		defer func() { s.dirs = nil }()
 	}
 
+	// Hashing started by workers stops when the run is cancelled
+	s.runCtx = ctx
+	defer func() { s.runCtx = nil }()
+
 	// Execute backup
-	err = s.pool.Execute(ctx, tasks)
+	var walkErr error
+	if streaming {
+		taskCh, wait := s.streamTasks(ctx, s.config.MirrorMode)
+		err = s.runPipeline(ctx, taskCh)
+		tasks, walkErr = wait()
+	} else {
+		if s.dirs != nil {
+			s.prepareDestDirs(pending)
+		}
+		err = s.runPipeline(ctx, taskQueue(pending))
+	}
+	stopAutosave()
+	if s.config.AdaptiveConcurrency {
+		s.logger.Info("Adaptive concurrency finished at %d workers", s.pool.Workers())
+	}
+	var archiveErr error
+	if s.archive != nil {
+		if abandoned := s.archive.abandoned; len(abandoned) > 0 {
+			s.logger.Warn("Archive %s holds %d incomplete entries from files that failed mid-read; they are not part of the version: %s",
+				version.Archive, len(abandoned), strings.Join(abandoned, ", "))
+		}
+		archiveErr = s.archive.close()
+		s.archive = nil
+	}
+
+	// Apply every queued update before the final progress line and stats
+	s.metrics.Stop()
+	stopProgress()
+
+	status := StatusCompleted
+	if walkErr != nil && ctx.Err() == nil {
+		// The walk stopped early, so only part of the source was seen
+		s.logger.Error("Source scan failed: %v", walkErr)
+		status = StatusPartial
+		if err == nil {
+			err = walkErr
+		}
+	}
+	if stopSpaceMonitor != nil {
+		if spaceErr := stopSpaceMonitor(); spaceErr != nil {
+			status = StatusPartial
+			err = spaceErr
+		}
+	}
+
+	// An interrupted run only copied part of the source
+	if ctx.Err() != nil {
+		status = StatusPartial
+	}
+	if archiveErr != nil {
+		s.logger.Error("%v", archiveErr)
+		status = StatusPartial
+		if err == nil {
+			err = archiveErr
+		}
+	}
+
+	var summary *ErrorSummary
+	if errors.As(err, &summary) {
+		s.metrics.SetErrorSummary(summary)
+	}
 
-	// Wait a moment for final progress update
-	time.Sleep(200 * time.Millisecond)
+	// Remove target files that no longer exist in the source
+	if s.config.MirrorMode && ctx.Err() == nil && walkErr == nil {
+		if mirrorErr := s.mirrorDeletions(ctx, tasks); mirrorErr != nil {
+			s.logger.Error("Mirror deletion failed: %v", mirrorErr)
+			if err == nil {
+				err = mirrorErr
+			}
+		}
+	}
+
+	// Apply trash retention
+	if s.config.TrashRetentionDays > 0 {
+		if _, trashErr := s.EmptyTrash(time.Duration(s.config.TrashRetentionDays) * 24 * time.Hour); trashErr != nil {
+			s.logger.Error("Failed to apply trash retention: %v", trashErr)
+		}
+	}
+
+	// Run post-backup hooks so their results are recorded in the version
+	runPostHooks()
+	s.versioner.SetHookResults(hookResults)
+
+	// Fingerprint the whole version's contents
+	if s.config.RootHash && status == StatusCompleted {
+		s.recordRootHash()
+	}
 
 	// Get final stats and complete version
 	stats := s.metrics.GetStats()
-	if err := s.versioner.CompleteVersion(stats); err != nil {
-		s.logger.Error("Failed to save backup version: %v", err)
+	runSummary := s.runSummary(s.versioner.CurrentVersionID(), status, stats, summary)
+	s.logRunSummary(runSummary)
+	if err := s.versioner.CompleteVersion(stats, status); err != nil {
+		s.logger.Error("Failed to complete backup version: %v", err)
+	}
+
+	// Apply size-based version retention
+	if s.config.RetentionMaxBytes > 0 {
+		pruned, pruneErr := s.versioner.Prune(s.config.RetentionMaxBytes)
+		if pruneErr != nil {
+			s.logger.Error("Failed to prune versions: %v", pruneErr)
+		}
+		for _, id := range pruned {
+			s.logger.Info("Pruned backup version %s", id)
+		}
 	}
 
 	// Print final summary
-	s.metrics.DisplayFinalSummary()
+	s.displaySummary(runSummary)
+	if s.diag != nil {
+		report := s.diag.report(s.pool.Workers(), s.config.checksumConcurrency(), runSummary.Retries)
+		s.logger.Info("%s", strings.TrimSpace(report))
+		fmt.Printf("\n%s", report)
+	}
 
-	// Close the metrics updates channel
-	close(s.metrics.updates)
+	result := &BackupResult{RunSummary: runSummary}
+	if summary != nil {
+		result.FailedFiles = summary.Paths()
+	}
+	return result, err
+}
 
-	return err
+// progressInterval is how often the progress display refreshes
+const progressInterval = 200 * time.Millisecond
+
+// startProgressDisplay calls display on every tick until the returned stop
+// function is called or ctx is cancelled. Stop shows one final update unless
+// ctx was cancelled, waits for the goroutine to exit, and is safe to call
+// more than once.
+func startProgressDisplay(ctx context.Context, display func()) func() {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				display()
+			case <-done:
+				display() // One final update
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
 }
 
 // DryRun simulates the backup process without making changes
 func (s *Service) DryRun(ctx context.Context) error {
 	// Validate only source path exists
 	if _, err := os.Stat(s.config.SourceDirectory); err != nil {
-		return fmt.Errorf("source directory does not exist: %v", err)
+		return withSentinel(fmt.Errorf("source directory does not exist: %v", err), ErrSourceNotFound)
 	}
 
 	// Create backup tasks
@@ -85,61 +423,46 @@ func (s *Service) DryRun(ctx context.Context) error {
 		return err
 	}
 
-	// Create log file in system temp directory
-	logFile := filepath.Join(os.TempDir(),
-		fmt.Sprintf("backup-butler_dryrun_%s.log",
-			time.Now().Format("2006-01-02_15-04-05")))
-
 	// Initialize metrics and counters
 	s.metrics = NewBackupMetrics(totalFiles, s.config.Options.Quiet)
 	s.metrics.StartTracking(ctx)
+	defer s.metrics.Stop()
 	totalSize := int64(0)
 	fileCount := 0
 	skippedCount := 0
 	skippedSize := int64(0)
 
-	// Create a done channel for the display goroutine
-	done := make(chan struct{})
-	defer close(done)
+	// Files analysed so far, read by the display goroutine
+	var analysed atomic.Int64
 
 	// Start progress display
+	stopProgress := func() {}
 	if !s.config.Options.Quiet {
 		fmt.Printf("Starting dry run analysis of %d files...\n\n", totalFiles)
-		go func() {
-			ticker := time.NewTicker(200 * time.Millisecond)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ticker.C:
-					displayDryRunProgress(totalFiles, fileCount+skippedCount)
-				case <-done:
-					displayDryRunProgress(totalFiles, fileCount+skippedCount)
-					return
-				case <-ctx.Done():
-					return
-				}
-			}
-		}()
+		stopProgress = startProgressDisplay(ctx, func() {
+			displayDryRunProgress(totalFiles, int(analysed.Load()))
+		})
 	}
+	defer stopProgress()
 
 	// Open log file for writing
-	file, err := os.Create(logFile)
+	file, logFile, err := createDryRunLog("dryrun", "Dry Run Analysis",
+		"Source: "+s.config.SourceDirectory,
+		"Target: "+s.config.TargetDirectory)
 	if err != nil {
-		return fmt.Errorf("failed to create log file: %v", err)
+		return err
 	}
 	defer file.Close()
 
-	// Write log header
-	fmt.Fprintf(file, "backup-butler Dry Run Analysis\n")
-	fmt.Fprintf(file, "Time: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Fprintf(file, "Source: %s\n", s.config.SourceDirectory)
-	fmt.Fprintf(file, "Target: %s\n", s.config.TargetDirectory)
-	fmt.Fprintf(file, "----------------------------------------\n\n")
+	for _, path := range s.unreadable {
+		fmt.Fprintf(file, "UNREADABLE: %s\n", path)
+	}
 
 	// Log details and collect statistics
 	for _, task := range tasks {
-		if _, err := os.Stat(s.config.TargetDirectory); os.IsNotExist(err) {
-			// Target doesn't exist, all files need to be copied
+		analysed.Add(1)
+		if _, err := os.Stat(s.config.TargetDirectory); os.IsNotExist(err) || s.archiveMode() {
+			// Target doesn't exist or takes a new archive, all files need to be copied
 			info, err := os.Stat(task.Source)
 			if err != nil {
 				fmt.Fprintf(file, "ERROR: Cannot stat file %s: %v\n", task.Source, err)
@@ -175,14 +498,40 @@ func (s *Service) DryRun(ctx context.Context) error {
 		}
 	}
 
+	// Preview mirror deletions without removing anything
+	var deletions []deleteCandidate
+	var deleteSize int64
+	var deleteLimitErr error
+	if s.config.MirrorMode {
+		var existing int
+		deletions, deleteSize, existing, err = s.findDeletions(tasks)
+		if err != nil {
+			return err
+		}
+		deleteLimitErr = s.checkDeleteLimit(len(deletions), existing)
+		for _, candidate := range deletions {
+			fmt.Fprintf(file, "DELETE: %s (%.2f MB)\n",
+				candidate.Path, float64(candidate.Size)/1024/1024)
+		}
+	}
+
 	// Write summary to log
 	fmt.Fprintf(file, "\n----------------------------------------\n")
 	fmt.Fprintf(file, "Summary:\n")
 	fmt.Fprintf(file, "Files to copy: %d (%.2f MB)\n", fileCount, float64(totalSize)/1024/1024)
 	fmt.Fprintf(file, "Files to skip: %d (%.2f MB)\n", skippedCount, float64(skippedSize)/1024/1024)
+	if len(s.unreadable) > 0 {
+		fmt.Fprintf(file, "Unreadable files: %d\n", len(s.unreadable))
+	}
+	if s.config.MirrorMode {
+		fmt.Fprintf(file, "Files to delete: %d (%.2f MB reclaimable)\n", len(deletions), float64(deleteSize)/1024/1024)
+		if deleteLimitErr != nil {
+			fmt.Fprintf(file, "Deletions would be refused: %v\n", deleteLimitErr)
+		}
+	}
 
-	// Allow progress bar to complete
-	time.Sleep(200 * time.Millisecond)
+	// Show the completed progress bar before the summary
+	stopProgress()
 
 	// Display console summary
 	if !s.config.Options.Quiet {
@@ -191,15 +540,48 @@ func (s *Service) DryRun(ctx context.Context) error {
 		fmt.Printf("Summary:\n")
 		fmt.Printf("- Files to copy: %d (%.2f MB)\n", fileCount, float64(totalSize)/1024/1024)
 		fmt.Printf("- Files to skip: %d (%.2f MB)\n", skippedCount, float64(skippedSize)/1024/1024)
+		if len(s.unreadable) > 0 {
+			fmt.Printf("- Unreadable files: %d (listed in the log)\n", len(s.unreadable))
+		}
+		if s.config.MirrorMode {
+			fmt.Printf("- Files to delete: %d (%.2f MB reclaimable)\n", len(deletions), float64(deleteSize)/1024/1024)
+			if deleteLimitErr != nil {
+				fmt.Printf("- Deletions would be refused: %v\n", deleteLimitErr)
+			}
+		}
 		fmt.Printf("\nDetailed analysis has been written to:\n%s\n", logFile)
 	}
 
 	return nil
 }
 
+// createDryRunLog creates a dry-run report in the system temp directory and
+// writes its header. It returns the open file and its path.
+func createDryRunLog(kind, title string, details ...string) (*os.File, string, error) {
+	logFile := filepath.Join(os.TempDir(),
+		fmt.Sprintf("backup-butler_%s_%s.log", kind,
+			time.Now().Format("2006-01-02_15-04-05")))
+
+	file, err := os.Create(logFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create log file: %v", err)
+	}
+
+	fmt.Fprintf(file, "backup-butler %s\n", title)
+	fmt.Fprintf(file, "Time: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	for _, detail := range details {
+		fmt.Fprintf(file, "%s\n", detail)
+	}
+	fmt.Fprintf(file, "----------------------------------------\n\n")
+	return file, logFile, nil
+}
+
 // Helper function for dry run progress display
 func displayDryRunProgress(total, current int) {
-	percentComplete := float64(current) / float64(total) * 100
+	percentComplete := 100.0
+	if total > 0 {
+		percentComplete = float64(current) / float64(total) * 100
+	}
 
 	// Create progress bar
 	const barWidth = 30
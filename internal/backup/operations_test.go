@@ -0,0 +1,92 @@
+// operations_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBackupWithResult(t *testing.T) {
+	files := map[string]string{"a.txt": "alpha", "b.txt": "bravo", "c.txt": "charlie"}
+
+	// Sources named bad*.txt fail to open
+	defer func(original func(string) (io.ReadCloser, error)) { openSource = original }(openSource)
+	openSource = func(name string) (io.ReadCloser, error) {
+		if matched, _ := filepath.Match("bad*.txt", filepath.Base(name)); matched {
+			return nil, os.ErrPermission
+		}
+		return os.Open(name)
+	}
+
+	tests := []struct {
+		name       string
+		failing    []string // Files added that fail to copy
+		blocked    bool     // An additional target can't be written
+		wantStatus string
+		wantCopied int
+	}{
+		{name: "completed", wantStatus: StatusCompleted, wantCopied: 3},
+		{name: "failed files listed", failing: []string{"bad1.txt", "bad2.txt"}, wantStatus: StatusCompleted, wantCopied: 3},
+		{name: "stopped before copying", blocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.Concurrency = 1
+			for _, name := range tt.failing {
+				writeFiles(t, sourcePath(cfg, ""), map[string]string{name: "x"})
+			}
+			if tt.blocked {
+				blocker := filepath.Join(t.TempDir(), "replica")
+				writeFiles(t, filepath.Dir(blocker), map[string]string{"replica": "not a directory"})
+				cfg.AdditionalTargets = []string{blocker}
+			}
+			s := newTestService(t, cfg)
+
+			result, err := s.BackupWithResult(context.Background())
+			if (err != nil) != (tt.blocked || len(tt.failing) > 0) {
+				t.Fatalf("BackupWithResult error = %v", err)
+			}
+			if tt.blocked {
+				if result != nil {
+					t.Errorf("result = %+v, want nil for a run that never started", result)
+				}
+				if !errors.Is(err, ErrTargetNotWritable) {
+					t.Errorf("error = %v, want %v", err, ErrTargetNotWritable)
+				}
+				return
+			}
+			if result == nil {
+				t.Fatal("no result")
+			}
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			if result.Stats.FilesBackedUp != tt.wantCopied || result.Stats.FilesFailed != len(tt.failing) {
+				t.Errorf("stats = %+v, want %d copied and %d failed", result.Stats, tt.wantCopied, len(tt.failing))
+			}
+			var wantFailed []string
+			for _, name := range tt.failing {
+				wantFailed = append(wantFailed, sourcePath(cfg, name))
+			}
+			if !slices.Equal(result.FailedFiles, wantFailed) {
+				t.Errorf("FailedFiles = %v, want %v", result.FailedFiles, wantFailed)
+			}
+
+			// The result matches what the version history recorded
+			latest, err := s.GetLatestVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.VersionID != latest.ID || result.Status != latest.Status {
+				t.Errorf("result is version %s (%s), latest is %s (%s)", result.VersionID, result.Status, latest.ID, latest.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// patternstats.go
+package backup
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PatternStat is how much of the source one exclude pattern decided on
+type PatternStat struct {
+	Setting string // "exclude_patterns" or "exclude_paths"
+	Pattern string // As configured, including any "!" prefix
+	Files   int    // Files excluded, or re-included for a "!" pattern
+	Bytes   int64
+}
+
+// Include reports whether the pattern re-includes files
+func (p PatternStat) Include() bool {
+	return strings.HasPrefix(p.Pattern, "!")
+}
+
+// patternCounter accumulates PatternStats during a walk. A nil counter
+// records nothing, so the walk only pays for it when stats were requested.
+type patternCounter struct {
+	stats map[string]*PatternStat
+}
+
+func newPatternCounter(cfg *Config) *patternCounter {
+	c := &patternCounter{stats: make(map[string]*PatternStat)}
+	// Seed every pattern so ones that never match still show up
+	for _, pattern := range cfg.ExcludePatterns {
+		c.stat("exclude_patterns", pattern)
+	}
+	for _, pattern := range cfg.ExcludePaths {
+		c.stat("exclude_paths", pattern)
+	}
+	return c
+}
+
+func (c *patternCounter) stat(setting, pattern string) *PatternStat {
+	key := setting + "\x00" + pattern
+	stat, ok := c.stats[key]
+	if !ok {
+		stat = &PatternStat{Setting: setting, Pattern: pattern}
+		c.stats[key] = stat
+	}
+	return stat
+}
+
+// record counts one file decided by pattern; an empty pattern is ignored
+func (c *patternCounter) record(setting, pattern string, size int64) {
+	if c == nil || pattern == "" {
+		return
+	}
+	stat := c.stat(setting, pattern)
+	stat.Files++
+	stat.Bytes += size
+}
+
+// recordTree counts a pruned file, or every file under a pruned directory
+func (c *patternCounter) recordTree(setting, pattern, root string, info os.FileInfo) {
+	if c == nil {
+		return
+	}
+	if !info.IsDir() {
+		c.record(setting, pattern, info.Size())
+		return
+	}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if fileInfo, err := d.Info(); err == nil {
+			c.record(setting, pattern, fileInfo.Size())
+		}
+		return nil
+	})
+}
+
+// sorted returns the stats with the most files first
+func (c *patternCounter) sorted() []PatternStat {
+	stats := make([]PatternStat, 0, len(c.stats))
+	for _, stat := range c.stats {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Files != stats[j].Files {
+			return stats[i].Files > stats[j].Files
+		}
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		return stats[i].Pattern < stats[j].Pattern
+	})
+	return stats
+}
+
+// PatternStats walks the source without copying anything and reports how
+// many files and bytes each exclude_patterns and exclude_paths entry matched.
+// For exclude_patterns, only the pattern that decides a file (the last match)
+// is credited.
+func (s *Service) PatternStats() ([]PatternStat, error) {
+	s.patternStats = newPatternCounter(s.config)
+	defer func() { s.patternStats = nil }()
+
+	if _, err := s.walkTasks(func(CopyTask) error { return nil }); err != nil {
+		return nil, err
+	}
+	return s.patternStats.sorted(), nil
+}
@@ -0,0 +1,61 @@
+// patternsyntax.go
+package backup
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Pattern syntaxes for exclude_patterns and immutable_paths
+const (
+	PatternGlob  = "glob"  // filepath.Match globs (default)
+	PatternRegex = "regex" // Regular expressions matched against the relative path
+)
+
+// regexpCache holds compiled regex-syntax patterns, which are matched
+// against every file the walk visits. A nil cache compiles on every call.
+type regexpCache struct {
+	mu       sync.Mutex
+	compiled map[string]*regexp.Regexp
+}
+
+func newRegexpCache() *regexpCache {
+	return &regexpCache{compiled: make(map[string]*regexp.Regexp)}
+}
+
+func (c *regexpCache) get(pattern string) (*regexp.Regexp, error) {
+	if c == nil {
+		return regexp.Compile(pattern)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if re, ok := c.compiled[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	c.compiled[pattern] = re
+	return re, nil
+}
+
+// regexSyntax reports whether exclude_patterns and immutable_paths are
+// regular expressions rather than globs
+func (s *Service) regexSyntax() bool {
+	return s.config.PatternSyntax == PatternRegex
+}
+
+// patternMatches matches one exclude_patterns or immutable_paths entry,
+// without any "!" prefix, against a slash-separated relative path. Regular
+// expressions match anywhere in the path unless anchored with ^ and $.
+func (s *Service) patternMatches(pattern, rel string) bool {
+	if !s.regexSyntax() {
+		return excludePatternMatches(pattern, rel)
+	}
+	if s.config.ExcludeCaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := s.regexps.get(pattern)
+	return err == nil && re.MatchString(rel)
+}
@@ -0,0 +1,82 @@
+// patternsyntax_test.go
+package backup
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRegexExcludePatterns(t *testing.T) {
+	files := map[string]string{
+		"IMG_1234.jpg":  "a",
+		"IMG_12a4.jpg":  "b",
+		"notes.txt":     "c",
+		"notes.txt.bak": "d",
+		"sub/x.tmp":     "e",
+		"sub/Y.TMP":     "f",
+	}
+	all := []string{"data/IMG_1234.jpg", "data/IMG_12a4.jpg", "data/notes.txt", "data/notes.txt.bak", "data/sub/Y.TMP", "data/sub/x.tmp"}
+	tests := []struct {
+		name        string
+		patterns    []string
+		insensitive bool
+		want        []string
+	}{
+		{"no patterns", nil, false, all},
+		// Globs have no "one or more digits"; IMG_* would take IMG_12a4 too
+		{"repetition", []string{`IMG_[0-9]+\.jpg$`}, false,
+			[]string{"data/IMG_12a4.jpg", "data/notes.txt", "data/notes.txt.bak", "data/sub/Y.TMP", "data/sub/x.tmp"}},
+		// Nor alternation in one pattern
+		{"alternation", []string{`\.(tmp|bak)$`}, false,
+			[]string{"data/IMG_1234.jpg", "data/IMG_12a4.jpg", "data/notes.txt", "data/sub/Y.TMP"}},
+		{"case-insensitive", []string{`\.(tmp|bak)$`}, true,
+			[]string{"data/IMG_1234.jpg", "data/IMG_12a4.jpg", "data/notes.txt"}},
+		// Expressions see the whole relative path, not just the name
+		{"matches the path", []string{`^data/sub/`}, false,
+			[]string{"data/IMG_1234.jpg", "data/IMG_12a4.jpg", "data/notes.txt", "data/notes.txt.bak"}},
+		{"negation", []string{`^data/sub/`, `!\.TMP$`}, false,
+			[]string{"data/IMG_1234.jpg", "data/IMG_12a4.jpg", "data/notes.txt", "data/notes.txt.bak", "data/sub/Y.TMP"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.PatternSyntax = PatternRegex
+			cfg.ExcludePatterns = tt.patterns
+			cfg.ExcludeCaseInsensitive = tt.insensitive
+			if got := walkKeys(t, newTestService(t, cfg)); !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternSyntaxValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		syntax    string
+		exclude   []string
+		immutable []string
+		wantErr   bool
+	}{
+		{"glob by default", "", []string{"*.tmp"}, nil, false},
+		{"regex", PatternRegex, []string{`\.(tmp|bak)$`}, []string{`^data/photos/`}, false},
+		{"unknown syntax", "shell", nil, nil, true},
+		{"invalid regex", PatternRegex, []string{"a(b"}, nil, true},
+		{"invalid negated regex", PatternRegex, []string{"!a(b"}, nil, true},
+		{"invalid immutable regex", PatternRegex, nil, []string{"a(b"}, true},
+		// Valid as a glob, so only regex syntax rejects it
+		{"glob syntax accepts it", PatternGlob, []string{"a(b"}, nil, false},
+		{"invalid glob", PatternGlob, []string{"a["}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.PatternSyntax = tt.syntax
+			cfg.ExcludePatterns = tt.exclude
+			cfg.ImmutablePaths = tt.immutable
+			if err := Validate(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// pipeline.go
+package backup
+
+import (
+	"context"
+	"errors"
+)
+
+// runPipeline decides which tasks need copying on a pool of
+// checksum_concurrency workers and copies only those on the copy pool, so
+// CPU-bound hashing and I/O-bound copying run at their own parallelism.
+// Failures from both stages are reported in one ErrorSummary.
+func (s *Service) runPipeline(ctx context.Context, taskCh <-chan CopyTask) error {
+	copyCh := make(chan CopyTask, s.config.Concurrency)
+	defer s.diag.addWall(s.diag.now())
+
+	s.checkPool = NewWorkerPool(
+		s.config.checksumConcurrency(),
+		func(task CopyTask) error {
+			needsCopy, err := s.checkFile(task)
+			if err != nil || !needsCopy {
+				return err
+			}
+			select {
+			case copyCh <- task:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		s.config.RetryAttempts,
+		s.config.RetryDelay,
+	)
+	s.checkPool.SetRampUp(s.config.WorkerRampUp)
+
+	// With fail_fast, the first failure in either stage stops the whole run
+	var stop func()
+	if s.config.FailFast && s.cancelRun != nil {
+		stop = func() {
+			s.logger.Error("Stopping the backup at the first failure (fail_fast)")
+			s.cancelRun()
+		}
+	}
+	s.checkPool.SetFailFast(stop)
+	s.pool.SetFailFast(stop)
+
+	checkDone := make(chan error, 1)
+	go func() {
+		defer close(copyCh)
+		checkDone <- s.checkPool.ExecuteStream(ctx, taskCh)
+	}()
+
+	copyErr := s.pool.ExecuteStream(ctx, copyCh)
+	checkErr := <-checkDone
+
+	return mergeFailures(checkErr, copyErr)
+}
+
+// mergeFailures combines the ErrorSummary errors of two pools into one
+func mergeFailures(first, second error) error {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	// A fail-fast stop in either stage is what the run reports
+	var stopped *failFastError
+	if !errors.As(first, &stopped) && errors.As(second, &stopped) {
+		first, second = second, first
+	}
+	var a, b *ErrorSummary
+	if !errors.As(first, &a) || !errors.As(second, &b) {
+		return errors.Join(first, second)
+	}
+	a.merge(b)
+	return first
+}
+
+// taskQueue returns a closed channel holding tasks, for feeding a known task
+// list through runPipeline
+func taskQueue(tasks []CopyTask) <-chan CopyTask {
+	taskCh := make(chan CopyTask, len(tasks))
+	for _, task := range tasks {
+		taskCh <- task
+	}
+	close(taskCh)
+	return taskCh
+}
@@ -0,0 +1,163 @@
+// preflight.go
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PreflightCheck is the outcome of a single runtime check
+type PreflightCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// Preflight performs the runtime checks a backup depends on without copying
+// anything: folder existence, target writability, source/target overlap and
+// whether the pending data fits on the target
+func (s *Service) Preflight() []PreflightCheck {
+	var checks []PreflightCheck
+
+	folders, unmatched := s.expandFolders(s.config.SourceDirectory)
+	for _, pattern := range unmatched {
+		checks = append(checks, PreflightCheck{"Folder " + pattern, false, "pattern matches no folders"})
+	}
+	for _, folder := range folders {
+		srcPath := filepath.Join(s.config.SourceDirectory, folder)
+		info, err := os.Stat(srcPath)
+		switch {
+		case err != nil:
+			checks = append(checks, PreflightCheck{"Folder " + folder, false, err.Error()})
+		case !info.IsDir():
+			checks = append(checks, PreflightCheck{"Folder " + folder, false, srcPath + " is not a directory"})
+		default:
+			checks = append(checks, PreflightCheck{"Folder " + folder, true, srcPath})
+		}
+	}
+
+	checks = append(checks, s.checkTargetWritable())
+	checks = append(checks, s.checkOverlap())
+	checks = append(checks, s.checkSpaceEstimate())
+
+	return checks
+}
+
+// checkTargetWritable creates and removes a temporary file in the target
+func (s *Service) checkTargetWritable() PreflightCheck {
+	check := PreflightCheck{Name: "Target writable"}
+
+	if err := probeWritable(s.config.TargetDirectory); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.Passed = true
+	check.Detail = s.config.TargetDirectory
+	return check
+}
+
+// probeWritable creates dir if needed, then creates and removes a file in it
+func probeWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("created but could not remove %s: %w", file.Name(), err)
+	}
+	return nil
+}
+
+// requireWritableTargets fails fast when the target or an additional target
+// can't be written, e.g. a read-only mount, rather than failing every file
+// mid-run
+func (s *Service) requireWritableTargets() error {
+	for _, target := range append([]string{s.config.TargetDirectory}, s.config.AdditionalTargets...) {
+		if err := probeWritable(target); err != nil {
+			return newBackupError("Backup", target, withSentinel(fmt.Errorf("target not writable: %w", err), ErrTargetNotWritable))
+		}
+	}
+	return nil
+}
+
+// checkOverlap fails when the target lies inside a backed-up folder or a
+// backed-up folder lies inside the target
+func (s *Service) checkOverlap() PreflightCheck {
+	check := PreflightCheck{Name: "No source/target overlap"}
+
+	target, err := filepath.Abs(s.config.TargetDirectory)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	folders, _ := s.expandFolders(s.config.SourceDirectory)
+	for _, folder := range folders {
+		src, err := filepath.Abs(filepath.Join(s.config.SourceDirectory, folder))
+		if err != nil {
+			check.Detail = err.Error()
+			return check
+		}
+		if isWithin(target, src) || isWithin(src, target) {
+			check.Detail = fmt.Sprintf("%s overlaps %s", src, target)
+			return check
+		}
+	}
+
+	check.Passed = true
+	check.Detail = "source folders and target are disjoint"
+	return check
+}
+
+// checkSpaceEstimate compares the bytes that would be copied with the
+// target's free space, honoring the configured reserve
+func (s *Service) checkSpaceEstimate() PreflightCheck {
+	check := PreflightCheck{Name: "Space estimate"}
+
+	tasks, _, err := s.createTasks()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	var needed int64
+	for _, task := range tasks {
+		if info, err := os.Stat(task.Destination); err == nil && info.Size() == task.Size {
+			continue
+		}
+		needed += task.Size
+	}
+
+	free, err := freeSpace(s.config.TargetDirectory)
+	if errors.Is(err, errFreeSpaceUnknown) {
+		check.Passed = true
+		check.Detail = fmt.Sprintf("%.2f MB to copy, %v", float64(needed)/1024/1024, err)
+		return check
+	}
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%.2f MB to copy, %.2f MB free", float64(needed)/1024/1024, float64(free)/1024/1024)
+	check.Passed = needed+s.config.MinFreeSpace <= free
+	return check
+}
+
+// isWithin reports whether path is dir or lies beneath it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
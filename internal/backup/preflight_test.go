@@ -0,0 +1,81 @@
+// preflight_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRequireWritableTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool // Needs permissions to be enforced
+		block    func(t *testing.T, cfg *Config) string
+	}{
+		{"writable", false, nil},
+		{"read-only target", true, func(t *testing.T, cfg *Config) string {
+			if err := os.Chmod(cfg.TargetDirectory, 0555); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chmod(cfg.TargetDirectory, 0755) })
+			return cfg.TargetDirectory
+		}},
+		{"read-only additional target", true, func(t *testing.T, cfg *Config) string {
+			replica := t.TempDir()
+			if err := os.Chmod(replica, 0555); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chmod(replica, 0755) })
+			cfg.AdditionalTargets = []string{replica}
+			return replica
+		}},
+		{"additional target is a file", false, func(t *testing.T, cfg *Config) string {
+			replica := filepath.Join(t.TempDir(), "replica")
+			writeFiles(t, filepath.Dir(replica), map[string]string{"replica": "x"})
+			cfg.AdditionalTargets = []string{replica}
+			return replica
+		}},
+		{"additional target below a file", false, func(t *testing.T, cfg *Config) string {
+			parent := filepath.Join(t.TempDir(), "parent")
+			writeFiles(t, filepath.Dir(parent), map[string]string{"parent": "x"})
+			cfg.AdditionalTargets = []string{filepath.Join(parent, "replica")}
+			return cfg.AdditionalTargets[0]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.readOnly && (runtime.GOOS == "windows" || os.Geteuid() == 0) {
+				t.Skip("directory permissions are not enforced")
+			}
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+			s := newTestService(t, cfg)
+			blocked := ""
+			if tt.block != nil {
+				blocked = tt.block(t, s.config)
+			}
+
+			_, err := s.BackupWithResult(context.Background())
+			if blocked == "" {
+				if err != nil {
+					t.Fatalf("BackupWithResult: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTargetNotWritable) {
+				t.Fatalf("error = %v, want %v", err, ErrTargetNotWritable)
+			}
+			if !strings.Contains(err.Error(), blocked) {
+				t.Errorf("error %q does not name %s", err, blocked)
+			}
+			// Nothing was attempted
+			if _, err := os.Stat(targetPath(cfg, "a.txt")); !os.IsNotExist(err) {
+				t.Error("a.txt was copied to the target")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// progresssignal.go
+package backup
+
+import (
+	"os"
+	"os/signal"
+)
+
+// startProgressSignal prints a metrics snapshot to stderr each time the
+// process receives SIGUSR1, regardless of quiet mode, until the returned stop
+// function is called
+func (s *Service) startProgressSignal() func() {
+	signals := make(chan os.Signal, 1)
+	notifyProgressSignal(signals)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-signals:
+				s.metrics.WriteSnapshot(os.Stderr)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(stop)
+		<-stopped
+	}
+}
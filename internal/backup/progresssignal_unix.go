@@ -0,0 +1,15 @@
+//go:build !windows
+
+// progresssignal_unix.go
+package backup
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyProgressSignal relays SIGUSR1 to ch
+func notifyProgressSignal(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGUSR1)
+}
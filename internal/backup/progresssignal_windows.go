@@ -0,0 +1,9 @@
+//go:build windows
+
+// progresssignal_windows.go
+package backup
+
+import "os"
+
+// notifyProgressSignal is a no-op: Windows has no SIGUSR1
+func notifyProgressSignal(ch chan<- os.Signal) {}
@@ -0,0 +1,30 @@
+// quarantine.go
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// quarantineDir holds corrupt backup copies set aside for inspection
+const quarantineDir = ".quarantine"
+
+// quarantine moves a corrupt file under the target directory root into
+// root/.quarantine/<timestamp>/, preserving its path relative to root
+func quarantine(root, path string, at time.Time) (string, error) {
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+
+	quarantinePath := filepath.Join(root, quarantineDir, at.Format("20060102-150405"), relPath)
+	if err := os.MkdirAll(filepath.Dir(quarantinePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	if err := os.Rename(path, quarantinePath); err != nil {
+		return "", fmt.Errorf("failed to quarantine: %w", err)
+	}
+	return quarantinePath, nil
+}
@@ -0,0 +1,92 @@
+// quickcompare.go
+package backup
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// quickCompareApplies reports whether a file of the given size is large enough
+// for the head-and-tail comparison to be cheaper than hashing it
+func (s *Service) quickCompareApplies(size int64) bool {
+	n := int64(s.config.QuickCompareBytes)
+	return n > 0 && size > 2*n
+}
+
+// edgesMatch compares the first and last QuickCompareBytes of two files of
+// equal size. It is a heuristic: a change confined to the middle of the file
+// goes unnoticed.
+func (s *Service) edgesMatch(sourcePath, destPath string, size int64) (bool, error) {
+	s.files.acquire(2)
+	defer s.files.release(2)
+
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	dst, err := os.Open(destPath)
+	if err != nil {
+		return false, err
+	}
+	defer dst.Close()
+
+	n := int64(s.config.QuickCompareBytes)
+	srcBuf := make([]byte, n)
+	dstBuf := make([]byte, n)
+	for _, offset := range []int64{0, size - n} {
+		if _, err := src.ReadAt(srcBuf, offset); err != nil && err != io.EOF {
+			return false, err
+		}
+		if _, err := dst.ReadAt(dstBuf, offset); err != nil && err != io.EOF {
+			return false, err
+		}
+		if !bytes.Equal(srcBuf, dstBuf) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// contentsMatch compares two files byte for byte
+func (s *Service) contentsMatch(pathA, pathB string) (bool, error) {
+	s.files.acquire(2)
+	defer s.files.release(2)
+
+	a, err := os.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer a.Close()
+
+	b, err := os.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer b.Close()
+
+	bufA := make([]byte, s.config.BufferSize)
+	bufB := make([]byte, s.config.BufferSize)
+	for {
+		nA, errA := io.ReadFull(a, bufA)
+		nB, errB := io.ReadFull(b, bufB)
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		// A short read means the end of the file; equal lengths end together
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			if errB == io.EOF || errB == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, errB
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// rampup.go
+package backup
+
+import (
+	"context"
+	"time"
+)
+
+// SetRampUp staggers worker launch: the first worker starts at once and
+// each further one interval later, letting a sleeping disk spin up before
+// every worker is seeking on it. Zero starts all workers together.
+func (p *WorkerPool) SetRampUp(interval time.Duration) {
+	p.rampUp = interval
+}
+
+// waitToLaunch delays launching worker i by the ramp-up interval. It returns
+// false once the run is cancelled or drained is closed because the tasks
+// ran out, when launching more workers is pointless. Adaptive pools only
+// stagger their configured workers; the rest wait on the limiter anyway.
+func (p *WorkerPool) waitToLaunch(ctx context.Context, i int, drained <-chan struct{}) bool {
+	if p.rampUp <= 0 || i == 0 || i >= p.workers {
+		return true
+	}
+	timer := time.NewTimer(p.rampUp)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-drained:
+		return false
+	}
+}
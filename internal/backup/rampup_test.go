@@ -0,0 +1,95 @@
+// rampup_test.go
+package backup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerRampUp(t *testing.T) {
+	const workers = 4
+	tests := []struct {
+		name   string
+		rampUp time.Duration
+	}{
+		{"all at once", 0},
+		{"staggered", 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each task holds its worker long enough that every worker is
+			// busy once launched; reached[n] is when n+1 first ran together
+			var mu sync.Mutex
+			active := 0
+			var reached []time.Duration
+			start := time.Now()
+			pool := NewWorkerPool(workers, func(task CopyTask) error {
+				mu.Lock()
+				active++
+				if active > len(reached) {
+					reached = append(reached, time.Since(start))
+				}
+				mu.Unlock()
+				time.Sleep(300 * time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			}, 1, 0)
+			pool.SetRampUp(tt.rampUp)
+
+			tasks := make([]CopyTask, workers)
+			if err := pool.Execute(context.Background(), tasks); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			if len(reached) != workers {
+				t.Fatalf("at most %d workers ran together, want %d", len(reached), workers)
+			}
+			for n, at := range reached {
+				earliest := time.Duration(n) * tt.rampUp
+				if at < earliest || at > earliest+50*time.Millisecond {
+					t.Errorf("worker %d started at %v, want %v", n+1, at.Round(time.Millisecond), earliest)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkerRampUpStopsLaunching(t *testing.T) {
+	tests := []struct {
+		name   string
+		tasks  int
+		cancel bool
+	}{
+		// Further workers would find nothing left to do
+		{"tasks ran out", 1, false},
+		{"run cancelled", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			pool := NewWorkerPool(4, func(task CopyTask) error {
+				if tt.cancel {
+					cancel()
+				}
+				return nil
+			}, 1, 0)
+			pool.SetRampUp(time.Hour)
+
+			done := make(chan struct{})
+			go func() {
+				pool.Execute(ctx, make([]CopyTask, tt.tasks))
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Execute still waiting to launch workers")
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// readerror.go
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Policies for a source file that fails to read partway through its copy
+const (
+	ReadErrorSkip        = "skip"         // Count the file as failed and continue (default)
+	ReadErrorFail        = "fail"         // Stop the backup
+	ReadErrorKeepPartial = "keep-partial" // Also keep the bytes read so far beside the destination
+)
+
+// partialCopySuffix marks the readable part of a source that failed mid-copy
+const partialCopySuffix = ".partial"
+
+// sourceReadError marks a failure reading the source, as opposed to writing
+// the destination
+type sourceReadError struct {
+	err error
+}
+
+func (e *sourceReadError) Error() string {
+	return fmt.Sprintf("failed to read source file: %v", e.err)
+}
+
+func (e *sourceReadError) Unwrap() error {
+	return e.err
+}
+
+// sourceReader tags read errors from r as sourceReadErrors and counts reads
+// for --diagnostics
+type sourceReader struct {
+	r    io.Reader
+	diag *diagnostics
+}
+
+func (r sourceReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.diag.read(n)
+	if err != nil && err != io.EOF {
+		err = &sourceReadError{err: err}
+	}
+	return n, err
+}
+
+// onReadError applies on_read_error to a copy whose source failed after
+// copied bytes. The temp file holding those bytes is left for a resumed
+// attempt unless it is kept as a .partial file.
+func (s *Service) onReadError(task CopyTask, tempPath string, copied int64, readErr error) error {
+	err := newBackupError("Copy", task.Source, withSentinel(readErr, ErrUnreadableSource))
+	s.logger.Error("Read error after %d bytes of %s: %v", copied, task.Source, readErr)
+
+	switch s.config.OnReadError {
+	case ReadErrorKeepPartial:
+		partialPath := task.Destination + partialCopySuffix
+		if renameErr := os.Rename(tempPath, partialPath); renameErr != nil {
+			s.logger.Error("Failed to keep partial copy of %s: %v", task.Source, renameErr)
+		} else {
+			s.logger.Warn("Kept %d readable bytes of %s as %s", copied, task.Source, partialPath)
+		}
+	case ReadErrorFail:
+		if s.cancelRun != nil {
+			s.logger.Error("Stopping the backup (on_read_error is %q)", ReadErrorFail)
+			s.cancelRun()
+		}
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+// readerror_test.go
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOnReadError(t *testing.T) {
+	const readable = "01234" // What a.txt yields before its read fails
+	files := map[string]string{"a.txt": readable + "56789"}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("b%02d.txt", i)] = "bravo"
+	}
+
+	// a.txt fails with an I/O error after its first bytes, as a bad sector would
+	defer func(original func(string) (io.ReadCloser, error)) { openSource = original }(openSource)
+	openSource = func(name string) (io.ReadCloser, error) {
+		if filepath.Base(name) == "a.txt" {
+			return io.NopCloser(&failingReader{data: readable, err: syscall.EIO}), nil
+		}
+		return os.Open(name)
+	}
+
+	tests := []struct {
+		policy      string
+		wantPartial bool
+		wantStopped bool
+	}{
+		{"", false, false},
+		{ReadErrorSkip, false, false},
+		{ReadErrorKeepPartial, true, false},
+		{ReadErrorFail, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("policy=%q", tt.policy), func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.Concurrency = 1
+			cfg.OnReadError = tt.policy
+			s := newTestService(t, cfg)
+
+			result, err := s.BackupWithResult(context.Background())
+			if err == nil {
+				t.Fatal("backup succeeded with an unreadable source")
+			}
+			if _, err := os.Stat(targetPath(cfg, "a.txt")); !os.IsNotExist(err) {
+				t.Error("a.txt was moved into place after a failed read")
+			}
+
+			partial := targetPath(cfg, "a.txt"+partialCopySuffix)
+			if tt.wantPartial {
+				if got := readFile(t, partial); got != readable {
+					t.Errorf("a.txt%s = %q, want %q", partialCopySuffix, got, readable)
+				}
+			} else if _, err := os.Stat(partial); !os.IsNotExist(err) {
+				t.Errorf("a.txt%s kept with on_read_error %q", partialCopySuffix, tt.policy)
+			}
+
+			// a.txt is walked first; skipping it leaves the rest to be
+			// copied, failing stops the run before most of them
+			copied := 0
+			for name := range files {
+				if _, err := os.Stat(targetPath(cfg, name)); err == nil {
+					copied++
+				}
+			}
+			if stopped := copied < len(files)/2; stopped != tt.wantStopped {
+				t.Errorf("%d of %d files copied, want stopped %v", copied, len(files), tt.wantStopped)
+			}
+			if result != nil && result.Stats.FilesFailed == 0 {
+				t.Error("no failed file counted")
+			}
+		})
+	}
+}
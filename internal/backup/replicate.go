@@ -0,0 +1,174 @@
+// replicate.go
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// replica is one additional target a file is written to alongside the copy
+// to target_directory. Writes go through the same source read; a failure is
+// recorded on the replica rather than failing the copy.
+type replica struct {
+	target   string // Additional target directory
+	dest     string // Final path under target
+	tempPath string
+	file     *os.File
+	err      error
+}
+
+// Write implements io.Writer for io.MultiWriter. Once a write fails the
+// replica stops accepting data but reports success, so the other targets
+// keep copying.
+func (r *replica) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return len(p), nil
+	}
+	if _, err := r.file.Write(p); err != nil {
+		r.err = fmt.Errorf("failed to write %s: %w", r.tempPath, err)
+	}
+	return len(p), nil
+}
+
+// replicasFor returns the additional targets that need task's file, applying
+// the same skip check and safe mode handling as target_directory. Targets
+// where the check itself fails are recorded as failed and left out.
+func (s *Service) replicasFor(task CopyTask) []*replica {
+	if len(s.config.AdditionalTargets) == 0 {
+		return nil
+	}
+	relPath, err := filepath.Rel(s.config.TargetDirectory, task.Destination)
+	if err != nil {
+		return nil
+	}
+
+	var replicas []*replica
+	for _, target := range s.config.AdditionalTargets {
+		replicaTask := task
+		replicaTask.Destination = filepath.Join(target, relPath)
+
+		skip, err := s.shouldSkipFile(replicaTask)
+		if err != nil {
+			s.logger.Error("Additional target %s: %v", target, err)
+			s.metrics.RecordTarget(target, 0, err)
+			continue
+		}
+		if skip {
+			continue
+		}
+
+		replicaTask = s.safeModeDestination(replicaTask)
+		replicas = append(replicas, &replica{target: target, dest: replicaTask.Destination})
+	}
+	return replicas
+}
+
+// openReplicas creates the temp file for each replica. Replicas that can't be
+// opened are marked failed and receive no data.
+func openReplicas(replicas []*replica) []io.Writer {
+	var writers []io.Writer
+	for _, r := range replicas {
+		r.tempPath = r.dest + copyTempSuffix
+		if err := os.MkdirAll(filepath.Dir(r.dest), 0755); err != nil {
+			r.err = fmt.Errorf("failed to create destination directory: %w", err)
+			continue
+		}
+		file, err := os.Create(r.tempPath)
+		if err != nil {
+			r.err = fmt.Errorf("failed to create destination file: %w", err)
+			continue
+		}
+		r.file = file
+		writers = append(writers, r)
+	}
+	return writers
+}
+
+// abortReplicas discards the temp files of a copy whose source read failed,
+// recording the failure against each additional target
+func (s *Service) abortReplicas(replicas []*replica, err error) {
+	for _, r := range replicas {
+		if r.file != nil {
+			r.file.Close()
+			os.Remove(r.tempPath)
+		}
+		s.metrics.RecordTarget(r.target, 0, err)
+	}
+}
+
+// finishReplicas moves every fully written replica into place with the
+// source's mode and modification time, and records each target's outcome
+func (s *Service) finishReplicas(replicas []*replica, sourceInfo os.FileInfo, size int64) {
+	for _, r := range replicas {
+		if r.file != nil {
+			if s.config.Fsync && r.err == nil {
+				if err := r.file.Sync(); err != nil {
+					r.err = fmt.Errorf("failed to sync destination file: %w", err)
+				}
+			}
+			if err := r.file.Close(); err != nil && r.err == nil {
+				r.err = fmt.Errorf("failed to close destination file: %w", err)
+			}
+		}
+		if r.err == nil {
+			if err := os.Rename(r.tempPath, r.dest); err != nil {
+				r.err = fmt.Errorf("failed to move copy into place: %w", err)
+			} else if s.config.Fsync {
+				if err := syncDir(filepath.Dir(r.dest)); err != nil {
+					s.logger.Warn("Failed to sync directory of %s: %v", r.dest, err)
+				}
+			}
+		}
+		if r.err != nil {
+			if r.file != nil {
+				os.Remove(r.tempPath)
+			}
+			s.logger.Error("Additional target %s: failed to copy %s: %v", r.target, r.dest, r.err)
+			s.metrics.RecordTarget(r.target, 0, r.err)
+			continue
+		}
+
+		if sourceInfo != nil {
+			if err := os.Chmod(r.dest, sourceInfo.Mode()); err != nil {
+				s.logger.Warn("Failed to preserve file mode for %s: %v", r.dest, err)
+			}
+			if err := os.Chtimes(r.dest, time.Now(), sourceInfo.ModTime()); err != nil {
+				s.logger.Warn("Failed to preserve modification time for %s: %v", r.dest, err)
+			}
+		}
+		s.logFileDone("Copied %s to additional target %s", r.dest, r.target)
+		s.metrics.RecordTarget(r.target, size, nil)
+	}
+}
+
+// replicate copies a file that target_directory already has unchanged to the
+// additional targets that still need it, reading the source once
+func (s *Service) replicate(task CopyTask, replicas []*replica) error {
+	s.files.acquire(1 + len(replicas))
+	defer s.files.release(1 + len(replicas))
+
+	src, err := os.Open(task.Source)
+	if err != nil {
+		err = fmt.Errorf("failed to open source file: %w", err)
+		for _, r := range replicas {
+			s.metrics.RecordTarget(r.target, 0, err)
+		}
+		return err
+	}
+	defer src.Close()
+
+	writers := openReplicas(replicas)
+	copied, err := io.CopyBuffer(io.MultiWriter(writers...), src, make([]byte, s.config.BufferSize))
+	if err != nil {
+		err = fmt.Errorf("failed to copy file: %w", err)
+		s.abortReplicas(replicas, err)
+		return err
+	}
+
+	sourceInfo, _ := src.Stat()
+	s.finishReplicas(replicas, sourceInfo, copied)
+	return nil
+}
@@ -0,0 +1,72 @@
+// replicate_test.go
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplicaSafeMode(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+	replicaDir := filepath.Join(t.TempDir(), "replica")
+	cfg.AdditionalTargets = []string{replicaDir}
+	runBackup(t, newTestService(t, cfg))
+
+	// The source changes, and the replica already has the new content while
+	// target_directory still holds the old copy
+	writeFiles(t, filepath.Join(cfg.SourceDirectory, testFolder), map[string]string{"a.txt": "alpha, edited"})
+	replicaFile := filepath.Join(replicaDir, testFolder, "a.txt")
+	writeFiles(t, filepath.Dir(replicaFile), map[string]string{"a.txt": "alpha, edited"})
+	info, err := os.Stat(sourcePath(cfg, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(replicaFile, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.SafeMode = true
+	runBackup(t, newTestService(t, cfg))
+
+	if got := readFile(t, targetPath(cfg, "a.txt")); got != "alpha" {
+		t.Errorf("a.txt = %q, want the earlier copy kept", got)
+	}
+	if got := readFile(t, targetPath(cfg, "a.txt"+safeModeSuffix)); got != "alpha, edited" {
+		t.Errorf("a.txt%s = %q, want the new copy", safeModeSuffix, got)
+	}
+	// The replica is checked against its own a.txt, which is up to date
+	if _, err := os.Stat(replicaFile + safeModeSuffix); !os.IsNotExist(err) {
+		t.Errorf("replica got a %s sidecar for an unchanged file", safeModeSuffix)
+	}
+}
+
+func TestReplicaOutlivesPrimaryFailure(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+	replicaDir := filepath.Join(t.TempDir(), "replica")
+	cfg.AdditionalTargets = []string{replicaDir}
+	// A directory in the way fails the move into place on target_directory
+	// after the source has been read in full
+	writeFiles(t, targetPath(cfg, "a.txt"), map[string]string{"blocker": "x"})
+
+	s := newTestService(t, cfg)
+	result, err := s.BackupWithResult(context.Background())
+	if err == nil {
+		t.Fatal("backup succeeded with a directory in the way")
+	}
+
+	if got := readFile(t, filepath.Join(replicaDir, testFolder, "a.txt")); got != "alpha" {
+		t.Errorf("replica a.txt = %q, want %q", got, "alpha")
+	}
+	outcomes := make(map[string]TargetStats)
+	for _, stats := range result.Targets {
+		outcomes[stats.Target] = stats
+	}
+	if stats := outcomes[replicaDir]; stats.FilesCopied != 1 || stats.FilesFailed != 0 {
+		t.Errorf("replica outcome = %+v, want 1 copied, 0 failed", stats)
+	}
+	if stats := outcomes[cfg.TargetDirectory]; stats.FilesFailed == 0 {
+		t.Errorf("target_directory outcome = %+v, want a failure", stats)
+	}
+}
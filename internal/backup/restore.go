@@ -0,0 +1,540 @@
+// restore.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// TestRestoreResult describes a restore into a scratch directory and the
+// check of every restored file against the version's manifest
+type TestRestoreResult struct {
+	VersionID  string
+	Restored   int      // Files restored
+	Verified   int      // Restored files whose checksum matched the manifest
+	Unverified int      // Restored files with no recorded checksum; size checked only
+	Failed     []string // Manifest keys that failed to restore or didn't match
+}
+
+// OK reports whether every file restored and matched the manifest
+func (r *TestRestoreResult) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// restoreTask maps one manifest entry from its backup copy under root to
+// its place under destDir
+func restoreTask(root, destDir, key string, metadata FileMetadata) CopyTask {
+	return CopyTask{
+		Source:      targetPathFor(root, key, metadata),
+		Destination: filepath.Join(destDir, filepath.FromSlash(key)),
+		Size:        metadata.Size,
+		ModTime:     metadata.ModTime,
+	}
+}
+
+// localKey reports whether a manifest entry stays inside the directories it
+// is resolved against. A corrupt or crafted manifest could otherwise read or
+// write outside the target and the restore destination.
+func localKey(key string, metadata FileMetadata) bool {
+	if metadata.TargetKey != "" && !filepath.IsLocal(filepath.FromSlash(metadata.TargetKey)) {
+		return false
+	}
+	return filepath.IsLocal(filepath.FromSlash(key))
+}
+
+// errKeyNotLocal reports a manifest key that would resolve outside its directory
+var errKeyNotLocal = errors.New("manifest key lies outside the destination")
+
+// sortedKeys returns a version's manifest keys in order
+func sortedKeys(files map[string]FileMetadata) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Restore actions for a single file, also used as summary labels
+const (
+	restoreNew       = "restored"
+	restoreOverwrite = "overwritten"
+	restorePreserve  = "preserved"
+	restoreSame      = "identical"
+)
+
+// Restore copies every file of a backup version into destDir, laid out as
+// it was under the source directory. Existing files are handled according
+// to OverwritePolicy; files already identical to the backup are left alone.
+// Manifest entries that would resolve outside destDir or the target are
+// reported as failed and never written.
+func (s *Service) Restore(ctx context.Context, versionID, destDir string) error {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return newBackupError("Restore", destDir, err)
+	}
+
+	root, cleanup, err := s.versionRoot(ctx, version)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Backup copies that are gone are reported together at the end rather
+	// than each failing through every retry
+	tasks := make([]CopyTask, 0, len(version.Files))
+	byDest := make(map[string]FileMetadata, len(version.Files))
+	var missing, unsafe []string
+	var keyErrs []error
+	for _, key := range sortedKeys(version.Files) {
+		metadata := version.Files[key]
+		if !localKey(key, metadata) {
+			s.logger.Error("Restore: %q lies outside the destination; not restored", key)
+			unsafe = append(unsafe, key)
+			keyErrs = append(keyErrs, newBackupError("Restore", key, errKeyNotLocal))
+			continue
+		}
+		task := restoreTask(root, destDir, key, metadata)
+		if _, err := os.Stat(task.Source); os.IsNotExist(err) {
+			s.logger.Warn("Restore: backup copy of %s is missing (%s)", key, task.Source)
+			missing = append(missing, key)
+			continue
+		}
+		tasks = append(tasks, task)
+		byDest[task.Destination] = metadata
+	}
+
+	// Decide each file's action once, so a retry after a partial write
+	// doesn't mistake the half-written file for existing data
+	var mu sync.Mutex
+	decided := make(map[string]string, len(tasks))
+	counts := make(map[string]int)
+	restoreFn := func(task CopyTask) error {
+		mu.Lock()
+		action, ok := decided[task.Destination]
+		mu.Unlock()
+		if !ok {
+			var err error
+			if action, err = s.restoreAction(task, byDest[task.Destination]); err != nil {
+				return err
+			}
+			mu.Lock()
+			decided[task.Destination] = action
+			mu.Unlock()
+		}
+
+		if action == restoreNew || action == restoreOverwrite {
+			if err := s.restoreFile(task, byDest[task.Destination]); err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		counts[action]++
+		mu.Unlock()
+		return nil
+	}
+
+	pool := NewWorkerPool(s.config.Concurrency, restoreFn, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, tasks)
+
+	summary := fmt.Sprintf("%d restored, %d overwritten, %d preserved, %d identical",
+		counts[restoreNew], counts[restoreOverwrite], counts[restorePreserve], counts[restoreSame])
+	if len(missing) > 0 {
+		summary += fmt.Sprintf(", %d missing from the backup", len(missing))
+	}
+	if len(unsafe) > 0 {
+		summary += fmt.Sprintf(", %d outside the destination", len(unsafe))
+	}
+	s.logger.Info("Restore of version %s to %s: %s", version.ID, destDir, summary)
+	if !s.config.Options.Quiet {
+		fmt.Printf("\nRestore of version %s to %s\n", version.ID, destDir)
+		fmt.Printf("Files: %s\n", summary)
+		if counts[restorePreserve] > 0 {
+			fmt.Printf("Existing files were kept under overwrite policy %q\n", s.overwritePolicy())
+		}
+		if len(missing) > 0 {
+			fmt.Printf("Missing from the backup (not restored):\n")
+			for _, key := range missing {
+				fmt.Printf("  %s\n", key)
+			}
+		}
+		if len(unsafe) > 0 {
+			fmt.Printf("Outside the destination (not restored):\n")
+			for _, key := range unsafe {
+				fmt.Printf("  %s\n", key)
+			}
+		}
+	}
+
+	err = errors.Join(append([]error{err}, keyErrs...)...)
+	if len(missing) > 0 {
+		missingErr := withSentinel(fmt.Errorf("%d files of version %s are missing from the backup", len(missing), version.ID), fs.ErrNotExist)
+		err = errors.Join(err, missingErr)
+	}
+	if err != nil {
+		return newBackupError("Restore", destDir, err)
+	}
+	return nil
+}
+
+// VersionSource returns the source directory a version was backed up from,
+// which is where its files originally lived
+func (s *Service) VersionSource(versionID string) (string, error) {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return "", err
+	}
+	if version.ConfigUsed.SourceDirectory == "" {
+		return "", fmt.Errorf("version %s does not record its source directory", version.ID)
+	}
+	return version.ConfigUsed.SourceDirectory, nil
+}
+
+// overwritePolicy returns the configured policy, defaulting to never
+func (s *Service) overwritePolicy() string {
+	if s.config.OverwritePolicy == "" {
+		return OverwriteNever
+	}
+	return s.config.OverwritePolicy
+}
+
+// restoreAction decides what restoring a file does: write it when the
+// destination is absent, leave it when already identical, and otherwise
+// apply the overwrite policy. "if-newer" compares the backup copy's
+// modification time with the existing file's.
+func (s *Service) restoreAction(task CopyTask, metadata FileMetadata) (string, error) {
+	destInfo, err := os.Stat(task.Destination)
+	if os.IsNotExist(err) {
+		return restoreNew, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	identical, err := s.restoreIdentical(task, metadata)
+	if err != nil {
+		return "", err
+	}
+	if identical {
+		return restoreSame, nil
+	}
+
+	switch s.overwritePolicy() {
+	case OverwriteAlways, OverwriteIfDifferent:
+		return restoreOverwrite, nil
+	case OverwriteIfNewer:
+		backupInfo, err := os.Stat(task.Source)
+		if err != nil {
+			return "", err
+		}
+		if backupInfo.ModTime().After(destInfo.ModTime()) {
+			return restoreOverwrite, nil
+		}
+		return restorePreserve, nil
+	default:
+		return restorePreserve, nil
+	}
+}
+
+// RestoreDryRun reports what Restore would do without writing anything: each
+// file it would write, which already exist at the destination and whether
+// they would be overwritten, kept by the overwrite policy, or are already
+// identical, and the total size. The details go to a dry-run log like DryRun's.
+func (s *Service) RestoreDryRun(ctx context.Context, versionID, destDir string) error {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return err
+	}
+
+	file, logFile, err := createDryRunLog("restore_dryrun", "Restore Dry Run",
+		"Version: "+version.ID,
+		"From: "+s.versionTarget(version),
+		"To: "+destDir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	root, cleanup, err := s.versionRoot(ctx, version)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var writeCount, overwriteCount, preserveCount, identicalCount, missingCount, unsafeCount int
+	var writeSize int64
+	for _, key := range sortedKeys(version.Files) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		metadata := version.Files[key]
+		if !localKey(key, metadata) {
+			unsafeCount++
+			fmt.Fprintf(file, "UNSAFE: %s (%v)\n", key, errKeyNotLocal)
+			continue
+		}
+		task := restoreTask(root, destDir, key, metadata)
+
+		if _, err := os.Stat(task.Source); err != nil {
+			missingCount++
+			fmt.Fprintf(file, "MISSING: %s (backup copy unavailable: %v)\n", task.Source, err)
+			continue
+		}
+
+		action, err := s.restoreAction(task, metadata)
+		if err != nil {
+			fmt.Fprintf(file, "ERROR: Cannot compare %s: %v\n", task.Destination, err)
+			continue
+		}
+
+		switch action {
+		case restoreSame:
+			identicalCount++
+			fmt.Fprintf(file, "IDENTICAL: %s\n", task.Destination)
+			continue
+		case restorePreserve:
+			preserveCount++
+			fmt.Fprintf(file, "PRESERVE: %s (exists, kept by overwrite policy)\n", task.Destination)
+			continue
+		case restoreOverwrite:
+			overwriteCount++
+			fmt.Fprintf(file, "OVERWRITE: %s -> %s (%.2f MB)\n",
+				task.Source, task.Destination, float64(task.Size)/1024/1024)
+		default:
+			fmt.Fprintf(file, "RESTORE: %s -> %s (%.2f MB)\n",
+				task.Source, task.Destination, float64(task.Size)/1024/1024)
+		}
+		writeCount++
+		writeSize += task.Size
+	}
+
+	fmt.Fprintf(file, "\n----------------------------------------\n")
+	fmt.Fprintf(file, "Summary:\n")
+	fmt.Fprintf(file, "Files to write: %d (%.2f MB), of which %d overwrite existing files\n",
+		writeCount, float64(writeSize)/1024/1024, overwriteCount)
+	fmt.Fprintf(file, "Existing files kept (overwrite policy %q): %d\n", s.overwritePolicy(), preserveCount)
+	fmt.Fprintf(file, "Already identical: %d\n", identicalCount)
+	if missingCount > 0 {
+		fmt.Fprintf(file, "Missing backup copies: %d\n", missingCount)
+	}
+	if unsafeCount > 0 {
+		fmt.Fprintf(file, "Outside the destination (not restored): %d\n", unsafeCount)
+	}
+
+	if !s.config.Options.Quiet {
+		fmt.Printf("\nRestore dry run of version %s to %s\n", version.ID, destDir)
+		fmt.Printf("Summary:\n")
+		fmt.Printf("- Files to write: %d (%.2f MB)\n", writeCount, float64(writeSize)/1024/1024)
+		fmt.Printf("- Existing files to overwrite: %d\n", overwriteCount)
+		fmt.Printf("- Existing files to keep (overwrite policy %q): %d\n", s.overwritePolicy(), preserveCount)
+		fmt.Printf("- Already identical: %d\n", identicalCount)
+		if missingCount > 0 {
+			fmt.Printf("- Missing backup copies: %d (listed in the log)\n", missingCount)
+		}
+		if unsafeCount > 0 {
+			fmt.Printf("- Outside the destination, not restored: %d (listed in the log)\n", unsafeCount)
+		}
+		fmt.Printf("\nDetailed analysis has been written to:\n%s\n", logFile)
+	}
+
+	return nil
+}
+
+// restoreIdentical reports whether an existing restore destination already
+// matches the backed-up file: by recorded checksum when there is one,
+// otherwise by comparing it with the backup copy byte for byte
+func (s *Service) restoreIdentical(task CopyTask, metadata FileMetadata) (bool, error) {
+	info, err := os.Stat(task.Destination)
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != task.Size {
+		return false, nil
+	}
+
+	if metadata.Checksum != "" {
+		checksum, err := s.calculateChecksum(task.Destination)
+		if err != nil {
+			return false, err
+		}
+		return checksum == metadata.Checksum, nil
+	}
+	return s.contentsMatch(task.Source, task.Destination)
+}
+
+// restoreFile copies one backup copy to its restore destination, keeping the
+// backup copy's file mode, or the attributes in its metadata sidecar. The
+// copy is written to a temp file beside the destination and checked against
+// the manifest before it replaces anything: re-hashed when a checksum was
+// recorded, otherwise by size.
+func (s *Service) restoreFile(task CopyTask, metadata FileMetadata) error {
+	if err := os.MkdirAll(filepath.Dir(task.Destination), 0755); err != nil {
+		return fmt.Errorf("failed to create restore directory: %w", err)
+	}
+
+	tempPath := task.Destination + copyTempSuffix
+	mode, err := s.restoreToTemp(task, tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	// Verified only after the copy's handles are released, as the re-hash
+	// takes its own and max_open_files may be as low as two
+	if err := s.verifyRestored(tempPath, metadata); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, mode); err != nil {
+		s.logger.Warn("Failed to preserve file mode for %s: %v", task.Destination, err)
+	}
+	if err := os.Rename(tempPath, task.Destination); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to move restored file into place: %w", err)
+	}
+
+	s.applySidecar(task.Source, task.Destination)
+
+	s.logger.Debug("Restored %s to %s", task.Source, task.Destination)
+	return nil
+}
+
+// restoreToTemp copies a backup copy to tempPath, returning the backup
+// copy's permissions
+func (s *Service) restoreToTemp(task CopyTask, tempPath string) (fs.FileMode, error) {
+	s.files.acquire(2)
+	defer s.files.release(2)
+
+	src, err := os.Open(task.Source)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup copy: %w", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat backup copy: %w", err)
+	}
+
+	dst, err := os.Create(tempPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create restored file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.CopyBuffer(dst, src, make([]byte, s.config.BufferSize)); err != nil {
+		return 0, fmt.Errorf("failed to restore file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close restored file: %w", err)
+	}
+	return info.Mode().Perm(), nil
+}
+
+// verifyRestored checks a freshly restored file against its manifest entry
+func (s *Service) verifyRestored(path string, metadata FileMetadata) error {
+	if metadata.Checksum == "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat restored file: %w", err)
+		}
+		if info.Size() != metadata.Size {
+			return fmt.Errorf("restored file is %d bytes, expected %d", info.Size(), metadata.Size)
+		}
+		return nil
+	}
+
+	checksum, err := s.calculateChecksum(path)
+	if err != nil {
+		return fmt.Errorf("failed to verify restored file: %w", err)
+	}
+	if checksum != metadata.Checksum {
+		return withSentinel(fmt.Errorf("restored file does not match the recorded checksum"), ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// TestRestore restores a version into a temporary directory, checks every
+// restored file against the manifest, and removes the directory again. Files
+// with a recorded checksum are re-hashed; others are compared by size.
+func (s *Service) TestRestore(ctx context.Context, versionID string) (*TestRestoreResult, error) {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return nil, err
+	}
+
+	scratch, err := os.MkdirTemp("", "backup-butler-restore-")
+	if err != nil {
+		return nil, newBackupError("TestRestore", "", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	result := &TestRestoreResult{VersionID: version.ID}
+	restoreErr := s.Restore(ctx, version.ID, scratch)
+
+	for key, metadata := range version.Files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if !localKey(key, metadata) {
+			result.Failed = append(result.Failed, key)
+			continue
+		}
+		restoredPath := filepath.Join(scratch, filepath.FromSlash(key))
+		info, err := os.Stat(restoredPath)
+		if err != nil {
+			s.logger.Warn("Test restore: %s was not restored: %v", key, err)
+			result.Failed = append(result.Failed, key)
+			continue
+		}
+		result.Restored++
+
+		if info.Size() != metadata.Size {
+			s.logger.Warn("Test restore: size mismatch for %s (expected %d, got %d)", key, metadata.Size, info.Size())
+			result.Failed = append(result.Failed, key)
+			continue
+		}
+		if metadata.Checksum == "" {
+			result.Unverified++
+			continue
+		}
+
+		checksum, err := s.calculateChecksum(restoredPath)
+		if err != nil {
+			return result, newBackupError("TestRestore", restoredPath, err)
+		}
+		if checksum != metadata.Checksum {
+			s.logger.Warn("Test restore: checksum mismatch for %s", key)
+			result.Failed = append(result.Failed, key)
+			continue
+		}
+		result.Verified++
+	}
+
+	sort.Strings(result.Failed)
+	if restoreErr != nil && result.OK() {
+		return result, restoreErr
+	}
+	return result, nil
+}
@@ -0,0 +1,245 @@
+// restore_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestore(t *testing.T) {
+	files := map[string]string{
+		"a.txt":     "alpha",
+		"sub/b.txt": "bravo bravo",
+		"sub/c.txt": "charlie",
+	}
+
+	tests := []struct {
+		name         string
+		maxOpenFiles int
+		concurrency  int
+	}{
+		{"unlimited handles", 0, 2},
+		// Copy and verify each need the handles in turn; holding the copy's
+		// two while verifying would deadlock
+		{"two handles", 2, 1},
+		{"two handles, several workers", 2, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.MaxOpenFiles = tt.maxOpenFiles
+			cfg.Concurrency = tt.concurrency
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+
+			dest := t.TempDir()
+			done := make(chan error, 1)
+			go func() { done <- s.Restore(context.Background(), result.VersionID, dest) }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Restore: %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Restore did not finish; file handles deadlocked")
+			}
+
+			for name, want := range files {
+				path := filepath.Join(dest, testFolder, filepath.FromSlash(name))
+				if got := readFile(t, path); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+				if _, err := os.Stat(path + copyTempSuffix); !os.IsNotExist(err) {
+					t.Errorf("%s: temp file left behind", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreKeepsExistingFileOnFailedVerify(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+	cfg.OverwritePolicy = OverwriteAlways
+	s := newTestService(t, cfg)
+	result := runBackup(t, s)
+
+	// Damage the backup copy without changing its size
+	if err := os.WriteFile(targetPath(cfg, "a.txt"), []byte("ALPHA"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	existing := filepath.Join(dest, testFolder, "a.txt")
+	writeFiles(t, filepath.Dir(existing), map[string]string{"a.txt": "local"})
+
+	if err := s.Restore(context.Background(), result.VersionID, dest); err == nil {
+		t.Fatal("Restore of a corrupt backup copy succeeded")
+	}
+	if got := readFile(t, existing); got != "local" {
+		t.Errorf("existing file = %q, want it untouched", got)
+	}
+	if _, err := os.Stat(existing + copyTempSuffix); !os.IsNotExist(err) {
+		t.Error("temp file left behind")
+	}
+}
+
+func TestRestoreIdenticalWithoutChecksum(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		backup   string
+		existing string
+		want     bool
+	}{
+		{"same content", "alpha bravo", "alpha bravo", true},
+		// Same size and modification time, which the backup skip check trusts
+		{"same size, different content", "alpha bravo", "alpha BRAVO", false},
+		{"different size", "alpha", "alpha bravo", false},
+		{"buffer multiple", "abcdefgh", "abcdefgh", true},
+		{"differs in last buffer", "abcdefghi", "abcdefghX", false},
+		{"empty", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			s := newTestService(t, cfg)
+			s.config.BufferSize = 4
+
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"backup": tt.backup, "existing": tt.existing})
+			task := CopyTask{
+				Source:      filepath.Join(dir, "backup"),
+				Destination: filepath.Join(dir, "existing"),
+				Size:        int64(len(tt.backup)),
+				ModTime:     modTime,
+			}
+			for _, path := range []string{task.Source, task.Destination} {
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := s.restoreIdentical(task, FileMetadata{Size: task.Size, ModTime: modTime})
+			if err != nil {
+				t.Fatalf("restoreIdentical: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("restoreIdentical = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestRestore(t *testing.T) {
+	files := map[string]string{"a.txt": "alpha", "sub/b.txt": "bravo"}
+	tests := []struct {
+		name       string
+		damage     func(t *testing.T, cfg *Config)
+		wantFailed []string
+	}{
+		{name: "intact"},
+		{
+			name: "corrupt copy",
+			damage: func(t *testing.T, cfg *Config) {
+				if err := os.WriteFile(targetPath(cfg, "a.txt"), []byte("ALPHA"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFailed: []string{"data/a.txt"},
+		},
+		{
+			name: "missing copy",
+			damage: func(t *testing.T, cfg *Config) {
+				if err := os.Remove(targetPath(cfg, "sub/b.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFailed: []string{"data/sub/b.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			s := newTestService(t, cfg)
+			version := runBackup(t, s).VersionID
+			if tt.damage != nil {
+				tt.damage(t, cfg)
+			}
+			scratch := t.TempDir()
+			t.Setenv("TMPDIR", scratch)
+
+			result, err := s.TestRestore(context.Background(), version)
+			if err != nil && result == nil {
+				t.Fatalf("TestRestore: %v", err)
+			}
+			if !slices.Equal(result.Failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", result.Failed, tt.wantFailed)
+			}
+			if result.OK() != (len(tt.wantFailed) == 0) {
+				t.Errorf("OK = %v with failures %v", result.OK(), result.Failed)
+			}
+			if want := len(files) - len(tt.wantFailed); result.Verified != want {
+				t.Errorf("verified %d files, want %d", result.Verified, want)
+			}
+			// The scratch restore is removed however it went
+			if entries, _ := os.ReadDir(scratch); len(entries) != 0 {
+				t.Errorf("temporary restore left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsKeysOutsideDestination(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		targetKey string
+	}{
+		{"parent directory", "../../escape.txt", ""},
+		{"absolute", "/escape.txt", ""},
+		{"backup copy outside the target", "data/escape.txt", "../../a.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+
+			// Add a crafted entry whose backup copy exists
+			version, err := s.GetVersion(result.VersionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			metadata := version.Files["data/a.txt"]
+			metadata.TargetKey = tt.targetKey
+			if metadata.TargetKey == "" {
+				metadata.TargetKey = "data/a.txt"
+			}
+			version.Files[tt.key] = metadata
+			if err := s.versioner.saveVersion(version); err != nil {
+				t.Fatal(err)
+			}
+
+			dest := filepath.Join(t.TempDir(), "one", "two")
+			err = newTestService(t, cfg).Restore(context.Background(), result.VersionID, dest)
+			if !errors.Is(err, errKeyNotLocal) {
+				t.Fatalf("Restore error = %v, want %v", err, errKeyNotLocal)
+			}
+			if !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("error %q does not name %s", err, tt.key)
+			}
+
+			if got := readFile(t, filepath.Join(dest, testFolder, "a.txt")); got != "alpha" {
+				t.Errorf("a.txt = %q, want %q", got, "alpha")
+			}
+			if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(tt.key))); !os.IsNotExist(err) {
+				t.Errorf("%s was restored", tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// roothash.go
+package backup
+
+import (
+	"encoding/hex"
+	"hash"
+	"sort"
+)
+
+// merkleRoot hashes a version's contents into one value: each leaf is the
+// hash of a path and its file checksum, taken in path order, and each level
+// hashes pairs of the one below, an odd node out moving up unchanged. Equal
+// roots mean equal paths and contents however the files were walked.
+func merkleRoot(newHash func() hash.Hash, checksums map[string]string) string {
+	if len(checksums) == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(checksums))
+	for path := range checksums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	level := make([][]byte, len(paths))
+	for i, path := range paths {
+		h := newHash()
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write([]byte(checksums[path]))
+		level[i] = h.Sum(nil)
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := newHash()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
+
+// recordRootHash stores the Merkle root of the version in progress. Files
+// skipped as unchanged take the checksum recorded when they were last
+// copied at the same size; if any file has none, no root is recorded.
+func (s *Service) recordRootHash() {
+	files := s.versioner.CurrentFiles()
+	history, err := s.buildChecksumIndex()
+	if err != nil {
+		s.logger.Warn("Root hash not recorded: %v", err)
+		return
+	}
+
+	checksums := make(map[string]string, len(files))
+	missing := 0
+	for key, metadata := range files {
+		checksum := metadata.Checksum
+		if previous, ok := history[key]; checksum == "" && ok && previous.Size == metadata.Size {
+			checksum = previous.Checksum
+		}
+		if checksum == "" {
+			missing++
+			continue
+		}
+		checksums[key] = checksum
+	}
+	if missing > 0 {
+		s.logger.Warn("Root hash not recorded: %d files have no checksum yet", missing)
+		return
+	}
+
+	root := merkleRoot(s.newHash, checksums)
+	s.versioner.SetRootHash(root)
+	s.logger.Info("Root hash %s", root)
+}
@@ -0,0 +1,166 @@
+// roothash_test.go
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	leaf := func(path, checksum string) []byte {
+		sum := sha256.Sum256([]byte(path + "\x00" + checksum))
+		return sum[:]
+	}
+	node := func(left, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+		return sum[:]
+	}
+	a, b, c := leaf("a.txt", "aa"), leaf("b.txt", "bb"), leaf("c.txt", "cc")
+
+	tests := []struct {
+		name      string
+		checksums map[string]string
+		want      string
+	}{
+		{"empty", map[string]string{}, ""},
+		{"one leaf", map[string]string{"a.txt": "aa"}, hex.EncodeToString(a)},
+		{"two leaves", map[string]string{"b.txt": "bb", "a.txt": "aa"}, hex.EncodeToString(node(a, b))},
+		// The odd leaf moves up unchanged and is paired one level higher
+		{"odd leaf count", map[string]string{"c.txt": "cc", "a.txt": "aa", "b.txt": "bb"}, hex.EncodeToString(node(node(a, b), c))},
+		{"golden", map[string]string{"a.txt": "aa", "b.txt": "bb", "c.txt": "cc"}, "a240ffdf112063976622f047a2707b88470aadb41a6ad2c0258789e978a443e0"},
+		{"content changed", map[string]string{"a.txt": "aa", "b.txt": "bb", "c.txt": "cX"}, hex.EncodeToString(node(node(a, b), leaf("c.txt", "cX")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := merkleRoot(sha256.New, tt.checksums); got != tt.want {
+				t.Errorf("merkleRoot = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootHashIgnoresWalkOrder(t *testing.T) {
+	files := map[string]string{
+		"a.txt":       "alpha",
+		"b/c.txt":     "charlie",
+		"b/d/e.txt":   "echo",
+		"z.txt":       "zulu",
+		"b/d/f/g.txt": "golf",
+	}
+	roots := make(map[string]bool)
+	for _, concurrency := range []int{1, 4} {
+		cfg := newTestConfig(t, files)
+		cfg.RootHash = true
+		cfg.Concurrency = concurrency
+		s := newTestService(t, cfg)
+		result := runBackup(t, s)
+		version, err := s.GetVersion(result.VersionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version.RootHash == "" {
+			t.Fatalf("concurrency %d: no root hash recorded", concurrency)
+		}
+		roots[version.RootHash] = true
+	}
+	if len(roots) != 1 {
+		t.Errorf("identical trees produced %d different roots", len(roots))
+	}
+}
+
+func TestDiffVersions(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	manifests := []BackupVersion{
+		{ID: "v1", RootHash: "root-1", Files: map[string]FileMetadata{
+			"data/a.txt": {Size: 5, Checksum: "aa"},
+			"data/b.txt": {Size: 5, Checksum: "bb"},
+		}},
+		{ID: "v2", RootHash: "root-1", Files: map[string]FileMetadata{
+			"data/a.txt": {Size: 5, Checksum: "aa"},
+			"data/b.txt": {Size: 5, Checksum: "bb"},
+		}},
+		{ID: "v3", RootHash: "root-3", Files: map[string]FileMetadata{
+			"data/b.txt": {Size: 5, Checksum: "bX"},
+			"data/c.txt": {Size: 7, Checksum: "cc"},
+		}},
+		{ID: "v4", Files: map[string]FileMetadata{
+			"data/b.txt": {Size: 5},
+			"data/c.txt": {Size: 7, Checksum: "cc"},
+		}},
+	}
+	saver := newTestService(t, cfg)
+	for i := range manifests {
+		if err := saver.versioner.saveVersion(&manifests[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		from, to  string
+		sameRoot  bool
+		unchanged int
+	}{
+		{"equal roots", "v1", "v2", true, 0},
+		// b.txt has no checksum in v4, so only its size is compared
+		{"different roots", "v3", "v4", false, 2},
+		{"no root recorded", "v4", "v4", false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A fresh service holds only the index of each version
+			s := newTestService(t, cfg)
+			if tt.sameRoot {
+				// Matching roots answer without reading either manifest
+				for _, id := range []string{tt.from, tt.to} {
+					if err := os.Truncate(s.versioner.existingVersionPath(id), 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			result, err := s.DiffVersions(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("DiffVersions: %v", err)
+			}
+			if result.SameRoot != tt.sameRoot {
+				t.Errorf("SameRoot = %v, want %v", result.SameRoot, tt.sameRoot)
+			}
+			if result.Unchanged != tt.unchanged || len(result.Added)+len(result.Modified)+len(result.Deleted) != 0 {
+				t.Errorf("result = %+v, want %d unchanged and no changes", result, tt.unchanged)
+			}
+		})
+	}
+
+	t.Run("changes listed", func(t *testing.T) {
+		s := newTestService(t, cfg)
+		if err := s.versioner.saveVersion(&BackupVersion{ID: "v5", RootHash: "root-5", Files: map[string]FileMetadata{
+			"data/a.txt": {Size: 5, Checksum: "aa"},
+			"data/b.txt": {Size: 5, Checksum: "bb"},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+		s = newTestService(t, cfg)
+		result, err := s.DiffVersions("v5", "v3")
+		if err != nil {
+			t.Fatalf("DiffVersions: %v", err)
+		}
+		for _, check := range []struct {
+			label string
+			got   []string
+			want  string
+		}{
+			{"Added", result.Added, "data/c.txt"},
+			{"Modified", result.Modified, "data/b.txt"},
+			{"Deleted", result.Deleted, "data/a.txt"},
+		} {
+			if len(check.got) != 1 || check.got[0] != check.want {
+				t.Errorf("%s = %v, want [%s]", check.label, check.got, check.want)
+			}
+		}
+	})
+
+	if _, err := newTestService(t, cfg).DiffVersions("v3", "missing"); err == nil {
+		t.Error("DiffVersions of an unknown version succeeded")
+	}
+}
@@ -0,0 +1,101 @@
+// runsummary.go
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// RunSummary is the single machine-readable record written to the operation
+// log at the end of every backup run. It is also the data SummaryTemplate is
+// rendered against.
+type RunSummary struct {
+	VersionID   string         `json:"version_id"`
+	Status      string         `json:"status"`
+	Partial     bool           `json:"partial"`
+	StartTime   time.Time      `json:"start_time"`
+	Duration    time.Duration  `json:"duration"`
+	Stats       BackupStats    `json:"stats"`
+	AverageMBps float64        `json:"average_mbps"`
+	PeakMBps    float64        `json:"peak_mbps"`
+	Retries     int64          `json:"retries"`
+	Errors      map[string]int `json:"errors,omitempty"`     // Failure count per error category
+	Targets     []TargetStats  `json:"targets,omitempty"`    // Per-target outcomes when copying to additional targets
+	SlowFiles   []SlowFile     `json:"slow_files,omitempty"` // Copies slower than SlowFileThreshold, slowest first
+}
+
+// BackupResult is what BackupWithResult returns to callers embedding the
+// package: the run's summary plus every file that failed to copy
+type BackupResult struct {
+	RunSummary
+	FailedFiles []string `json:"failed_files,omitempty"` // Source paths of failed tasks, in the order they failed
+}
+
+// runSummary collects the end-of-run figures for the log record and the
+// summary template
+func (s *Service) runSummary(versionID, status string, stats BackupStats, failures *ErrorSummary) RunSummary {
+	duration := s.metrics.GetDuration()
+	record := RunSummary{
+		VersionID: versionID,
+		Status:    status,
+		Partial:   status == StatusPartial,
+		StartTime: s.metrics.GetStartTime(),
+		Duration:  duration,
+		Stats:     stats,
+		PeakMBps:  s.metrics.PeakMBps(),
+		Retries:   s.pool.Retries(),
+	}
+	if s.checkPool != nil {
+		record.Retries += s.checkPool.Retries()
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		record.AverageMBps = float64(stats.BytesTransferred) / 1024 / 1024 / seconds
+	}
+	if failures != nil {
+		record.Errors = failures.Counts()
+	}
+	if len(s.config.AdditionalTargets) > 0 {
+		record.Targets = s.metrics.TargetStats()
+	}
+	record.SlowFiles = s.metrics.SlowFiles()
+	return record
+}
+
+// logRunSummary writes the end-of-run summary as a JSON line tagged SUMMARY
+func (s *Service) logRunSummary(record RunSummary) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("Failed to encode run summary: %v", err)
+		return
+	}
+	s.logger.Record("SUMMARY", string(data))
+}
+
+// displaySummary prints the final summary using SummaryTemplate when set,
+// falling back to the built-in format if it is unset or fails to render
+func (s *Service) displaySummary(record RunSummary) {
+	if s.config.SummaryTemplate == "" || s.config.Options.Quiet {
+		s.metrics.DisplayFinalSummary()
+		return
+	}
+
+	var out strings.Builder
+	tmpl, err := template.New("summary").Parse(s.config.SummaryTemplate)
+	if err == nil {
+		err = tmpl.Execute(&out, record)
+	}
+	if err != nil {
+		s.logger.Error("Failed to render summary template: %v", err)
+		s.metrics.DisplayFinalSummary()
+		return
+	}
+
+	text := out.String()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	fmt.Printf("\n\n%s", text)
+}
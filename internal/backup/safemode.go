@@ -0,0 +1,52 @@
+// safemode.go
+package backup
+
+import "os"
+
+// safeModeSuffix names the sidecar a changed file is written to in safe mode
+// instead of overwriting the existing backup copy
+const safeModeSuffix = ".new"
+
+// applySafeMode turns off every setting that deletes from the target when
+// safe_mode is on, and returns the names of the settings it disabled
+func applySafeMode(cfg *Config) []string {
+	if !cfg.SafeMode {
+		return nil
+	}
+
+	var disabled []string
+	if cfg.MirrorMode {
+		cfg.MirrorMode = false
+		disabled = append(disabled, "mirror_mode")
+	}
+	if cfg.TrashRetentionDays > 0 {
+		cfg.TrashRetentionDays = 0
+		disabled = append(disabled, "trash_retention_days")
+	}
+	if cfg.RetentionMaxBytes > 0 {
+		cfg.RetentionMaxBytes = 0
+		disabled = append(disabled, "retention_max_bytes")
+	}
+	if cfg.LogRetentionCount > 0 || cfg.LogRetentionDays > 0 {
+		cfg.LogRetentionCount, cfg.LogRetentionDays = 0, 0
+		disabled = append(disabled, "log retention")
+	}
+	return disabled
+}
+
+// safeModeDestination redirects a copy that would overwrite an existing file
+// to a .new sidecar beside it. A sidecar left by an earlier run is replaced.
+func (s *Service) safeModeDestination(task CopyTask) CopyTask {
+	if !s.config.SafeMode {
+		return task
+	}
+	if _, err := os.Lstat(task.Destination); err != nil {
+		return task
+	}
+
+	sidecar := task.Destination + safeModeSuffix
+	s.logger.Info("Safe mode: not overwriting %s; writing %s for review", task.Destination, sidecar)
+	s.metrics.IncrementWrittenAsNew()
+	task.Destination = sidecar
+	return task
+}
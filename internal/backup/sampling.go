@@ -0,0 +1,46 @@
+// sampling.go
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// verifySampler picks which otherwise-matching files get a full checksum
+// comparison. Each file is picked by a hash of the seed and its relative
+// path, so a fixed seed samples the same files every run, whatever order the
+// workers reach them in. It is safe for concurrent use.
+type verifySampler struct {
+	rate float64
+	seed [8]byte
+}
+
+// newVerifySampler returns a sampler for the given rate (0.0-1.0). A zero
+// seed seeds from the clock; any other seed gives a reproducible selection.
+func newVerifySampler(rate float64, seed int64) *verifySampler {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	v := &verifySampler{rate: rate}
+	binary.BigEndian.PutUint64(v.seed[:], uint64(seed))
+	return v
+}
+
+// sample reports whether the file recorded under key should be fully
+// checksummed
+func (v *verifySampler) sample(key string) bool {
+	if v == nil || v.rate <= 0 {
+		return false
+	}
+	if v.rate >= 1 {
+		return true
+	}
+
+	hash := sha256.New()
+	hash.Write(v.seed[:])
+	hash.Write([]byte(key))
+	// The top 53 bits as a fraction in [0, 1)
+	fraction := float64(binary.BigEndian.Uint64(hash.Sum(nil))>>11) / (1 << 53)
+	return fraction < v.rate
+}
@@ -0,0 +1,81 @@
+// sampling_test.go
+package backup
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// sampleKeys returns which of keys v samples, visited in the given order
+func sampleKeys(v *verifySampler, keys []string, order []int) map[string]bool {
+	picked := make(map[string]bool)
+	for _, i := range order {
+		if v.sample(keys[i]) {
+			picked[keys[i]] = true
+		}
+	}
+	return picked
+}
+
+func TestVerifySampler(t *testing.T) {
+	keys := make([]string, 2000)
+	inOrder := make([]int, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("data/photos/%04d.jpg", i)
+		inOrder[i] = i
+	}
+	shuffled := rand.New(rand.NewSource(1)).Perm(len(keys))
+
+	tests := []struct {
+		name     string
+		rate     float64
+		min, max int // Expected range of sampled files
+	}{
+		{"off", 0, 0, 0},
+		{"all", 1, len(keys), len(keys)},
+		{"tenth", 0.1, 140, 260},
+		{"half", 0.5, 900, 1100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := sampleKeys(newVerifySampler(tt.rate, 42), keys, inOrder)
+			if n := len(first); n < tt.min || n > tt.max {
+				t.Errorf("sampled %d of %d files, want %d-%d", n, len(keys), tt.min, tt.max)
+			}
+
+			// Another run with the same seed, reaching files in another
+			// order, picks exactly the same files
+			again := sampleKeys(newVerifySampler(tt.rate, 42), keys, shuffled)
+			if len(again) != len(first) {
+				t.Fatalf("same seed sampled %d files, then %d", len(first), len(again))
+			}
+			for key := range first {
+				if !again[key] {
+					t.Fatalf("same seed sampled %s only once", key)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifySamplerSeeds(t *testing.T) {
+	keys := make([]string, 200)
+	order := make([]int, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("file%d", i)
+		order[i] = i
+	}
+	a := sampleKeys(newVerifySampler(0.5, 1), keys, order)
+	b := sampleKeys(newVerifySampler(0.5, 2), keys, order)
+
+	differ := 0
+	for _, key := range keys {
+		if a[key] != b[key] {
+			differ++
+		}
+	}
+	if differ == 0 {
+		t.Error("different seeds sampled the same files")
+	}
+}
@@ -1,12 +1,41 @@
 // service.go
 package backup
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
 
 // NewService creates a new backup service instance
 // service.go
 func NewService(cfg *Config) (*Service, error) {
-	logger, err := NewLogger(cfg.TargetDirectory)
+	// Resolving the target and applying safe mode change the service's own
+	// copy, so the caller's config can create further services
+	c := *cfg
+	cfg = &c
+	if cfg.Options == nil {
+		cfg.Options = &Options{}
+	}
+
+	// Snapshot mode gives each run its own timestamped directory
+	if cfg.SnapshotMode {
+		cfg.TargetDirectory = filepath.Join(cfg.TargetDirectory, snapshotDirTemplate)
+	}
+
+	// Resolve a templated target once, at the start of the run
+	var targetTemplate string
+	if isTargetTemplate(cfg.TargetDirectory) {
+		targetTemplate = cfg.TargetDirectory
+		resolved, err := expandTargetTemplate(cfg.TargetDirectory, time.Now())
+		if err != nil {
+			return nil, withSentinel(newBackupError("ExpandTarget", cfg.TargetDirectory, err), ErrInvalidConfig)
+		}
+		cfg.TargetDirectory = resolved
+	}
+	baseDir := cfg.versionBase(targetTemplate)
+
+	logger, err := NewLogger(baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create logger: %v", err)
 	}
@@ -15,15 +44,37 @@ func NewService(cfg *Config) (*Service, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	versioner, err := NewVersionManager(cfg.TargetDirectory)
+	if warning := concurrencyWarning(cfg); warning != "" {
+		logger.Warn("%s", warning)
+	}
+	for _, setting := range applySafeMode(cfg) {
+		logger.Warn("safe_mode: %s disabled", setting)
+	}
+
+	versioner, err := NewVersionManager(baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create version manager: %v", err)
 	}
+	versioner.compress = cfg.CompressVersions
+	versioner.latestLink = cfg.LatestLink
+	for _, err := range versioner.SkippedVersions() {
+		logger.Warn("Skipping unreadable version: %v (run --rebuild-index to inspect)", err)
+	}
 
 	s := &Service{
 		config:    cfg,
 		logger:    logger,
 		versioner: versioner,
+		files:     newFileLimiter(cfg.MaxOpenFiles),
+		sampler:   newVerifySampler(cfg.VerifySampleRate, cfg.VerifySampleSeed),
+		ignores:   newIgnoreFiles(logger),
+		regexps:   newRegexpCache(),
+
+		targetTemplate: targetTemplate,
+	}
+
+	if _, err := s.CleanupLogs(); err != nil {
+		logger.Error("Failed to apply log retention: %v", err)
 	}
 
 	s.pool = NewWorkerPool(
@@ -32,6 +83,13 @@ func NewService(cfg *Config) (*Service, error) {
 		cfg.RetryAttempts,
 		cfg.RetryDelay,
 	)
+	s.pool.SetFileTimeout(cfg.PerFileTimeout)
+	s.pool.SetRampUp(cfg.WorkerRampUp)
+	if cfg.AdaptiveConcurrency {
+		s.pool.EnableAdaptive(func(workers int, mbps float64) {
+			logger.Info("Adaptive concurrency: %d workers after %.2f MB/s", workers, mbps)
+		})
+	}
 
 	return s, nil
 }
@@ -55,9 +113,35 @@ func (s *Service) GetLatestVersion() (*BackupVersion, error) {
 	if s.versioner == nil {
 		return nil, fmt.Errorf("version manager not initialized")
 	}
-	latest := s.versioner.GetLatestVersion()
+	latest, err := s.versioner.GetLatestVersion()
+	if err != nil {
+		return nil, err
+	}
 	if latest == nil {
 		return nil, fmt.Errorf("no backup versions found")
 	}
 	return latest, nil
 }
+
+// RebuildIndex validates every version file and reloads the version history,
+// moving corrupt files to .versions/.quarantine when quarantineBad is set
+func (s *Service) RebuildIndex(quarantineBad bool) (*IndexReport, error) {
+	if s.versioner == nil {
+		return nil, fmt.Errorf("version manager not initialized")
+	}
+	report, err := s.versioner.RebuildIndex(quarantineBad)
+	if report != nil {
+		for _, path := range report.Quarantined {
+			s.logger.Warn("Quarantined corrupt version file to %s", path)
+		}
+	}
+	return report, err
+}
+
+// GetLifetimeStats returns statistics accumulated across all backups to the target
+func (s *Service) GetLifetimeStats() (*LifetimeStats, error) {
+	if s.versioner == nil {
+		return nil, fmt.Errorf("version manager not initialized")
+	}
+	return s.versioner.LifetimeStats()
+}
@@ -0,0 +1,126 @@
+// sidecar.go
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// sidecarSuffix names the metadata sidecar written beside a backup copy
+// under metadata_sidecar
+const sidecarSuffix = ".fsmeta"
+
+// fileAttributes is what a sidecar records about a source file, for targets
+// that can't hold it themselves (FAT32 has no permissions or owners, many
+// filesystems no extended attributes)
+type fileAttributes struct {
+	Mode    os.FileMode       `json:"mode"`
+	ModTime time.Time         `json:"mod_time"`
+	UID     *int              `json:"uid,omitempty"`
+	GID     *int              `json:"gid,omitempty"`
+	Xattrs  map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// isSidecarOf reports whether path is the sidecar of a file in expected
+func isSidecarOf(path string, expected map[string]bool) bool {
+	return strings.HasSuffix(path, sidecarSuffix) && expected[strings.TrimSuffix(path, sidecarSuffix)]
+}
+
+// sourceAttributes collects the attributes of the source file at path
+func sourceAttributes(path string, info os.FileInfo) (fileAttributes, error) {
+	attrs := fileAttributes{
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	if uid, gid, ok := fileOwner(info); ok {
+		attrs.UID, attrs.GID = &uid, &gid
+	}
+	xattrs, err := readXattrs(path)
+	if err != nil {
+		return attrs, fmt.Errorf("failed to read extended attributes: %w", err)
+	}
+	attrs.Xattrs = xattrs
+	return attrs, nil
+}
+
+// writeSidecar records the attributes of task's source beside its backup
+// copy. An unchanged sidecar is left alone, so skipped files cost one read.
+// Failures are logged: the copy itself is still good.
+func (s *Service) writeSidecar(task CopyTask, info os.FileInfo) {
+	attrs, err := sourceAttributes(task.Source, info)
+	if err != nil {
+		s.logger.Warn("Metadata sidecar for %s: %v", task.Source, err)
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		s.logger.Warn("Failed to encode metadata sidecar for %s: %v", task.Source, err)
+		return
+	}
+
+	path := task.Destination + sidecarSuffix
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return
+	}
+	tempPath := path + copyTempSuffix
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		s.logger.Warn("Failed to write metadata sidecar %s: %v", path, err)
+		return
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		s.logger.Warn("Failed to write metadata sidecar %s: %v", path, err)
+	}
+}
+
+// recordAttributes writes the sidecar for a task whose backup copy was kept
+// or linked rather than copied
+func (s *Service) recordAttributes(task CopyTask) {
+	if !s.config.MetadataSidecar {
+		return
+	}
+	info, err := os.Stat(task.Source)
+	if err != nil {
+		s.logger.Warn("Metadata sidecar for %s: %v", task.Source, err)
+		return
+	}
+	s.writeSidecar(task, info)
+}
+
+// applySidecar restores the attributes recorded beside a backup copy onto
+// the restored file, if the copy has a sidecar. Owners are only restored
+// when permitted, normally when running as root.
+func (s *Service) applySidecar(backupPath, restoredPath string) {
+	data, err := os.ReadFile(backupPath + sidecarSuffix)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		s.logger.Warn("Failed to read metadata sidecar for %s: %v", backupPath, err)
+		return
+	}
+	var attrs fileAttributes
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		s.logger.Warn("Invalid metadata sidecar for %s: %v", backupPath, err)
+		return
+	}
+
+	for name, value := range attrs.Xattrs {
+		if err := setXattr(restoredPath, name, value); err != nil {
+			s.logger.Warn("Failed to restore attribute %s on %s: %v", name, restoredPath, err)
+		}
+	}
+	if attrs.UID != nil && attrs.GID != nil {
+		if err := os.Lchown(restoredPath, *attrs.UID, *attrs.GID); err != nil && !os.IsPermission(err) {
+			s.logger.Warn("Failed to restore owner of %s: %v", restoredPath, err)
+		}
+	}
+	if err := os.Chmod(restoredPath, attrs.Mode); err != nil {
+		s.logger.Warn("Failed to restore mode of %s: %v", restoredPath, err)
+	}
+	if err := os.Chtimes(restoredPath, time.Now(), attrs.ModTime); err != nil {
+		s.logger.Warn("Failed to restore modification time of %s: %v", restoredPath, err)
+	}
+}
@@ -0,0 +1,99 @@
+// sincelast_test.go
+package backup
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSinceLast(t *testing.T) {
+	// The previous backup started an hour ago
+	backupStart := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name       string
+		sinceLast  bool
+		modTime    time.Time
+		write      string // New source content, if any
+		wantCopied bool   // Whether the run copies the file again
+	}{
+		{"unmodified since the backup", true, backupStart.Add(-time.Hour), "", false},
+		{"without --since-last", false, backupStart.Add(-time.Hour), "", true},
+		{"modified after the backup", true, backupStart.Add(30 * time.Minute), "", true},
+		// Within sinceLastSkew of the backup start, in case the clocks differ
+		{"modified just before the backup", true, backupStart.Add(-time.Minute), "", true},
+		{"dated in the future", true, time.Now().Add(time.Hour), "", true},
+		{"resized with an old date", true, backupStart.Add(-time.Hour), "alpha, longer", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+			first := runBackup(t, newTestService(t, cfg))
+
+			// Backdate the previous version so files can be older or newer
+			// than it without waiting
+			s := newTestService(t, cfg)
+			version, err := s.GetVersion(first.VersionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			version.Timestamp = backupStart
+			if err := s.versioner.saveVersion(version); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.write != "" {
+				writeFiles(t, sourcePath(cfg, ""), map[string]string{"a.txt": tt.write})
+			}
+			if err := os.Chtimes(sourcePath(cfg, "a.txt"), tt.modTime, tt.modTime); err != nil {
+				t.Fatal(err)
+			}
+			// With its target copy gone, the file is only copied again if the
+			// run looks at the target
+			if err := os.Remove(targetPath(cfg, "a.txt")); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg.Options.SinceLast = tt.sinceLast
+			second := runBackup(t, newTestService(t, cfg))
+
+			_, err = os.Stat(targetPath(cfg, "a.txt"))
+			if copied := err == nil; copied != tt.wantCopied {
+				t.Errorf("a.txt copied again = %v, want %v", copied, tt.wantCopied)
+			}
+
+			// The version records which backup it trusted
+			recorded, err := newTestService(t, cfg).GetVersion(second.VersionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantBase := ""
+			if tt.sinceLast {
+				wantBase = first.VersionID
+			}
+			if recorded.SinceLast != wantBase {
+				t.Errorf("SinceLast = %q, want %q", recorded.SinceLast, wantBase)
+			}
+		})
+	}
+}
+
+func TestSinceLastFirstRun(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+	cfg.Options.SinceLast = true
+	s := newTestService(t, cfg)
+	result := runBackup(t, s)
+
+	// With nothing to trust every file is checked and copied
+	if got := readFile(t, targetPath(cfg, "a.txt")); got != "alpha" {
+		t.Errorf("a.txt = %q, want %q", got, "alpha")
+	}
+	version, err := s.GetVersion(result.VersionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.SinceLast != "" {
+		t.Errorf("SinceLast = %q for a run with no earlier backup", version.SinceLast)
+	}
+}
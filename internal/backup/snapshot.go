@@ -0,0 +1,97 @@
+// snapshot.go
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Filesystem types that support read-only source snapshots
+const (
+	fsBtrfs = "btrfs"
+	fsZFS   = "zfs"
+)
+
+// sourceSnapshot is a read-only view of the source directory taken for the
+// duration of one backup
+type sourceSnapshot struct {
+	kind    string // Filesystem type the snapshot was taken on
+	path    string // Snapshot location corresponding to SourceDirectory
+	release func(ctx context.Context) error
+}
+
+// takeSnapshot creates a read-only snapshot of the source directory when its
+// filesystem supports it
+func (s *Service) takeSnapshot(ctx context.Context) (*sourceSnapshot, error) {
+	fsType, err := filesystemType(s.config.SourceDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "backup-butler-" + time.Now().Format("20060102-150405")
+	switch fsType {
+	case fsBtrfs:
+		return snapshotBtrfs(ctx, s.config.SourceDirectory, name)
+	case fsZFS:
+		return snapshotZFS(ctx, s.config.SourceDirectory, name)
+	default:
+		return nil, fmt.Errorf("snapshots are not supported on %s filesystems", fsType)
+	}
+}
+
+// snapshotBtrfs snapshots a btrfs subvolume next to the source directory
+func snapshotBtrfs(ctx context.Context, source, name string) (*sourceSnapshot, error) {
+	snapPath := filepath.Join(filepath.Dir(source), "."+name)
+	if out, err := exec.CommandContext(ctx, "btrfs", "subvolume", "snapshot", "-r", source, snapPath).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("btrfs snapshot failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return &sourceSnapshot{
+		kind: fsBtrfs,
+		path: snapPath,
+		release: func(ctx context.Context) error {
+			if out, err := exec.CommandContext(ctx, "btrfs", "subvolume", "delete", snapPath).CombinedOutput(); err != nil {
+				return fmt.Errorf("btrfs snapshot delete failed: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		},
+	}, nil
+}
+
+// snapshotZFS snapshots the dataset holding the source and reads it through
+// the dataset's .zfs/snapshot directory
+func snapshotZFS(ctx context.Context, source, name string) (*sourceSnapshot, error) {
+	out, err := exec.CommandContext(ctx, "zfs", "list", "-H", "-o", "name,mountpoint", source).Output()
+	if err != nil {
+		return nil, fmt.Errorf("zfs dataset lookup failed: %w", err)
+	}
+	dataset, mountpoint, ok := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	if !ok {
+		return nil, fmt.Errorf("unexpected zfs list output: %q", out)
+	}
+
+	relPath, err := filepath.Rel(mountpoint, source)
+	if err != nil {
+		return nil, err
+	}
+
+	snapName := dataset + "@" + name
+	if out, err := exec.CommandContext(ctx, "zfs", "snapshot", snapName).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("zfs snapshot failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return &sourceSnapshot{
+		kind: fsZFS,
+		path: filepath.Join(mountpoint, ".zfs", "snapshot", name, relPath),
+		release: func(ctx context.Context) error {
+			if out, err := exec.CommandContext(ctx, "zfs", "destroy", snapName).CombinedOutput(); err != nil {
+				return fmt.Errorf("zfs destroy failed: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		},
+	}, nil
+}
@@ -0,0 +1,21 @@
+// snapshot_darwin.go
+package backup
+
+import "syscall"
+
+// filesystemType returns the type of the filesystem holding path (e.g. "apfs", "zfs")
+func filesystemType(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name), nil
+}
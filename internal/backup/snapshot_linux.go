@@ -0,0 +1,30 @@
+// snapshot_linux.go
+package backup
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Filesystem magic numbers from statfs(2)
+const (
+	btrfsSuperMagic = 0x9123683e
+	zfsSuperMagic   = 0x2fc12fc1
+)
+
+// filesystemType returns the type of the filesystem holding path
+func filesystemType(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+
+	switch stat.Type {
+	case btrfsSuperMagic:
+		return fsBtrfs, nil
+	case zfsSuperMagic:
+		return fsZFS, nil
+	default:
+		return fmt.Sprintf("0x%x", stat.Type), nil
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+// snapshot_other.go
+package backup
+
+import "fmt"
+
+// filesystemType is not implemented on this platform
+func filesystemType(path string) (string, error) {
+	return "", fmt.Errorf("filesystem detection is not supported on this platform")
+}
@@ -0,0 +1,67 @@
+// snapshotmode.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// snapshotDirTemplate is the per-run directory snapshot_mode adds below the
+// target, so each run lands in target/<timestamp>/
+//
+// Every snapshot is a complete, browsable copy of the source, but a file
+// unchanged since the previous snapshot is hard-linked to it rather than
+// copied: it takes a directory entry, not a second copy of its data. Only
+// new and changed files use new space, so a snapshot costs about as much as
+// an incremental run. Space is freed only when the last snapshot linking a
+// file is deleted. Linked files share one inode, so editing a file in place
+// inside one snapshot changes it in all of them; snapshots are meant to be
+// read, not modified. Hard links need every snapshot on one filesystem.
+const snapshotDirTemplate = "{{.Timestamp}}"
+
+// previousSnapshot returns the directory of the newest earlier file-tree
+// snapshot that still exists, or "" when there is none
+func (s *Service) previousSnapshot() string {
+	versions := s.GetVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Archive != "" {
+			continue
+		}
+		dir := s.versionTarget(&versions[i])
+		if dir == s.config.TargetDirectory {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// linkUnchanged hard-links a missing destination to the previous snapshot's
+// copy when the skip check finds that copy identical to the source. It
+// reports whether it did; otherwise the file is copied as usual.
+func (s *Service) linkUnchanged(task CopyTask) bool {
+	relPath, err := filepath.Rel(s.config.TargetDirectory, task.Destination)
+	if err != nil {
+		return false
+	}
+	previous := task
+	previous.Destination = filepath.Join(s.linkDest, relPath)
+	if info, err := os.Lstat(previous.Destination); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if same, err := s.shouldSkipFile(previous); err != nil || !same {
+		return false
+	}
+
+	if err := s.prepareDestDir(filepath.Dir(task.Destination)); err != nil {
+		return false
+	}
+	if err := os.Link(previous.Destination, task.Destination); err != nil {
+		s.logger.Debug("Cannot link %s to %s, copying instead: %v", task.Destination, previous.Destination, err)
+		return false
+	}
+	s.explain(task.Source, decisionSkip, "unchanged since the previous snapshot, linked to %s", previous.Destination)
+	return true
+}
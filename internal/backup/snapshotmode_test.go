@@ -0,0 +1,87 @@
+// snapshotmode_test.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotMode(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{
+		"same.txt":     "unchanged",
+		"sub/same.txt": "unchanged too",
+		"edited.txt":   "before",
+		"removed.txt":  "gone later",
+	})
+	cfg.SnapshotMode = true
+
+	// Each service resolves its own snapshot directory from the base,
+	// leaving cfg as it was
+	base := cfg.TargetDirectory
+	snapshot := func() *Service {
+		s := newTestService(t, cfg)
+		runBackup(t, s)
+		if cfg.TargetDirectory != base {
+			t.Fatalf("target_directory changed to %s", cfg.TargetDirectory)
+		}
+		return s
+	}
+	first := snapshot()
+
+	writeFiles(t, sourcePath(cfg, ""), map[string]string{"edited.txt": "after, longer", "added.txt": "new"})
+	if err := os.Remove(sourcePath(cfg, "removed.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// Snapshot directories are named to the second
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	second := snapshot()
+
+	firstDir, secondDir := first.config.TargetDirectory, second.config.TargetDirectory
+	if firstDir == secondDir || filepath.Dir(firstDir) != filepath.Dir(secondDir) {
+		t.Fatalf("snapshots %s and %s are not sibling directories", firstDir, secondDir)
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		wantFirst  string // "" if absent from the first snapshot
+		wantSecond string // "" if absent from the second snapshot
+		wantShared bool
+	}{
+		{"unchanged file", "same.txt", "unchanged", "unchanged", true},
+		{"unchanged nested file", "sub/same.txt", "unchanged too", "unchanged too", true},
+		{"changed file", "edited.txt", "before", "after, longer", false},
+		{"new file", "added.txt", "", "new", false},
+		{"deleted file", "removed.txt", "gone later", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var infos []os.FileInfo
+			for _, check := range []struct {
+				dir, want string
+			}{{firstDir, tt.wantFirst}, {secondDir, tt.wantSecond}} {
+				path := filepath.Join(check.dir, testFolder, filepath.FromSlash(tt.file))
+				info, err := os.Stat(path)
+				if check.want == "" {
+					if err == nil {
+						t.Errorf("%s exists in %s", tt.file, filepath.Base(check.dir))
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readFile(t, path); got != check.want {
+					t.Errorf("%s in %s = %q, want %q", tt.file, filepath.Base(check.dir), got, check.want)
+				}
+				infos = append(infos, info)
+			}
+			shared := len(infos) == 2 && os.SameFile(infos[0], infos[1])
+			if shared != tt.wantShared {
+				t.Errorf("%s shares an inode across snapshots = %v, want %v", tt.file, shared, tt.wantShared)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// sourcediff.go
+package backup
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// SourceDiffResult describes how the live source differs from a stored
+// version: what a backup now would add, change or no longer find
+type SourceDiffResult struct {
+	VersionID string
+	New       []string // Source files the version doesn't have
+	Modified  []string // Files whose size or checksum differs from the version
+	Deleted   []string // Files in the version that are gone from the source
+	Unchanged int
+}
+
+// DiffSource compares a version's manifest with the source as it is now,
+// walking it with the configured folders and exclusions. Files are compared
+// by size, and by checksum where the version recorded one; files the version
+// skipped as unchanged have no checksum and are compared by size only.
+// Paths are manifest keys, relative to the source directory.
+func (s *Service) DiffSource(ctx context.Context, versionID string) (*SourceDiffResult, error) {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return nil, err
+	}
+
+	result := &SourceDiffResult{VersionID: version.ID}
+	seen := make(map[string]bool, len(version.Files))
+	var toHash []CopyTask
+	_, err = s.walkTasks(func(task CopyTask) error {
+		key := s.manifestKey(task.Source)
+		seen[key] = true
+		metadata, ok := version.Files[key]
+		switch {
+		case !ok:
+			result.New = append(result.New, key)
+		case metadata.Size != task.Size:
+			result.Modified = append(result.Modified, key)
+		case metadata.Checksum != "":
+			toHash = append(toHash, task)
+		default:
+			result.Unchanged++
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, newBackupError("DiffSource", s.config.SourceDirectory, err)
+	}
+
+	var mu sync.Mutex
+	hashFn := func(task CopyTask) error {
+		key := s.manifestKey(task.Source)
+		checksum, err := s.calculateChecksumContext(ctx, task.Source)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if checksum != version.Files[key].Checksum {
+			result.Modified = append(result.Modified, key)
+		} else {
+			result.Unchanged++
+		}
+		return nil
+	}
+	pool := NewWorkerPool(s.config.Concurrency, hashFn, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, toHash)
+
+	for key := range version.Files {
+		if !seen[key] {
+			result.Deleted = append(result.Deleted, key)
+		}
+	}
+	sort.Strings(result.New)
+	sort.Strings(result.Modified)
+	sort.Strings(result.Deleted)
+	if err != nil {
+		return result, newBackupError("DiffSource", version.ID, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+// syncdir_unix.go
+package backup
+
+import "os"
+
+// syncDir flushes dir's entries to disk, making a rename into it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+// syncdir_windows.go
+package backup
+
+// syncDir is a no-op on Windows: directories can't be opened for syncing,
+// and NTFS journals renames itself
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,93 @@
+// targettemplate.go
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TargetPlaceholders are the values a templated target_directory can use,
+// e.g. "/backups/{{.Date}}". They are resolved once when the service starts.
+//
+// Each resolved directory starts out empty, so the skip check has nothing to
+// compare against and every run into a new directory is a full copy; runs
+// that resolve to the same directory (two runs on one Date) are incremental
+// as usual. Mirror mode, trash and quarantine act only on the current run's
+// directory. Version history and logs live in version_directory, which
+// defaults to the fixed part of the path above the first placeholder, so
+// earlier versions stay listed and verifiable in their own directories.
+type TargetPlaceholders struct {
+	Date      string // 2006-01-02
+	Timestamp string // 20060102-150405, matching version IDs
+	Year      string
+	Month     string
+	Day       string
+	Hostname  string
+}
+
+// isTargetTemplate reports whether a target directory contains placeholders
+func isTargetTemplate(dir string) bool {
+	return strings.Contains(dir, "{{")
+}
+
+// expandTargetTemplate resolves the placeholders in a target directory
+func expandTargetTemplate(dir string, now time.Time) (string, error) {
+	tmpl, err := template.New("target_directory").Option("missingkey=error").Parse(dir)
+	if err != nil {
+		return "", err
+	}
+
+	hostname, _ := os.Hostname()
+	var out strings.Builder
+	err = tmpl.Execute(&out, TargetPlaceholders{
+		Date:      now.Format("2006-01-02"),
+		Timestamp: now.Format("20060102-150405"),
+		Year:      now.Format("2006"),
+		Month:     now.Format("01"),
+		Day:       now.Format("02"),
+		Hostname:  hostname,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resolved := filepath.Clean(out.String())
+	if resolved == "." || resolved == string(filepath.Separator) {
+		return "", fmt.Errorf("resolves to %q", resolved)
+	}
+	return resolved, nil
+}
+
+// templateBase returns the directory above the first placeholder in a
+// templated target, which is the same for every run
+func templateBase(dir string) string {
+	return filepath.Dir(dir[:strings.Index(dir, "{{")])
+}
+
+// versionBase returns where version manifests and logs are kept: the
+// configured version_directory, the fixed part of a templated target, or the
+// target itself
+func (c *Config) versionBase(targetTemplate string) string {
+	switch {
+	case c.VersionDirectory != "":
+		return c.VersionDirectory
+	case targetTemplate != "":
+		return templateBase(targetTemplate)
+	default:
+		return c.TargetDirectory
+	}
+}
+
+// versionTarget returns the directory a version was backed up to. A templated
+// target resolves differently for each run, so the version's own record is
+// used; otherwise the configured target is, which keeps a moved target usable.
+func (s *Service) versionTarget(version *BackupVersion) string {
+	if s.targetTemplate != "" && version.ConfigUsed.TargetDirectory != "" {
+		return version.ConfigUsed.TargetDirectory
+	}
+	return s.config.TargetDirectory
+}
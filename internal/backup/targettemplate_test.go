@@ -0,0 +1,97 @@
+// targettemplate_test.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandTargetTemplate(t *testing.T) {
+	now := time.Date(2024, 1, 17, 15, 4, 5, 0, time.Local)
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		name    string
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{"date", "/backups/{{.Date}}", "/backups/2024-01-17", false},
+		{"timestamp", "/backups/run-{{.Timestamp}}", "/backups/run-20240117-150405", false},
+		{"year, month and day", "/backups/{{.Year}}/{{.Month}}/{{.Day}}", "/backups/2024/01/17", false},
+		{"hostname", "/backups/{{.Hostname}}/{{.Date}}", filepath.Join("/backups", hostname, "2024-01-17"), false},
+		{"cleaned", "/backups//{{.Date}}/", "/backups/2024-01-17", false},
+		{"unknown placeholder", "/backups/{{.Week}}", "", true},
+		{"malformed", "/backups/{{.Date", "", true},
+		{"resolves to root", "/{{if false}}x{{end}}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandTargetTemplate(filepath.FromSlash(tt.dir), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandTargetTemplate(%q) error = %v, want error %v", tt.dir, err, tt.wantErr)
+			}
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("expandTargetTemplate(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionBase(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		versions string // version_directory
+		want     string
+	}{
+		{"plain target", "/backups/latest", "", "/backups/latest"},
+		{"templated target", "/backups/{{.Year}}/{{.Date}}", "", "/backups"},
+		{"placeholder inside a name", "/backups/run-{{.Date}}", "", "/backups"},
+		{"version_directory wins", "/backups/{{.Date}}", "/var/lib/butler", "/var/lib/butler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{TargetDirectory: filepath.FromSlash(tt.target), VersionDirectory: filepath.FromSlash(tt.versions)}
+			template := ""
+			if isTargetTemplate(cfg.TargetDirectory) {
+				template = cfg.TargetDirectory
+			}
+			if got := cfg.versionBase(template); got != filepath.FromSlash(tt.want) {
+				t.Errorf("versionBase = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplatedTargetBackup(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "alpha"})
+	base := cfg.TargetDirectory
+	cfg.TargetDirectory = filepath.Join(base, "{{.Date}}")
+	s := newTestService(t, cfg)
+	result := runBackup(t, s)
+
+	resolved := filepath.Join(base, time.Now().Format("2006-01-02"))
+	if s.config.TargetDirectory != resolved {
+		t.Fatalf("target_directory = %q, want %q", s.config.TargetDirectory, resolved)
+	}
+	if got := readFile(t, filepath.Join(resolved, testFolder, "a.txt")); got != "alpha" {
+		t.Errorf("a.txt = %q, want %q", got, "alpha")
+	}
+
+	// A later run finds the version in the fixed base and its files in the
+	// directory it resolved to then
+	cfg.TargetDirectory = filepath.Join(base, "{{.Timestamp}}-later")
+	later := newTestService(t, cfg)
+	version, err := later.GetVersion(result.VersionID)
+	if err != nil {
+		t.Fatalf("GetVersion from a later run: %v", err)
+	}
+	if got := later.versionTarget(version); got != resolved {
+		t.Errorf("versionTarget = %q, want %q", got, resolved)
+	}
+	if _, err := os.Stat(filepath.Join(base, ".versions")); err != nil {
+		t.Errorf("versions not kept in the fixed base: %v", err)
+	}
+}
@@ -1,34 +1,135 @@
 package backup
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
 // createTasks generates the list of files to be backed up
 // task.go
 func (s *Service) createTasks() ([]CopyTask, int, error) {
 	var tasks []CopyTask
+	totalFiles, err := s.walkTasks(func(task CopyTask) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// A near-empty source usually means a failed mount; stop before anything
+	// is copied or, in mirror mode, deleted
+	if totalFiles < s.config.MinExpectedFiles {
+		return nil, 0, newBackupError("CreateTasks", s.config.SourceDirectory, withSentinel(
+			fmt.Errorf("found only %d source files, fewer than min_expected_files (%d); is the source mounted?",
+				totalFiles, s.config.MinExpectedFiles), ErrTooFewFiles))
+	}
+
+	if s.config.CaseConflictPolicy == CaseConflictFail || s.config.CaseConflictPolicy == CaseConflictRename {
+		if tasks, err = s.resolveCaseConflicts(tasks); err != nil {
+			return nil, 0, newBackupError("CreateTasks", s.config.SourceDirectory, err)
+		}
+	}
+
+	return tasks, totalFiles, nil
+}
+
+// fileDevice returns the device of a directory for one_file_system; tests
+// replace it to simulate a mount point
+var fileDevice = deviceID
+
+// walkTasks walks every folder to back up and passes each file's task to emit
+// as it is found. An error from emit stops the walk. It returns the number of
+// files emitted.
+func (s *Service) walkTasks(emit func(CopyTask) error) (int, error) {
 	totalFiles := 0
+	s.unreadable = nil
+	s.withheld = nil
 
-	for _, folder := range s.config.FoldersToBackup {
-		srcPath := filepath.Join(s.config.SourceDirectory, folder)
+	for _, folder := range s.sourceFolders() {
+		srcPath := filepath.Join(s.sourceDirectory(), folder)
 		dstPath := filepath.Join(s.config.TargetDirectory, folder)
+		var rootDevice uint64
+		haveRootDevice := false
 
 		err := filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
+				if path == srcPath && os.IsNotExist(err) {
+					return withSentinel(err, ErrSourceNotFound)
+				}
+				if s.config.CheckReadable && os.IsPermission(err) {
+					return s.flagUnreadable(path, err)
+				}
 				return err
 			}
 
-			// Skip if matches exclude patterns
-			for _, pattern := range s.config.ExcludePatterns {
-				if matched, _ := filepath.Match(pattern, info.Name()); matched {
-					s.logger.Debug("Skipping excluded file: %s", path)
+			// Stay on the folder root's filesystem, like rsync -x
+			if s.config.OneFileSystem && info.IsDir() {
+				if device, ok := fileDevice(info); ok {
+					if path == srcPath {
+						rootDevice, haveRootDevice = device, true
+					} else if haveRootDevice && device != rootDevice {
+						s.logger.Info("Not crossing filesystem boundary at %s", path)
+						return filepath.SkipDir
+					}
+				}
+			}
+
+			// Skip hidden files and prune hidden directories, but never the folder root itself
+			if s.config.SkipHidden && path != srcPath && isHidden(path, info) {
+				s.explain(path, decisionSkip, "hidden")
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Prune excluded subtrees entirely
+			if pattern, excluded := s.matchExcludePaths(s.sourceDirectory(), path); excluded {
+				s.explain(path, decisionSkip, "excluded by exclude_paths %q", pattern)
+				s.patternStats.recordTree("exclude_paths", pattern, path, info)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Skip if matches exclude patterns. Directories are still walked
+			// so that "!" patterns can re-include files inside them.
+			if !info.IsDir() {
+				setting, pattern, excluded := s.matchExcludePatterns(s.sourceDirectory(), path)
+				s.patternStats.record(setting, pattern, info.Size())
+				if excluded {
+					s.explain(path, decisionSkip, "excluded by %s %q", setting, pattern)
 					return nil
 				}
 			}
 
 			if !info.IsDir() {
+				if info.Size() == 0 && info.Mode().IsRegular() {
+					switch s.config.ZeroByteFiles {
+					case ZeroByteSkip:
+						s.explain(path, decisionSkip, "zero-byte file (zero_byte_files is %q)", ZeroByteSkip)
+						// Still in the source, so an earlier copy isn't mirrored away
+						if relPath, err := filepath.Rel(srcPath, path); err == nil {
+							s.withheld = append(s.withheld, filepath.Join(dstPath, relPath))
+						}
+						return nil
+					case ZeroByteWarn:
+						s.logger.Warn("Zero-byte source file: %s", path)
+					}
+				}
+
+				if s.config.CheckReadable {
+					if err := s.checkReadable(path); err != nil {
+						return s.flagUnreadable(path, err)
+					}
+				}
+
 				totalFiles++ // Increment total files count
 				// Create relative path
 				relPath, err := filepath.Rel(srcPath, path)
@@ -37,21 +138,246 @@ func (s *Service) createTasks() ([]CopyTask, int, error) {
 				}
 
 				destPath := filepath.Join(dstPath, relPath)
-				tasks = append(tasks, CopyTask{
+				if err := emit(CopyTask{
 					Source:      path,
 					Destination: destPath,
 					Size:        info.Size(),
 					ModTime:     info.ModTime(),
-				})
+				}); err != nil {
+					return err
+				}
 			}
 
 			return nil
 		})
 
 		if err != nil {
-			return nil, 0, newBackupError("CreateTasks", srcPath, err)
+			return totalFiles, newBackupError("CreateTasks", srcPath, err)
 		}
 	}
 
-	return tasks, totalFiles, nil
+	return totalFiles, nil
+}
+
+// streamBuffer is how many discovered tasks may wait ahead of the workers
+const streamBuffer = 1024
+
+// canStreamTasks reports whether tasks can be copied as the walk discovers
+// them. Resuming, the minimum file count, case-conflict resolution and the
+// fail-on-unreadable policy all need the complete task list before anything
+// is copied.
+func (s *Service) canStreamTasks() bool {
+	switch {
+	case s.config.Options.Resume:
+		return false
+	case s.config.MinExpectedFiles > 0:
+		return false
+	case s.config.CaseConflictPolicy == CaseConflictFail || s.config.CaseConflictPolicy == CaseConflictRename:
+		return false
+	case s.config.CheckReadable && s.config.UnreadablePolicy == UnreadableFail:
+		return false
+	}
+	return true
+}
+
+// streamTasks walks the source in a background goroutine, sending each task
+// on the returned channel as it is found. The channel is closed when the walk
+// ends. The returned wait function blocks until then and reports the walk
+// error along with the tasks seen, which are only kept when collect is set.
+func (s *Service) streamTasks(ctx context.Context, collect bool) (<-chan CopyTask, func() ([]CopyTask, error)) {
+	taskCh := make(chan CopyTask, streamBuffer)
+	done := make(chan struct{})
+	var collected []CopyTask
+	var walkErr error
+
+	go func() {
+		defer close(done)
+		defer close(taskCh)
+
+		totalFiles, err := s.walkTasks(func(task CopyTask) error {
+			if collect {
+				collected = append(collected, task)
+			}
+			// Directories are prepared as the walk reaches them, ahead of the copies
+			if s.dirs != nil {
+				s.prepareDestDir(filepath.Dir(task.Destination))
+			}
+			select {
+			case taskCh <- task:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		walkErr = err
+		s.metrics.SetTotalFiles(totalFiles)
+		s.metrics.SetUnreadable(s.unreadable)
+	}()
+
+	return taskCh, func() ([]CopyTask, error) {
+		<-done
+		return collected, walkErr
+	}
+}
+
+// sourceDirectory returns the directory to read from: the active snapshot if
+// one was taken, otherwise the configured source
+func (s *Service) sourceDirectory() string {
+	if s.snapshot != nil {
+		return s.snapshot.path
+	}
+	return s.config.SourceDirectory
+}
+
+// manifestKey returns the key a source file is recorded under in a version:
+// its slash-separated path relative to the source directory. Keys stay stable
+// when the source moves or is read through a snapshot.
+func (s *Service) manifestKey(path string) string {
+	relPath, err := filepath.Rel(s.sourceDirectory(), path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// checkReadable opens and immediately closes a file to confirm it can be read
+func (s *Service) checkReadable(path string) error {
+	s.files.acquire(1)
+	defer s.files.release(1)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// flagUnreadable records a source path that can't be read and applies the
+// unreadable policy: "fail" aborts the walk, "skip" leaves the path out
+func (s *Service) flagUnreadable(path string, err error) error {
+	if s.config.UnreadablePolicy == UnreadableFail {
+		return withSentinel(fmt.Errorf("unreadable source file: %w", err), ErrUnreadableSource)
+	}
+	s.logger.Warn("Skipping unreadable source %s: %v", path, err)
+	s.unreadable = append(s.unreadable, path)
+	return nil
+}
+
+// isExcluded reports whether a file or directory, taken relative to root, is
+// excluded by exclude_patterns or a .foldersitterignore file. Patterns are
+// applied in order and the last match wins, so a pattern prefixed with "!"
+// re-includes paths excluded by an earlier one. A pattern without a slash
+// matches the base name; one with a slash matches the relative path or any of
+// its parent directories. With pattern_syntax "regex", exclude_patterns are
+// regular expressions matched against the relative path instead. Ignore
+// files always use globs and are applied after exclude_patterns, from root
+// down to the file's directory, so deeper files take precedence.
+func (s *Service) isExcluded(root, fullPath string) bool {
+	_, _, excluded := s.matchExcludePatterns(root, fullPath)
+	return excluded
+}
+
+// matchExcludePatterns returns where the deciding pattern comes from
+// ("exclude_patterns" or the ignore file's path relative to root), the
+// pattern itself ("" if none matches) and the decision
+func (s *Service) matchExcludePatterns(root, fullPath string) (string, string, bool) {
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil {
+		rel = filepath.Base(fullPath)
+	}
+	rel = filepath.ToSlash(rel)
+
+	setting, decider, excluded := "", "", false
+	apply := func(source string, patterns []string, rel string, match func(pattern, rel string) bool) {
+		if s.config.ExcludeCaseInsensitive {
+			rel = strings.ToLower(rel)
+		}
+		for _, configured := range patterns {
+			negated := strings.HasPrefix(configured, "!")
+			pattern := strings.TrimPrefix(configured, "!")
+			if s.config.ExcludeCaseInsensitive && !(source == "exclude_patterns" && s.regexSyntax()) {
+				pattern = strings.ToLower(pattern)
+			}
+			if match(pattern, rel) {
+				setting, decider, excluded = source, configured, !negated
+			}
+		}
+	}
+	apply("exclude_patterns", s.config.ExcludePatterns, rel, s.patternMatches)
+
+	// Ignore files from root down to the file's own directory. They are
+	// always read from the source, so mirror mode honors them on the target.
+	if s.ignores != nil && rel != "." && !strings.HasPrefix(rel, "../") {
+		parts := strings.Split(rel, "/")
+		for i := range parts {
+			dir := path.Join(parts[:i]...)
+			if patterns := s.ignores.patterns(filepath.Join(s.sourceDirectory(), filepath.FromSlash(dir))); len(patterns) > 0 {
+				apply(path.Join(dir, ignoreFileName), patterns, path.Join(parts[i:]...), excludePatternMatches)
+			}
+		}
+	}
+	return setting, decider, excluded
+}
+
+// hasIncludeOverrides reports whether any exclude pattern is a "!" negation,
+// in which case excluded directories must still be walked
+func (s *Service) hasIncludeOverrides() bool {
+	for _, pattern := range s.config.ExcludePatterns {
+		if strings.HasPrefix(pattern, "!") {
+			return true
+		}
+	}
+	return false
+}
+
+// excludePatternMatches matches one exclude pattern against a slash-separated
+// relative path
+func excludePatternMatches(pattern, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(rel))
+		return matched
+	}
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if matched, _ := path.Match(pattern, p); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcludedPath reports whether path, taken relative to root, matches one of
+// the exclude_paths entries. Entries are anchored at root and may use glob
+// patterns in each segment.
+func (s *Service) isExcludedPath(root, fullPath string) bool {
+	_, excluded := s.matchExcludePaths(root, fullPath)
+	return excluded
+}
+
+// matchExcludePaths returns the first exclude_paths entry matching fullPath
+// and whether there is one
+func (s *Service) matchExcludePaths(root, fullPath string) (string, bool) {
+	if len(s.config.ExcludePaths) == 0 {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if s.config.ExcludeCaseInsensitive {
+		rel = strings.ToLower(rel)
+	}
+
+	for _, configured := range s.config.ExcludePaths {
+		pattern := strings.Trim(filepath.ToSlash(configured), "/")
+		if s.config.ExcludeCaseInsensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		if matched, _ := path.Match(pattern, rel); matched {
+			return configured, true
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,352 @@
+// task_test.go
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestExcludeCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name        string
+		patterns    []string
+		paths       []string // ExcludePaths
+		insensitive bool
+		file        string
+		want        bool
+	}{
+		{name: "exact case", patterns: []string{"*.jpg"}, file: "photo.jpg", want: true},
+		{name: "upper pattern, lower name", patterns: []string{"*.JPG"}, file: "photo.jpg", want: false},
+		{name: "upper pattern, lower name, insensitive", patterns: []string{"*.JPG"}, insensitive: true, file: "photo.jpg", want: true},
+		{name: "lower pattern, mixed name, insensitive", patterns: []string{"*.jpg"}, insensitive: true, file: "Photo.JpG", want: true},
+		{name: "mixed directory, insensitive", patterns: []string{"cache/*"}, insensitive: true, file: "Cache/Photo.jpg", want: true},
+		{name: "mixed directory", patterns: []string{"cache/*"}, file: "Cache/Photo.jpg", want: false},
+		{name: "no match, insensitive", patterns: []string{"*.JPG"}, insensitive: true, file: "photo.png", want: false},
+		{name: "exclude_paths, insensitive", paths: []string{"Data/TMP"}, insensitive: true, file: "data/tmp", want: true},
+		{name: "exclude_paths", paths: []string{"Data/TMP"}, file: "data/tmp", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.ExcludePatterns = tt.patterns
+			cfg.ExcludePaths = tt.paths
+			cfg.ExcludeCaseInsensitive = tt.insensitive
+			s := newTestService(t, cfg)
+
+			root := cfg.SourceDirectory
+			full := filepath.Join(root, filepath.FromSlash(tt.file))
+			got := s.isExcluded(root, full) || s.isExcludedPath(root, full)
+			if got != tt.want {
+				t.Errorf("excluded = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeCaseInsensitiveBackup(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"photo.jpg": "a", "PHOTO2.Jpg": "b", "notes.txt": "c"})
+	cfg.ExcludePatterns = []string{"*.JPG"}
+	cfg.ExcludeCaseInsensitive = true
+	runBackup(t, newTestService(t, cfg))
+
+	for name, want := range map[string]bool{"photo.jpg": false, "PHOTO2.Jpg": false, "notes.txt": true} {
+		_, err := os.Stat(targetPath(cfg, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s backed up = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// walkKeys returns the sorted manifest keys of the files the walk queues
+func walkKeys(t *testing.T, s *Service) []string {
+	t.Helper()
+	var keys []string
+	if _, err := s.walkTasks(func(task CopyTask) error {
+		keys = append(keys, s.manifestKey(task.Source))
+		return nil
+	}); err != nil {
+		t.Fatalf("walkTasks: %v", err)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestExcludeNegation(t *testing.T) {
+	files := map[string]string{
+		"a.txt":             "a",
+		"b.log":             "b",
+		"cache/keep.db":     "k",
+		"cache/tmp.bin":     "t",
+		"cache/sub/keep.db": "s",
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{"no negation", []string{"data/cache"}, []string{"data/a.txt", "data/b.log"}},
+		{"re-include one file", []string{"data/cache", "!data/cache/keep.db"},
+			[]string{"data/a.txt", "data/b.log", "data/cache/keep.db"}},
+		{"re-include by name", []string{"data/cache", "!keep.db"},
+			[]string{"data/a.txt", "data/b.log", "data/cache/keep.db", "data/cache/sub/keep.db"}},
+		// The last matching pattern wins, so an exclude after the negation
+		// takes the file out again
+		{"exclude after negation", []string{"data/cache", "!keep.db", "data/cache/sub"},
+			[]string{"data/a.txt", "data/b.log", "data/cache/keep.db"}},
+		{"negation before exclude has no effect", []string{"!keep.db", "data/cache"},
+			[]string{"data/a.txt", "data/b.log"}},
+		{"negation of an unexcluded file", []string{"*.log", "!a.txt"},
+			[]string{"data/a.txt", "data/cache/keep.db", "data/cache/sub/keep.db", "data/cache/tmp.bin"}},
+		{"exclude all but one type", []string{"*", "!*.log"}, []string{"data/b.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.ExcludePatterns = tt.patterns
+			if got := walkKeys(t, newTestService(t, cfg)); !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipHidden(t *testing.T) {
+	files := map[string]string{
+		"a.txt":                   "a",
+		".profile":                "p",
+		"docs/b.txt":              "b",
+		"docs/.draft.txt":         "d",
+		".config/app.conf":        "c",
+		".config/nested/deep.txt": "n",
+		"docs/.cache/x/y.txt":     "y",
+		"docs/visible/.git/HEAD":  "h",
+		"docs/visible/c.txt":      "c",
+	}
+	tests := []struct {
+		name       string
+		skipHidden bool
+		want       []string
+	}{
+		{"off", false, []string{
+			"data/.config/app.conf", "data/.config/nested/deep.txt", "data/.profile", "data/a.txt",
+			"data/docs/.cache/x/y.txt", "data/docs/.draft.txt", "data/docs/b.txt",
+			"data/docs/visible/.git/HEAD", "data/docs/visible/c.txt",
+		}},
+		{"on", true, []string{"data/a.txt", "data/docs/b.txt", "data/docs/visible/c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.SkipHidden = tt.skipHidden
+			if got := walkKeys(t, newTestService(t, cfg)); !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipHiddenPrunesSubtree(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "a", ".hidden/inner/b.txt": "b"})
+	// A dangling link is reported as unreadable if the walk ever reaches it
+	if err := os.Symlink("missing", sourcePath(cfg, ".hidden/inner/link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	cfg.CheckReadable = true
+	cfg.SkipHidden = true
+	s := newTestService(t, cfg)
+
+	if got := walkKeys(t, s); !slices.Equal(got, []string{"data/a.txt"}) {
+		t.Errorf("walked %v, want [data/a.txt]", got)
+	}
+	if len(s.unreadable) != 0 {
+		t.Errorf("the walk entered the hidden directory: %v", s.unreadable)
+	}
+}
+
+func TestZeroByteFiles(t *testing.T) {
+	tests := []struct {
+		mode       string
+		wantCopied bool
+	}{
+		{ZeroByteInclude, true},
+		{ZeroByteWarn, true},
+		{ZeroByteSkip, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha", "empty.txt": ""})
+			cfg.ZeroByteFiles = tt.mode
+			result := runBackup(t, newTestService(t, cfg))
+
+			info, err := os.Stat(targetPath(cfg, "empty.txt"))
+			if copied := err == nil; copied != tt.wantCopied {
+				t.Fatalf("empty.txt copied = %v, want %v", copied, tt.wantCopied)
+			}
+			if tt.wantCopied && info.Size() != 0 {
+				t.Errorf("empty.txt copy has %d bytes", info.Size())
+			}
+			if got := readFile(t, targetPath(cfg, "a.txt")); got != "alpha" {
+				t.Errorf("a.txt = %q, want %q", got, "alpha")
+			}
+			wantFiles := 1
+			if tt.wantCopied {
+				wantFiles = 2
+			}
+			if result.Stats.FilesBackedUp != wantFiles || result.Stats.FilesFailed != 0 {
+				t.Errorf("stats = %+v, want %d copied, none failed", result.Stats, wantFiles)
+			}
+		})
+	}
+}
+
+func TestZeroByteDeepDuplicateCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string // Existing backup copy of the empty source file
+		want   bool   // Copied again
+	}{
+		{"empty copy is identical", "", false},
+		{"non-empty copy differs", "stale", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"empty.txt": ""})
+			cfg.DeepDuplicateCheck = true
+			writeFiles(t, targetPath(cfg, ""), map[string]string{"empty.txt": tt.target})
+			info, err := os.Stat(sourcePath(cfg, "empty.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(targetPath(cfg, "empty.txt"), info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+
+			result := runBackup(t, newTestService(t, cfg))
+			if copied := result.Stats.FilesBackedUp == 1; copied != tt.want {
+				t.Errorf("copied = %v, want %v (stats %+v)", copied, tt.want, result.Stats)
+			}
+			if got := readFile(t, targetPath(cfg, "empty.txt")); got != "" {
+				t.Errorf("empty.txt copy = %q, want it empty", got)
+			}
+		})
+	}
+}
+
+func TestExcludePaths(t *testing.T) {
+	files := map[string]string{
+		"2019/keep.jpg":         "k",
+		"2019/raw/a.raw":        "a",
+		"2019/raw/deep/b.raw":   "b",
+		"2019/rawish/c.raw":     "c",
+		"2020/raw/d.raw":        "d",
+		"2020/edited/e.jpg":     "e",
+		"notes/2019/raw/f.note": "f",
+	}
+	tests := []struct {
+		name     string
+		excluded []string
+		want     []string
+	}{
+		{"one subtree", []string{"data/2019/raw"}, []string{
+			"data/2019/keep.jpg", "data/2019/rawish/c.raw", "data/2020/edited/e.jpg", "data/2020/raw/d.raw", "data/notes/2019/raw/f.note",
+		}},
+		// Anchored at the source directory, so notes/2019/raw is kept
+		{"glob segment", []string{"data/*/raw"}, []string{
+			"data/2019/keep.jpg", "data/2019/rawish/c.raw", "data/2020/edited/e.jpg", "data/notes/2019/raw/f.note",
+		}},
+		{"trailing slash", []string{"data/2020/"}, []string{
+			"data/2019/keep.jpg", "data/2019/raw/a.raw", "data/2019/raw/deep/b.raw", "data/2019/rawish/c.raw", "data/notes/2019/raw/f.note",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.ExcludePaths = tt.excluded
+			if err := Validate(cfg); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if got := walkKeys(t, newTestService(t, cfg)); !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludePathsPrunesSubtree(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{"a.txt": "a", "raw/inner/b.raw": "b"})
+	// A dangling link is reported as unreadable if the walk ever reaches it
+	if err := os.Symlink("missing", sourcePath(cfg, "raw/inner/link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	cfg.CheckReadable = true
+	cfg.ExcludePaths = []string{"data/raw"}
+	s := newTestService(t, cfg)
+
+	if got := walkKeys(t, s); !slices.Equal(got, []string{"data/a.txt"}) {
+		t.Errorf("walked %v, want [data/a.txt]", got)
+	}
+	if len(s.unreadable) != 0 {
+		t.Errorf("the walk entered the excluded directory: %v", s.unreadable)
+	}
+}
+
+func TestExcludePathsValidation(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"data/raw", false},
+		{"data/*/raw", false},
+		{"", true},
+		{"/data/raw", true},
+		{"../data", true},
+		{"data/[raw", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.ExcludePaths = []string{tt.path}
+			if err := Validate(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOneFileSystem(t *testing.T) {
+	files := map[string]string{
+		"a.txt":             "a",
+		"local/b.txt":       "b",
+		"mnt/nas/c.txt":     "c",
+		"mnt/nas/sub/d.txt": "d",
+	}
+	// Directories named "nas" are mount points of another device
+	defer func(original func(os.FileInfo) (uint64, bool)) { fileDevice = original }(fileDevice)
+	fileDevice = func(info os.FileInfo) (uint64, bool) {
+		if info.Name() == "nas" {
+			return 2, true
+		}
+		return 1, true
+	}
+
+	tests := []struct {
+		name          string
+		oneFileSystem bool
+		want          []string
+	}{
+		{"crosses mounts", false, []string{"data/a.txt", "data/local/b.txt", "data/mnt/nas/c.txt", "data/mnt/nas/sub/d.txt"}},
+		{"one file system", true, []string{"data/a.txt", "data/local/b.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			cfg.OneFileSystem = tt.oneFileSystem
+			if got := walkKeys(t, newTestService(t, cfg)); !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// timeout.go
+package backup
+
+import (
+	"io"
+	"time"
+)
+
+// SetFileTimeout gives each attempt at a task a deadline of timeout, after
+// which a stalled copy gives up with ErrFileTimeout and is retried like any
+// other failure. Zero means no deadline.
+func (p *WorkerPool) SetFileTimeout(timeout time.Duration) {
+	p.fileTimeout = timeout
+}
+
+// ioResult carries the outcome of a read or write made on another goroutine
+type ioResult struct {
+	n   int
+	err error
+}
+
+// deadlineIO bounds the reads and writes of one copy attempt by the task's
+// deadline. Reads from and writes to regular files can't be interrupted, so
+// they run on one goroutine that lives for the attempt; a call still running
+// at the deadline is abandoned and every later call fails at once. The
+// goroutine works on its own buffer, so a caller's slice is never touched
+// after its call returns. It exits at close, or once an abandoned call
+// returns. Calls must come from one goroutine at a time, as in io.Copy.
+type deadlineIO struct {
+	deadline time.Time
+	calls    chan func() ioResult
+	results  chan ioResult
+	buf      []byte
+	stalled  bool
+}
+
+// newDeadlineIO returns a deadlineIO for the task's deadline, or nil when it
+// has none; a nil deadlineIO passes readers and writers through unchanged
+func newDeadlineIO(task CopyTask) *deadlineIO {
+	if task.deadline.IsZero() {
+		return nil
+	}
+	d := &deadlineIO{
+		deadline: task.deadline,
+		calls:    make(chan func() ioResult),
+		results:  make(chan ioResult, 1), // An abandoned call can still deliver
+	}
+	go d.serve()
+	return d
+}
+
+func (d *deadlineIO) serve() {
+	for call := range d.calls {
+		d.results <- call()
+	}
+}
+
+// close ends the goroutine once any abandoned call returns
+func (d *deadlineIO) close() {
+	if d != nil {
+		close(d.calls)
+	}
+}
+
+// do runs call on the goroutine and waits for it until the deadline
+func (d *deadlineIO) do(call func() ioResult) (int, error) {
+	remaining := time.Until(d.deadline)
+	if d.stalled || remaining <= 0 {
+		return 0, ErrFileTimeout
+	}
+
+	d.calls <- call
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case result := <-d.results:
+		return result.n, result.err
+	case <-timer.C:
+		d.stalled = true
+		return 0, ErrFileTimeout
+	}
+}
+
+// buffer returns the goroutine's buffer sized to n
+func (d *deadlineIO) buffer(n int) []byte {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	return d.buf[:n]
+}
+
+// reader bounds reads from r by the deadline
+func (d *deadlineIO) reader(r io.Reader) io.Reader {
+	if d == nil {
+		return r
+	}
+	return deadlineReader{d: d, r: r}
+}
+
+// writer bounds writes to w by the deadline
+func (d *deadlineIO) writer(w io.Writer) io.Writer {
+	if d == nil {
+		return w
+	}
+	return deadlineWriter{d: d, w: w}
+}
+
+type deadlineReader struct {
+	d *deadlineIO
+	r io.Reader
+}
+
+func (r deadlineReader) Read(p []byte) (int, error) {
+	buf := r.d.buffer(len(p))
+	n, err := r.d.do(func() ioResult {
+		n, err := r.r.Read(buf)
+		return ioResult{n, err}
+	})
+	copy(p, buf[:n])
+	return n, err
+}
+
+type deadlineWriter struct {
+	d *deadlineIO
+	w io.Writer
+}
+
+func (w deadlineWriter) Write(p []byte) (int, error) {
+	buf := w.d.buffer(len(p))
+	copy(buf, p)
+	return w.d.do(func() ioResult {
+		n, err := w.w.Write(buf)
+		return ioResult{n, err}
+	})
+}
@@ -0,0 +1,92 @@
+// timeout_test.go
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingIO blocks every read and write until release is closed
+type stallingIO struct {
+	release chan struct{}
+}
+
+func (s stallingIO) Read(p []byte) (int, error) {
+	<-s.release
+	return 0, io.EOF
+}
+
+func (s stallingIO) Write(p []byte) (int, error) {
+	<-s.release
+	return len(p), nil
+}
+
+func TestDeadlineIO(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	data := strings.Repeat("backup butler ", 1000)
+
+	tests := []struct {
+		name    string
+		stallR  bool
+		stallW  bool
+		wantErr error
+	}{
+		{"completes in time", false, false, nil},
+		{"stalled read", true, false, ErrFileTimeout},
+		{"stalled write", false, true, ErrFileTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stall := stallingIO{release: make(chan struct{})}
+			defer close(stall.release)
+
+			var src io.Reader = strings.NewReader(data)
+			if tt.stallR {
+				src = stall
+			}
+			var dst bytes.Buffer
+			var w io.Writer = &dst
+			if tt.stallW {
+				w = stall
+			}
+
+			deadline := newDeadlineIO(CopyTask{deadline: time.Now().Add(timeout)})
+			defer deadline.close()
+
+			start := time.Now()
+			_, err := io.CopyBuffer(deadline.writer(w), deadline.reader(src), make([]byte, 64))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("copy error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 10*timeout {
+				t.Errorf("copy took %v, want it cut off near %v", elapsed, timeout)
+			}
+			if tt.wantErr == nil && dst.String() != data {
+				t.Errorf("copied %d bytes, want %d", dst.Len(), len(data))
+			}
+
+			// Once stalled, later calls fail without waiting
+			if tt.wantErr != nil {
+				if _, err := deadline.reader(strings.NewReader("x")).Read(make([]byte, 1)); !errors.Is(err, ErrFileTimeout) {
+					t.Errorf("read after timeout = %v, want %v", err, ErrFileTimeout)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadlineIOWithoutDeadline(t *testing.T) {
+	deadline := newDeadlineIO(CopyTask{})
+	if deadline != nil {
+		t.Fatal("newDeadlineIO without a deadline returned a deadlineIO")
+	}
+	r := strings.NewReader("x")
+	if deadline.reader(r) != io.Reader(r) {
+		t.Error("nil deadlineIO wrapped the reader")
+	}
+	deadline.close()
+}
@@ -0,0 +1,71 @@
+// trash.go
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trashDir is the holding area under the target for files removed by mirror mode
+const trashDir = ".trash"
+
+// moveToTrash moves a target file into .trash/<version-id>/, preserving its
+// path relative to the target directory
+func (s *Service) moveToTrash(path string) (string, error) {
+	relPath, err := filepath.Rel(s.config.TargetDirectory, path)
+	if err != nil {
+		return "", err
+	}
+
+	versionID := s.versioner.CurrentVersionID()
+	if versionID == "" {
+		versionID = time.Now().Format("20060102-150405")
+	}
+
+	trashPath := filepath.Join(s.config.TargetDirectory, trashDir, versionID, relPath)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := os.Rename(path, trashPath); err != nil {
+		return "", fmt.Errorf("failed to move to trash: %w", err)
+	}
+	return trashPath, nil
+}
+
+// EmptyTrash removes trashed runs older than the given age. An age of zero
+// empties the trash completely. It returns the number of runs removed.
+func (s *Service) EmptyTrash(olderThan time.Duration) (int, error) {
+	root := filepath.Join(s.config.TargetDirectory, trashDir)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, newBackupError("EmptyTrash", root, err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if olderThan > 0 {
+			trashedAt, err := time.ParseInLocation("20060102-150405", entry.Name(), time.Local)
+			if err != nil || trashedAt.After(cutoff) {
+				continue
+			}
+		}
+
+		path := filepath.Join(root, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return removed, newBackupError("EmptyTrash", path, err)
+		}
+		s.logger.Info("Emptied trash %s", path)
+		removed++
+	}
+
+	return removed, nil
+}
@@ -2,16 +2,42 @@
 package backup
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 )
 
 // Service represents the backup service with all required dependencies
 type Service struct {
-	config    *Config
-	logger    *Logger
-	metrics   *BackupMetrics
-	pool      *WorkerPool
-	versioner *VersionManager
+	config       *Config
+	logger       *Logger
+	metrics      *BackupMetrics
+	pool         *WorkerPool
+	checkPool    *WorkerPool // Skip-check stage of the current run, feeding pool
+	versioner    *VersionManager
+	unreadable   []string                // Source paths skipped by the readability scan
+	withheld     []string                // Destinations of source files the walk found but didn't queue, kept by mirror mode
+	snapshot     *sourceSnapshot         // Read-only source snapshot for the current run, if any
+	files        *fileLimiter            // Caps simultaneously open file handles
+	sampler      *verifySampler          // Chooses files for spot-check checksums
+	ignores      *ignoreFiles            // Parsed .foldersitterignore files
+	regexps      *regexpCache            // Compiled patterns, with pattern_syntax "regex"
+	patternStats *patternCounter         // Per-pattern match counts, only while --pattern-stats walks
+	archive      *archiveWriter          // Archive being written by the current run, for tar targets
+	cancelRun    func()                  // Stops the current backup, set when on_read_error is "fail" or with fail_fast
+	moves        *moveIndex              // Files already on the target, while a detect_moves run copies
+	diag         *diagnostics            // Phase timings for the current run, with --diagnostics
+	linkDest     string                  // Previous snapshot unchanged files are linked to, in snapshot_mode
+	runCtx       context.Context         // Context of the backup in progress, see runContext
+	appendIndex  map[string]FileMetadata // Newest checksummed entry per file, with incremental_verify
+	immutable    map[string]int64        // Backed-up sizes of files under immutable_paths
+	sinceLast    *sinceLastIndex         // Previous completed version, with --since-last
+	dirs         *destDirs               // Destination directories prepared by the current run
+	filesLogged  atomic.Int64            // Files written so far, for log_sample_every
+	autosave     chan struct{}           // Asks the autosave goroutine for a flush, with autosave_every_files
+	recorded     atomic.Int64            // Files added to the version in progress, for autosave_every_files
+	// TargetDirectory as configured when it contains placeholders, before expansion
+	targetTemplate string
 }
 
 // CopyTask represents a single file copy operation
@@ -20,24 +46,48 @@ type CopyTask struct {
 	Destination string
 	Size        int64
 	ModTime     time.Time
+
+	deadline time.Time // When the current attempt times out, with per_file_timeout
 }
 
 // FileMetadata holds file comparison information
 type FileMetadata struct {
-	Path     string
-	Size     int64
-	ModTime  time.Time
-	Checksum string
+	Path      string
+	Size      int64
+	ModTime   time.Time
+	Checksum  string
+	Duration  time.Duration `json:",omitempty"` // Time taken to copy (zero when skipped)
+	TargetKey string        `json:",omitempty"` // Target-relative path when renamed (e.g. case conflict)
+	// Offset of the file's tar header in the uncompressed archive stream,
+	// for versions written with target_format tar
+	ArchiveOffset int64 `json:",omitempty"`
+	// Target-relative path of the identical file this one was hard-linked
+	// from instead of copied, when detect_moves found it moved
+	MovedFrom string `json:",omitempty"`
+	// With incremental_verify, a file that only grew since its previous
+	// checksum records the size it grew from and hashes of the appended
+	// bytes and of the bytes just before them
+	AppendFrom     int64  `json:",omitempty"`
+	AppendChecksum string `json:",omitempty"`
+	AppendBoundary string `json:",omitempty"`
 }
 
 // BackupStats holds statistical information about the backup
 type BackupStats struct {
-	TotalFiles       int   // Total number of files processed
-	FilesBackedUp    int   // Number of files actually copied
-	FilesSkipped     int   // Number of unchanged files
-	FilesFailed      int   // Number of files that failed to backup
-	TotalBytes       int64 // Total bytes processed
-	BytesTransferred int64 // Actual bytes copied
+	TotalFiles        int   // Total number of files processed
+	FilesBackedUp     int   // Number of files actually copied
+	FilesSkipped      int   // Number of unchanged files
+	FilesExisting     int   // Of FilesSkipped, files left alone because they existed (--ignore-existing)
+	FilesFailed       int   // Number of files that failed to backup
+	TotalBytes        int64 // Total bytes processed
+	BytesTransferred  int64 // Actual bytes copied
+	FilesDeleted      int   // Number of target files removed in mirror mode
+	BytesDeleted      int64 // Bytes reclaimed by mirror deletions
+	FilesWrittenAsNew int   // Changed files written to a .new sidecar in safe mode
+	FilesChanged      int   // Source files modified while being backed up; their copy may be inconsistent
+	FilesMoved        int   // Files hard-linked from an identical copy on the target (detect_moves)
+	DirsCreated       int   // Destination directories created
+	DirConflicts      int   // Destination directories blocked by a file of the same name
 }
 
 // WorkerPool manages a pool of workers for concurrent file operations
@@ -46,4 +96,16 @@ type WorkerPool struct {
 	copyFn        func(CopyTask) error
 	retryAttempts int
 	retryDelay    time.Duration
+	retries       atomic.Int64 // Retry attempts made across all tasks
+
+	// Experimental throughput-driven worker count, see EnableAdaptive
+	adaptive       bool
+	onAdjust       func(workers int, mbps float64)
+	limiter        *concurrencyLimiter
+	completedBytes atomic.Int64 // Sizes of tasks finished successfully
+	completedTasks atomic.Int64
+
+	fileTimeout time.Duration // Deadline for each attempt, see SetFileTimeout
+	rampUp      time.Duration // Delay between worker launches, see SetRampUp
+	failFast    func()        // Called at the first failed task, see SetFailFast
 }
@@ -2,10 +2,15 @@
 package backup
 
 import (
+	"compress/gzip"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
+	"text/template"
 	"time"
 )
 
@@ -38,30 +43,68 @@ func (s *Service) validatePaths() error {
 }
 */
 
-// shouldSkipFile determines if a file should be skipped based on metadata and checksum
+// shouldSkipFile determines if a file should be skipped based on metadata and
+// checksum. Every decision states its deciding factor through explain.
 // validations.go
 func (s *Service) shouldSkipFile(task CopyTask) (bool, error) {
+	statStart := s.diag.now()
 	sourceInfo, err := os.Stat(task.Source)
 	if err != nil {
 		return false, fmt.Errorf("failed to stat source file: %w", err)
 	}
 
 	destInfo, err := os.Stat(task.Destination)
+	s.diag.addStat(statStart)
 	if os.IsNotExist(err) {
-		s.logger.Debug("Destination file does not exist: %s", task.Destination)
+		s.explain(task.Source, decisionCopy, "new, %s does not exist", task.Destination)
 		return false, nil
 	} else if err != nil {
 		return false, fmt.Errorf("failed to stat destination file: %w", err)
 	}
 
+	// Existing files are never touched, whatever their contents
+	if s.config.Options.IgnoreExisting {
+		s.explain(task.Source, decisionSkip, "ignore-existing, %s exists", task.Destination)
+		return true, nil
+	}
+
+	// In update mode a newer backup copy was edited deliberately; keep it
+	// without comparing contents, so the deep check never overrides it
+	if s.config.UpdateMode && destInfo.ModTime().After(sourceInfo.ModTime()) {
+		s.logger.Info("Kept newer backup copy %s (update mode)", task.Destination)
+		s.explain(task.Source, decisionSkip, "update-skip, backup copy modified %s is newer than source %s",
+			destInfo.ModTime().Format(time.RFC3339), sourceInfo.ModTime().Format(time.RFC3339))
+		return true, nil
+	}
+
 	// Quick size comparison first
 	if sourceInfo.Size() != destInfo.Size() {
-		s.logger.Debug("Size mismatch - Source: %d bytes, Destination: %d bytes",
+		s.explain(task.Source, decisionCopy, "size-differs, source %d bytes, backup copy %d bytes",
 			sourceInfo.Size(), destInfo.Size())
 		return false, nil
 	}
 
-	if s.config.DeepDuplicateCheck {
+	// Spot-check a sample of otherwise-matching files with a full checksum
+	sampled := !s.config.DeepDuplicateCheck && s.sampler.sample(s.manifestKey(task.Source))
+
+	// Large files: compare the first and last bytes instead of hashing,
+	// unless the deep check asks for full checksums. A size change never
+	// gets here.
+	if !s.config.DeepDuplicateCheck && !sampled && s.quickCompareApplies(sourceInfo.Size()) {
+		match, err := s.edgesMatch(task.Source, task.Destination, sourceInfo.Size())
+		if err != nil {
+			return false, fmt.Errorf("failed to compare file edges: %w", err)
+		}
+		if !match {
+			s.explain(task.Source, decisionCopy, "edges-differ, first or last %d bytes differ", s.config.QuickCompareBytes)
+			return false, nil
+		}
+		s.explain(task.Source, decisionSkip, "identical size (%d bytes) and first and last %d bytes",
+			sourceInfo.Size(), s.config.QuickCompareBytes)
+		return true, nil
+	}
+
+	if s.config.DeepDuplicateCheck || sampled {
 		// Calculate checksums for both files
 		sourceChecksum, err := s.calculateChecksum(task.Source)
 		if err != nil {
@@ -74,80 +117,130 @@ func (s *Service) shouldSkipFile(task CopyTask) (bool, error) {
 		}
 
 		if sourceChecksum != destChecksum {
-			s.logger.Debug("Checksum mismatch - Source: %s, Destination: %s",
+			if sampled {
+				s.logger.Warn("Sampled checksum mismatch for %s despite matching size; recopying",
+					task.Destination)
+			}
+			s.explain(task.Source, decisionCopy, "checksum-differs, source %s, backup copy %s",
 				sourceChecksum, destChecksum)
 			return false, nil
 		}
+		s.explain(task.Source, decisionSkip, "identical size (%d bytes) and checksum %s",
+			sourceInfo.Size(), sourceChecksum)
+		return true, nil
 	}
 
-	// Files are identical - update metrics
-	// s.metrics.IncrementSkipped(sourceInfo.Size())
-
-	s.logger.Debug("Skipped identical file: %s (Size: %.2f MB)",
-		task.Source, float64(sourceInfo.Size())/1024/1024)
+	s.explain(task.Source, decisionSkip, "identical size (%d bytes); contents not compared without deep_duplicate_check",
+		sourceInfo.Size())
 	return true, nil
 }
 
 // validateWorkerConfig performs detailed validation of worker pool settings
-func validateWorkerConfig(cfg *Config) error {
+func validateWorkerConfig(cfg *Config) ValidationErrors {
+	var problems ValidationErrors
+
 	// Validate concurrency
 	if cfg.Concurrency < minConcurrency || cfg.Concurrency > maxConcurrency {
-		return newBackupError(
+		problems = append(problems, newBackupError(
 			"ValidateWorker",
 			"",
 			fmt.Errorf("concurrency must be between %d and %d, got %d",
 				minConcurrency, maxConcurrency, cfg.Concurrency),
-		)
+		))
+	}
+
+	// Validate checksum concurrency; zero falls back to concurrency
+	if cfg.ChecksumConcurrency != 0 &&
+		(cfg.ChecksumConcurrency < minConcurrency || cfg.ChecksumConcurrency > maxConcurrency) {
+		problems = append(problems, newBackupError(
+			"ValidateWorker",
+			"",
+			fmt.Errorf("checksum concurrency must be 0 or between %d and %d, got %d",
+				minConcurrency, maxConcurrency, cfg.ChecksumConcurrency),
+		))
 	}
 
 	// Validate retry attempts
 	if cfg.RetryAttempts < minRetryAttempts || cfg.RetryAttempts > maxRetryAttempts {
-		return newBackupError(
+		problems = append(problems, newBackupError(
 			"ValidateWorker",
 			"",
 			fmt.Errorf("retry attempts must be between %d and %d, got %d",
 				minRetryAttempts, maxRetryAttempts, cfg.RetryAttempts),
-		)
+		))
 	}
 
 	// Validate retry delay
 	if cfg.RetryDelay < minRetryDelay || cfg.RetryDelay > maxRetryDelay {
-		return newBackupError(
+		problems = append(problems, newBackupError(
 			"ValidateWorker",
 			"",
 			fmt.Errorf("retry delay must be between %v and %v, got %v",
 				minRetryDelay, maxRetryDelay, cfg.RetryDelay),
-		)
+		))
+	}
+
+	if cfg.PerFileTimeout < 0 {
+		problems = append(problems, newBackupError(
+			"ValidateWorker",
+			"",
+			fmt.Errorf("per file timeout must not be negative, got %v", cfg.PerFileTimeout),
+		))
+	}
+
+	if cfg.WorkerRampUp < 0 {
+		problems = append(problems, newBackupError(
+			"ValidateWorker",
+			"",
+			fmt.Errorf("worker ramp up must not be negative, got %v", cfg.WorkerRampUp),
+		))
+	}
+
+	if cfg.SlowFileThreshold < 0 {
+		problems = append(problems, newBackupError(
+			"ValidateWorker",
+			"",
+			fmt.Errorf("slow file threshold must not be negative, got %v", cfg.SlowFileThreshold),
+		))
+	}
+
+	// Validate open file limit
+	if cfg.MaxOpenFiles != 0 && cfg.MaxOpenFiles < minOpenFiles {
+		problems = append(problems, newBackupError(
+			"ValidateWorker",
+			"",
+			fmt.Errorf("max open files must be 0 (unlimited) or at least %d, got %d",
+				minOpenFiles, cfg.MaxOpenFiles),
+		))
 	}
 
 	// Validate buffer size
 	if cfg.BufferSize < minBufferSize || cfg.BufferSize > maxBufferSize {
-		return newBackupError(
+		problems = append(problems, newBackupError(
 			"ValidateWorker",
 			"",
 			fmt.Errorf("buffer size must be between %d and %d bytes, got %d",
 				minBufferSize, maxBufferSize, cfg.BufferSize),
-		)
+		))
 	}
 
-	return nil
+	return problems
 }
 
-// validateSystemResources checks if the system can handle the requested configuration
-func validateSystemResources(cfg *Config) error {
-	// Get number of CPU cores
+// concurrencyWarning describes why the requested concurrency is above the
+// recommended 2x CPU cores, or returns "" when it is not or the user has opted
+// in. Backups are I/O-bound, so this is advisory; maxConcurrency is the hard cap.
+func concurrencyWarning(cfg *Config) string {
 	numCPU := runtime.NumCPU()
-
-	// Ensure concurrency doesn't exceed 2x number of CPU cores
-	if cfg.Concurrency > numCPU*2 {
-		return newBackupError(
-			"ValidateResources",
-			"",
-			fmt.Errorf("requested concurrency (%d) exceeds recommended maximum (%d) for %d CPU cores",
-				cfg.Concurrency, numCPU*2, numCPU),
-		)
+	if cfg.AllowHighConcurrency || cfg.Concurrency <= numCPU*2 {
+		return ""
 	}
+	return fmt.Sprintf("requested concurrency (%d) exceeds recommended maximum (%d) for %d CPU cores; "+
+		"set allow_high_concurrency to silence this warning", cfg.Concurrency, numCPU*2, numCPU)
+}
 
+// validateSystemResources checks if the system can handle the requested configuration
+func validateSystemResources(cfg *Config) error {
 	// Calculate total buffer size across all workers
 	totalBufferSize := int64(cfg.BufferSize) * int64(cfg.Concurrency)
 
@@ -168,42 +261,251 @@ func validateSystemResources(cfg *Config) error {
 
 // Validate performs comprehensive validation of the configuration
 func Validate(cfg *Config) error {
+	if err := validateConfig(cfg); err != nil {
+		return withSentinel(err, ErrInvalidConfig)
+	}
+	return nil
+}
+
+// validateConfig checks every setting and reports all problems at once
+func validateConfig(cfg *Config) error {
+	var problems ValidationErrors
+
 	// Basic validation
 	if cfg.SourceDirectory == "" {
-		return newBackupError("Validate", "", fmt.Errorf("source_directory is empty"))
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("source_directory is empty")))
+	} else if _, err := os.Stat(cfg.SourceDirectory); err != nil {
+		// Check source directory exists
+		problems = append(problems, newBackupError("Validate", cfg.SourceDirectory, withSentinel(fmt.Errorf("source directory does not exist"), ErrSourceNotFound)))
 	}
 	if cfg.TargetDirectory == "" {
-		return newBackupError("Validate", "", fmt.Errorf("target_directory is empty"))
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("target_directory is empty")))
 	}
 	if len(cfg.FoldersToBackup) == 0 {
-		return newBackupError("Validate", "", fmt.Errorf("folders_to_backup is empty"))
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("folders_to_backup is empty")))
 	}
-
-	// Check source directory exists
-	if _, err := os.Stat(cfg.SourceDirectory); err != nil {
-		return newBackupError("Validate", cfg.SourceDirectory, fmt.Errorf("source directory does not exist"))
+	for _, folder := range cfg.FoldersToBackup {
+		// Entries are joined to both source and target, so they must stay
+		// inside them: no absolute paths and no ".." out of the tree
+		if !filepath.IsLocal(folder) {
+			problems = append(problems, newBackupError("Validate", folder,
+				fmt.Errorf("folders_to_backup entries must be relative paths inside source_directory")))
+			continue
+		}
+		if _, err := filepath.Match(folder, ""); err != nil {
+			problems = append(problems, newBackupError("Validate", folder, fmt.Errorf("invalid folders_to_backup pattern: %v", err)))
+		}
 	}
 
 	// Worker and resource validation
-	if err := validateWorkerConfig(cfg); err != nil {
-		return err
-	}
+	problems = append(problems, validateWorkerConfig(cfg)...)
 
 	if err := validateSystemResources(cfg); err != nil {
-		return err
+		problems = append(problems, err)
+	}
+
+	if cfg.MinExpectedFiles < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("min_expected_files must not be negative, got %d", cfg.MinExpectedFiles)))
+	}
+
+	if cfg.VerifySampleRate < 0 || cfg.VerifySampleRate > 1 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("verify_sample_rate must be between 0.0 and 1.0, got %g", cfg.VerifySampleRate)))
+	}
+
+	if cfg.AutosaveEveryFiles < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("autosave_every_files must not be negative, got %d", cfg.AutosaveEveryFiles)))
+	}
+
+	if cfg.LogSampleEvery < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("log_sample_every must not be negative, got %d", cfg.LogSampleEvery)))
+	}
+
+	if cfg.QuickCompareBytes < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("quick_compare_bytes must not be negative, got %d", cfg.QuickCompareBytes)))
+	}
+
+	if cfg.MinFreeSpace < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("min_free_space must not be negative, got %d", cfg.MinFreeSpace)))
+	}
+
+	if cfg.RetentionMaxBytes < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("retention_max_bytes must not be negative, got %d", cfg.RetentionMaxBytes)))
+	}
+
+	if cfg.MaxDeletePercent < 0 || cfg.MaxDeletePercent > 100 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("max_delete_percent must be between 0 and 100, got %g", cfg.MaxDeletePercent)))
+	}
+
+	if cfg.TrashRetentionDays < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("trash_retention_days must not be negative, got %d", cfg.TrashRetentionDays)))
+	}
+
+	if cfg.LogRetentionCount < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("log_retention_count must not be negative, got %d", cfg.LogRetentionCount)))
+	}
+
+	if cfg.LogRetentionDays < 0 {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("log_retention_days must not be negative, got %d", cfg.LogRetentionDays)))
+	}
+
+	if _, err := newHasher(cfg.ChecksumAlgorithm); err != nil {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("checksum_algorithm must be one of %s, got %q",
+			strings.Join(supportedChecksumAlgorithms(), ", "), cfg.ChecksumAlgorithm)))
+	}
+
+	switch cfg.OnReadError {
+	case "", ReadErrorSkip, ReadErrorFail, ReadErrorKeepPartial:
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("on_read_error must be %q, %q or %q, got %q",
+			ReadErrorSkip, ReadErrorFail, ReadErrorKeepPartial, cfg.OnReadError)))
+	}
+
+	switch cfg.UnreadablePolicy {
+	case "", UnreadableSkip, UnreadableFail:
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("unreadable_policy must be %q or %q, got %q",
+			UnreadableSkip, UnreadableFail, cfg.UnreadablePolicy)))
+	}
+
+	switch cfg.OverwritePolicy {
+	case "", OverwriteAlways, OverwriteNever, OverwriteIfNewer, OverwriteIfDifferent:
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("overwrite_policy must be %q, %q, %q or %q, got %q",
+			OverwriteNever, OverwriteAlways, OverwriteIfNewer, OverwriteIfDifferent, cfg.OverwritePolicy)))
+	}
+
+	switch cfg.ZeroByteFiles {
+	case "", ZeroByteInclude, ZeroByteSkip, ZeroByteWarn:
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("zero_byte_files must be %q, %q or %q, got %q",
+			ZeroByteInclude, ZeroByteSkip, ZeroByteWarn, cfg.ZeroByteFiles)))
+	}
+
+	switch cfg.CaseConflictPolicy {
+	case "", CaseConflictIgnore, CaseConflictFail, CaseConflictRename:
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("case_conflict_policy must be %q, %q or %q, got %q",
+			CaseConflictIgnore, CaseConflictFail, CaseConflictRename, cfg.CaseConflictPolicy)))
+	}
+
+	if cfg.SummaryTemplate != "" {
+		if _, err := template.New("summary").Parse(cfg.SummaryTemplate); err != nil {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("invalid summary_template: %v", err)))
+		}
+	}
+
+	// Archives are written whole per version, so nothing on the target is
+	// compared, deleted or continued
+	switch cfg.TargetFormat {
+	case "", TargetFiles:
+	case TargetTar, TargetTarGz:
+		if cfg.MirrorMode {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("mirror_mode requires target_format %q", TargetFiles)))
+		}
+		if len(cfg.AdditionalTargets) > 0 {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("additional_targets requires target_format %q", TargetFiles)))
+		}
+		if cfg.DetectMoves {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("detect_moves requires target_format %q", TargetFiles)))
+		}
+		if cfg.Options != nil && cfg.Options.Resume {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("--resume requires target_format %q", TargetFiles)))
+		}
+		if cfg.SnapshotMode {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("snapshot_mode requires target_format %q", TargetFiles)))
+		}
+		if cfg.MetadataSidecar {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("metadata_sidecar requires target_format %q; archives keep attributes themselves", TargetFiles)))
+		}
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("target_format must be %q, %q or %q, got %q",
+			TargetFiles, TargetTar, TargetTarGz, cfg.TargetFormat)))
+	}
+	if cfg.CompressionLevel < 0 || cfg.CompressionLevel > gzip.BestCompression {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("compression_level must be 0 (default) or %d to %d, got %d", gzip.BestSpeed, gzip.BestCompression, cfg.CompressionLevel)))
+	} else if cfg.CompressionLevel != 0 && cfg.TargetFormat != TargetTarGz {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("compression_level requires target_format %q", TargetTarGz)))
+	}
+
+	// A resumed run would land in a new snapshot directory, not the one it left
+	if cfg.SnapshotMode && cfg.Options != nil && cfg.Options.Resume {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("--resume cannot be used with snapshot_mode")))
+	}
+
+	// Additional targets must be distinct from the main target and each other
+	seenTargets := map[string]bool{filepath.Clean(cfg.TargetDirectory): true}
+	for _, target := range cfg.AdditionalTargets {
+		if target == "" {
+			problems = append(problems, newBackupError("Validate", "", fmt.Errorf("additional_targets entry is empty")))
+			continue
+		}
+		if seenTargets[filepath.Clean(target)] {
+			problems = append(problems, newBackupError("Validate", target, fmt.Errorf("additional target duplicates another target")))
+		}
+		seenTargets[filepath.Clean(target)] = true
+	}
+	if len(cfg.AdditionalTargets) > 0 && cfg.MaxOpenFiles != 0 && cfg.MaxOpenFiles < 2+len(cfg.AdditionalTargets) {
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("max_open_files must be at least %d to copy to %d additional targets, got %d",
+			2+len(cfg.AdditionalTargets), len(cfg.AdditionalTargets), cfg.MaxOpenFiles)))
 	}
 
 	// Validate exclude patterns
+	switch cfg.PatternSyntax {
+	case "", PatternGlob, PatternRegex:
+	default:
+		problems = append(problems, newBackupError("Validate", "", fmt.Errorf("pattern_syntax must be %q or %q, got %q",
+			PatternGlob, PatternRegex, cfg.PatternSyntax)))
+	}
+	checkPattern := func(pattern string) error {
+		if cfg.PatternSyntax == PatternRegex {
+			_, err := regexp.Compile(pattern)
+			return err
+		}
+		_, err := path.Match(pattern, "test")
+		return err
+	}
 	for _, pattern := range cfg.ExcludePatterns {
-		if _, err := filepath.Match(pattern, "test"); err != nil {
-			return newBackupError(
+		if err := checkPattern(strings.TrimPrefix(pattern, "!")); err != nil {
+			problems = append(problems, newBackupError(
 				"Validate",
 				pattern,
 				fmt.Errorf("invalid exclude pattern: %v", err),
-			)
+			))
+		}
+	}
+
+	for _, pattern := range cfg.ImmutablePaths {
+		if err := checkPattern(pattern); err != nil {
+			problems = append(problems, newBackupError(
+				"Validate",
+				pattern,
+				fmt.Errorf("invalid immutable path: %v", err),
+			))
+		}
+	}
+
+	// Validate exclude paths: relative to the source directory and staying inside it
+	for _, excluded := range cfg.ExcludePaths {
+		if excluded == "" || filepath.IsAbs(excluded) || strings.HasPrefix(filepath.Clean(excluded), "..") {
+			problems = append(problems, newBackupError(
+				"Validate",
+				excluded,
+				fmt.Errorf("exclude path must be relative to source_directory"),
+			))
+			continue
+		}
+		if _, err := path.Match(filepath.ToSlash(excluded), "test"); err != nil {
+			problems = append(problems, newBackupError(
+				"Validate",
+				excluded,
+				fmt.Errorf("invalid exclude path: %v", err),
+			))
 		}
 	}
 
+	if len(problems) > 0 {
+		return problems
+	}
 	return nil
 }
 
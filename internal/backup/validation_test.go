@@ -0,0 +1,245 @@
+// validation_test.go
+package backup
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShouldSkipFileQuickCompare(t *testing.T) {
+	original := strings.Repeat("a", 64) + strings.Repeat("b", 64) + strings.Repeat("c", 64)
+	middleChanged := strings.Repeat("a", 64) + strings.Repeat("X", 64) + strings.Repeat("c", 64)
+	edgeChanged := strings.Repeat("a", 64) + strings.Repeat("b", 64) + strings.Repeat("c", 63) + "X"
+
+	tests := []struct {
+		name       string
+		backup     string
+		quickBytes int
+		deep       bool
+		wantSkip   bool
+	}{
+		{"identical, quick compare", original, 16, false, true},
+		{"size changed, quick compare", original + "c", 16, false, false},
+		{"size changed, deep check", original + "c", 16, true, false},
+		{"last bytes changed, quick compare", edgeChanged, 16, false, false},
+		// The heuristic misses a change confined to the middle
+		{"middle changed, quick compare", middleChanged, 16, false, true},
+		// deep_duplicate_check always hashes, so nothing slips through
+		{"middle changed, deep check", middleChanged, 16, true, false},
+		{"identical, deep check", original, 16, true, true},
+		{"file too small for quick compare", middleChanged, 128, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"big.bin": original})
+			cfg.QuickCompareBytes = tt.quickBytes
+			cfg.DeepDuplicateCheck = tt.deep
+			s := newTestService(t, cfg)
+
+			writeFiles(t, targetPath(cfg, "."), map[string]string{"big.bin": tt.backup})
+			modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			for _, path := range []string{sourcePath(cfg, "big.bin"), targetPath(cfg, "big.bin")} {
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			skip, err := s.shouldSkipFile(CopyTask{
+				Source:      sourcePath(cfg, "big.bin"),
+				Destination: targetPath(cfg, "big.bin"),
+				Size:        int64(len(original)),
+				ModTime:     modTime,
+			})
+			if err != nil {
+				t.Fatalf("shouldSkipFile: %v", err)
+			}
+			if skip != tt.wantSkip {
+				t.Errorf("shouldSkipFile = %v, want %v", skip, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestConcurrencyValidation(t *testing.T) {
+	high := runtime.NumCPU()*2 + 1
+	if high > maxConcurrency {
+		t.Skipf("%d CPUs: any valid concurrency is within 2x cores", runtime.NumCPU())
+	}
+	tests := []struct {
+		name        string
+		concurrency int
+		allowHigh   bool
+		wantErr     bool
+		wantWarning bool
+	}{
+		{"within 2x cores", 1, false, false, false},
+		{"above 2x cores warns", high, false, false, true},
+		{"above 2x cores allowed", high, true, false, false},
+		{"hard cap", maxConcurrency + 1, false, true, false},
+		{"hard cap with allow_high_concurrency", maxConcurrency + 1, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.Concurrency = tt.concurrency
+			cfg.AllowHighConcurrency = tt.allowHigh
+			if err := Validate(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if warning := concurrencyWarning(cfg); (warning != "") != tt.wantWarning {
+				t.Errorf("concurrencyWarning = %q, want warning %v", warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestShouldSkipFileUpdateMode(t *testing.T) {
+	sourceTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		update   bool
+		deep     bool
+		backup   string
+		age      time.Duration // Backup copy's modification time relative to the source
+		wantSkip bool
+	}{
+		{"newer edited copy kept", true, false, "edited on the target", time.Hour, true},
+		// The deep check would see different contents, but update mode wins
+		{"newer same-size edit kept, deep check", true, true, "SOURCE", time.Hour, true},
+		{"older copy replaced", true, false, "old", -time.Hour, false},
+		{"newer copy replaced without update mode", false, false, "edited on the target", time.Hour, false},
+		{"newer same-size edit replaced by deep check", false, true, "SOURCE", time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "source"})
+			cfg.UpdateMode = tt.update
+			cfg.DeepDuplicateCheck = tt.deep
+			s := newTestService(t, cfg)
+
+			writeFiles(t, targetPath(cfg, "."), map[string]string{"a.txt": tt.backup})
+			backupTime := sourceTime.Add(tt.age)
+			if err := os.Chtimes(sourcePath(cfg, "a.txt"), sourceTime, sourceTime); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(targetPath(cfg, "a.txt"), backupTime, backupTime); err != nil {
+				t.Fatal(err)
+			}
+
+			skip, err := s.shouldSkipFile(CopyTask{
+				Source:      sourcePath(cfg, "a.txt"),
+				Destination: targetPath(cfg, "a.txt"),
+				Size:        int64(len("source")),
+				ModTime:     sourceTime,
+			})
+			if err != nil {
+				t.Fatalf("shouldSkipFile: %v", err)
+			}
+			if skip != tt.wantSkip {
+				t.Errorf("shouldSkipFile = %v, want %v", skip, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cfg *Config)
+		want   []string
+	}{
+		{"valid", func(cfg *Config) {}, nil},
+		{"one problem", func(cfg *Config) { cfg.TargetDirectory = "" }, []string{"target_directory is empty"}},
+		{"several problems", func(cfg *Config) {
+			cfg.SourceDirectory = ""
+			cfg.FoldersToBackup = nil
+			cfg.VerifySampleRate = 2
+			cfg.ChecksumAlgorithm = "crc7"
+		}, []string{
+			"source_directory is empty",
+			"folders_to_backup is empty",
+			"verify_sample_rate must be between 0.0 and 1.0",
+			"checksum_algorithm must be one of",
+		}},
+		{"problems in one list", func(cfg *Config) {
+			cfg.FoldersToBackup = []string{"../outside", "/absolute", "[bad"}
+		}, []string{
+			"../outside",
+			"/absolute",
+			"invalid folders_to_backup pattern",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			tt.mutate(cfg)
+			err := Validate(cfg)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Validate error = %v, want %v", err, ErrInvalidConfig)
+			}
+			var problems ValidationErrors
+			if !errors.As(err, &problems) {
+				t.Fatalf("Validate error = %T, want ValidationErrors", err)
+			}
+			if len(problems) != len(tt.want) {
+				t.Errorf("got %d problems, want %d:\n%v", len(problems), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error does not mention %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFoldersToBackupValidation(t *testing.T) {
+	tests := []struct {
+		folder  string
+		wantErr bool
+	}{
+		{"data", false},
+		{"sub/dir/", false},
+		{"Photos/20*", false},
+		{"a/../data", false}, // Cleans to a path inside the source
+		{"../secrets", true},
+		{"..", true},
+		{"data/../../secrets", true},
+		{"/etc", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.folder, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			cfg.FoldersToBackup = []string{tt.folder}
+			err := Validate(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidConfig)
+			}
+			// The service refuses the entry before anything is read
+			s, err := NewService(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewService error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				s.logger.Close()
+			}
+		})
+	}
+}
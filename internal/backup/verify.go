@@ -0,0 +1,435 @@
+// verify.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VerifyResult describes how a backup version's files compare to the target
+type VerifyResult struct {
+	VersionID   string
+	Checked     int         // Number of manifest entries checked
+	Missing     []string    // Manifest keys whose backup copy is missing
+	Corrupt     []string    // Manifest keys whose backup copy doesn't match the manifest
+	Quarantined []string    // Paths corrupt copies were moved to, when quarantine is enabled
+	Stats       BackupStats // Progress counts: intact files as backed up, missing or corrupt ones as failed
+}
+
+// OK reports whether every file in the version was found intact
+func (r *VerifyResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0
+}
+
+// RepairResult describes the outcome of re-copying verify failures
+type RepairResult struct {
+	Verify        *VerifyResult
+	Repaired      int      // Files re-copied from the source
+	Unrepairable  []string // Manifest keys whose source no longer exists
+	SourceChanged []string // Manifest keys whose source no longer matches the version
+}
+
+// sourcePath resolves a manifest key against the configured source directory
+func (s *Service) sourcePath(key string) string {
+	return filepath.Join(s.config.SourceDirectory, filepath.FromSlash(key))
+}
+
+// targetPathFor resolves a manifest entry to its location under a version's
+// target directory, honoring any rename recorded when the file was backed up
+func targetPathFor(root, key string, metadata FileMetadata) string {
+	if metadata.TargetKey != "" {
+		key = metadata.TargetKey
+	}
+	return filepath.Join(root, filepath.FromSlash(key))
+}
+
+// Verify checks every file recorded in a version against the target. Files
+// with a recorded checksum are re-hashed; others are compared by size. Files
+// are checked concurrently on a worker pool sized by concurrency, with
+// progress tracked in metrics of its own, shown as a backup's is and returned
+// in the result. With QuarantineCorrupt set,
+// corrupt copies are moved aside to .quarantine and the quarantined paths are
+// recorded in the version.
+func (s *Service) Verify(ctx context.Context, versionID string) (*VerifyResult, error) {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{VersionID: version.ID}
+	root, cleanup, err := s.versionRoot(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	startedAt := time.Now()
+
+	// Tasks carry the backup copy as their source; keys maps it back to the manifest
+	keys := make(map[string]string, len(version.Files))
+	tasks := make([]CopyTask, 0, len(version.Files))
+	for key, metadata := range version.Files {
+		destPath := targetPathFor(root, key, metadata)
+		keys[destPath] = key
+		tasks = append(tasks, CopyTask{Source: destPath, Size: metadata.Size})
+	}
+
+	// Progress is tracked apart from s.metrics, which may belong to a backup
+	// in progress
+	metrics := NewBackupMetrics(len(tasks), s.config.Options.Quiet)
+	metrics.StartTracking(ctx)
+	defer metrics.Stop()
+	stopProgress := func() {}
+	if !s.config.Options.Quiet {
+		stopProgress = startProgressDisplay(ctx, metrics.DisplayProgress)
+	}
+	defer stopProgress()
+
+	var mu sync.Mutex
+	verifyFn := func(task CopyTask) error {
+		key := keys[task.Source]
+		corrupt, err := s.verifyFile(ctx, task.Source, version.Files[key])
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.Checked++
+		switch {
+		case corrupt == nil:
+			metrics.IncrementCompleted(task.Size)
+		case errors.Is(corrupt, os.ErrNotExist):
+			result.Missing = append(result.Missing, key)
+			metrics.IncrementFailed()
+		default:
+			result.Corrupt = append(result.Corrupt, key)
+			metrics.IncrementFailed()
+			if version.Archive == "" {
+				s.quarantineCorrupt(result, root, task.Source, startedAt)
+			}
+		}
+		return nil
+	}
+
+	pool := NewWorkerPool(s.config.Concurrency, verifyFn, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, tasks)
+
+	// Apply every queued update before the final progress line and stats
+	metrics.Stop()
+	stopProgress()
+	result.Stats = metrics.GetStats()
+	if err != nil {
+		return result, newBackupError("Verify", version.ID, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Corrupt)
+	sort.Strings(result.Quarantined)
+
+	if len(result.Quarantined) > 0 {
+		if err := s.versioner.AddQuarantined(version.ID, result.Quarantined); err != nil {
+			return result, newBackupError("Verify", version.ID, err)
+		}
+	}
+	return result, nil
+}
+
+// verifyFile compares one backup copy with its manifest entry. The first
+// return describes why the copy doesn't match (os.ErrNotExist when it is
+// missing) and is nil for an intact copy; the second is an I/O failure.
+func (s *Service) verifyFile(ctx context.Context, destPath string, metadata FileMetadata) (mismatch error, err error) {
+	info, err := os.Stat(destPath)
+	if os.IsNotExist(err) {
+		s.logger.Warn("Verify: missing backup copy %s", destPath)
+		return os.ErrNotExist, nil
+	} else if err != nil {
+		return nil, newBackupError("Verify", destPath, err)
+	}
+
+	if info.Size() != metadata.Size {
+		s.logger.Warn("Verify: size mismatch for %s (expected %d, got %d)",
+			destPath, metadata.Size, info.Size())
+		return fmt.Errorf("size mismatch"), nil
+	}
+
+	// A copy recorded as an append only needs its new bytes checked
+	if s.config.IncrementalVerify && metadata.AppendChecksum != "" {
+		match, checked, err := s.verifyAppended(ctx, destPath, metadata)
+		if err != nil {
+			return nil, newBackupError("Verify", destPath, err)
+		}
+		if checked && !match {
+			s.logger.Warn("Verify: checksum mismatch in bytes appended to %s", destPath)
+			return ErrChecksumMismatch, nil
+		}
+		if checked {
+			return nil, nil
+		}
+		s.logger.Info("Verify: bytes before the append to %s changed; verifying the whole copy", destPath)
+	}
+
+	if metadata.Checksum != "" {
+		checksum, err := s.calculateChecksumContext(ctx, destPath)
+		if err != nil {
+			return nil, newBackupError("Verify", destPath, err)
+		}
+		if checksum != metadata.Checksum {
+			s.logger.Warn("Verify: checksum mismatch for %s", destPath)
+			return ErrChecksumMismatch, nil
+		}
+	}
+	return nil, nil
+}
+
+// quarantineCorrupt moves a corrupt copy aside when quarantine is enabled.
+// A failed move is logged and leaves the copy for Repair to overwrite.
+func (s *Service) quarantineCorrupt(result *VerifyResult, root, destPath string, at time.Time) {
+	if !s.config.QuarantineCorrupt {
+		return
+	}
+
+	quarantinePath, err := quarantine(root, destPath, at)
+	if err != nil {
+		s.logger.Error("Verify: failed to quarantine %s: %v", destPath, err)
+		return
+	}
+	s.logger.Warn("Verify: quarantined corrupt copy %s to %s", destPath, quarantinePath)
+	result.Quarantined = append(result.Quarantined, quarantinePath)
+}
+
+// RestoredTreeResult describes how a directory compares to a version's
+// manifest, for a tree restored outside the target
+type RestoredTreeResult struct {
+	VersionID  string
+	Dir        string
+	Checked    int      // Number of manifest entries checked
+	Missing    []string // Manifest keys with no file in the directory
+	Mismatched []string // Manifest keys whose file doesn't match the manifest
+	Extra      []string // Files in the directory the version doesn't contain
+}
+
+// OK reports whether the directory holds exactly the version's files, intact
+func (r *RestoredTreeResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0 && len(r.Extra) == 0
+}
+
+// VerifyRestoredTree checks a directory holding a restored copy of a version
+// against its manifest without changing anything. Files are looked up by
+// manifest key as Restore writes them, or by their target-relative path, so
+// the target itself can be checked too; they are hashed on a worker pool as
+// Verify does. Extra files are looked for only below the version's top-level
+// folders, and metadata sidecars of restored files are not counted.
+func (s *Service) VerifyRestoredTree(ctx context.Context, versionID, dir string) (*RestoredTreeResult, error) {
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkChecksumAlgorithm(); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, newBackupError("VerifyRestore", dir, err)
+	} else if !info.IsDir() {
+		return nil, newBackupError("VerifyRestore", dir, fmt.Errorf("not a directory"))
+	}
+
+	result := &RestoredTreeResult{VersionID: version.ID, Dir: dir}
+
+	// Both the restored and the target-relative path identify an entry
+	expected := make(map[string]string, len(version.Files))
+	roots := make(map[string]bool)
+	for key, metadata := range version.Files {
+		expected[key] = key
+		if metadata.TargetKey != "" {
+			expected[metadata.TargetKey] = key
+		}
+		top, _, _ := strings.Cut(key, "/")
+		roots[top] = true
+	}
+
+	found := make(map[string]string, len(version.Files))
+	for top := range roots {
+		topPath := filepath.Join(dir, filepath.FromSlash(top))
+		err := filepath.WalkDir(topPath, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == topPath {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			rel := filepath.ToSlash(relPath)
+			if key, ok := expected[rel]; ok {
+				// Prefer the restored layout when both are present
+				if _, seen := found[key]; !seen || rel == key {
+					found[key] = path
+				}
+				return nil
+			}
+			if base, ok := strings.CutSuffix(rel, sidecarSuffix); ok {
+				if _, ok := expected[base]; ok {
+					return nil
+				}
+			}
+			result.Extra = append(result.Extra, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, newBackupError("VerifyRestore", topPath, err)
+		}
+	}
+
+	keys := make(map[string]string, len(found))
+	tasks := make([]CopyTask, 0, len(found))
+	for key, metadata := range version.Files {
+		path, ok := found[key]
+		if !ok {
+			result.Missing = append(result.Missing, key)
+			continue
+		}
+		keys[path] = key
+		tasks = append(tasks, CopyTask{Source: path, Size: metadata.Size})
+	}
+
+	var mu sync.Mutex
+	checkFn := func(task CopyTask) error {
+		key := keys[task.Source]
+		mismatch, err := s.verifyFile(ctx, task.Source, version.Files[key])
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.Checked++
+		switch {
+		case mismatch == nil:
+		case errors.Is(mismatch, os.ErrNotExist):
+			result.Missing = append(result.Missing, key)
+		default:
+			result.Mismatched = append(result.Mismatched, key)
+		}
+		return nil
+	}
+
+	pool := NewWorkerPool(s.config.Concurrency, checkFn, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, tasks)
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Mismatched)
+	sort.Strings(result.Extra)
+	if err != nil {
+		return result, newBackupError("VerifyRestore", dir, err)
+	}
+	return result, ctx.Err()
+}
+
+// Repair verifies a version and re-copies every missing or corrupt file from
+// the source, leaving intact files untouched. A source that no longer matches
+// the version's checksum isn't copied, since the copy would still fail Verify.
+func (s *Service) Repair(ctx context.Context, versionID string) (*RepairResult, error) {
+	if version, err := s.GetVersion(versionID); err != nil {
+		return nil, err
+	} else if err := requireFileTree("Repair", version); err != nil {
+		return nil, err
+	}
+
+	verifyResult, err := s.Verify(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepairResult{Verify: verifyResult}
+	if verifyResult.OK() {
+		return result, nil
+	}
+
+	version, err := s.GetVersion(versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []CopyTask
+	for _, key := range append(verifyResult.Missing, verifyResult.Corrupt...) {
+		srcPath := s.sourcePath(key)
+		info, err := os.Stat(srcPath)
+		if os.IsNotExist(err) {
+			result.Unrepairable = append(result.Unrepairable, key)
+			continue
+		} else if err != nil {
+			return result, newBackupError("Repair", srcPath, err)
+		}
+
+		metadata := version.Files[key]
+		if changed, err := s.sourceChanged(ctx, srcPath, info, metadata); err != nil {
+			return result, newBackupError("Repair", srcPath, err)
+		} else if changed {
+			s.logger.Warn("Repair: %s changed since version %s; not copied", srcPath, version.ID)
+			result.SourceChanged = append(result.SourceChanged, key)
+			continue
+		}
+
+		tasks = append(tasks, CopyTask{
+			Source:      srcPath,
+			Destination: targetPathFor(s.versionTarget(version), key, metadata),
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+		})
+	}
+	sort.Strings(result.Unrepairable)
+	sort.Strings(result.SourceChanged)
+
+	// Copy unconditionally; the skip check would trust a same-size corrupt copy
+	s.metrics = NewBackupMetrics(len(tasks), true)
+	s.metrics.StartTracking(ctx)
+	defer s.metrics.Stop()
+
+	pool := NewWorkerPool(s.config.Concurrency, s.performCopy, s.config.RetryAttempts, s.config.RetryDelay)
+	err = pool.Execute(ctx, tasks)
+
+	result.Repaired = len(tasks)
+	var summary *ErrorSummary
+	if errors.As(err, &summary) {
+		result.Repaired -= summary.Total()
+	}
+	if err != nil {
+		return result, fmt.Errorf("repair incomplete: %w", err)
+	}
+	return result, nil
+}
+
+// sourceChanged reports whether a source file differs from its manifest
+// entry, by checksum when one was recorded and otherwise by size
+func (s *Service) sourceChanged(ctx context.Context, srcPath string, info os.FileInfo, metadata FileMetadata) (bool, error) {
+	if info.Size() != metadata.Size {
+		return true, nil
+	}
+	if metadata.Checksum == "" {
+		return false, nil
+	}
+	checksum, err := s.calculateChecksumContext(ctx, srcPath)
+	if err != nil {
+		return false, err
+	}
+	return checksum != metadata.Checksum, nil
+}
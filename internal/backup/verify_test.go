@@ -0,0 +1,167 @@
+// verify_test.go
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	files := map[string]string{
+		"a.txt":     "alpha",
+		"sub/b.txt": "bravo",
+		"sub/c.txt": "charlie",
+	}
+	tests := []struct {
+		name    string
+		damage  func(t *testing.T, cfg *Config)
+		missing []string
+		corrupt []string
+	}{
+		{name: "intact"},
+		{
+			name: "missing copy",
+			damage: func(t *testing.T, cfg *Config) {
+				if err := os.Remove(targetPath(cfg, "sub/b.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			missing: []string{"data/sub/b.txt"},
+		},
+		{
+			name: "corrupt copy",
+			damage: func(t *testing.T, cfg *Config) {
+				if err := os.WriteFile(targetPath(cfg, "a.txt"), []byte("ALPHA"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			corrupt: []string{"data/a.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, files)
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+			if tt.damage != nil {
+				tt.damage(t, cfg)
+			}
+
+			// Verify keeps its progress apart from the metrics of a backup
+			metrics := NewBackupMetrics(1, true)
+			s.metrics = metrics
+			verify, err := s.Verify(context.Background(), result.VersionID)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if s.metrics != metrics {
+				t.Error("Verify replaced the service metrics")
+			}
+			if verify.Checked != len(files) {
+				t.Errorf("checked %d files, want %d", verify.Checked, len(files))
+			}
+			bad := len(tt.missing) + len(tt.corrupt)
+			if stats := verify.Stats; stats.TotalFiles != len(files) || stats.FilesBackedUp != len(files)-bad || stats.FilesFailed != bad {
+				t.Errorf("stats = %d of %d intact, %d failed; want %d of %d, %d failed",
+					stats.FilesBackedUp, stats.TotalFiles, stats.FilesFailed, len(files)-bad, len(files), bad)
+			}
+			if got, want := strings.Join(verify.Missing, ","), strings.Join(tt.missing, ","); got != want {
+				t.Errorf("missing = %s, want %s", got, want)
+			}
+			if got, want := strings.Join(verify.Corrupt, ","), strings.Join(tt.corrupt, ","); got != want {
+				t.Errorf("corrupt = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// BenchmarkVerify re-hashes a version of many medium files serially and on
+// several workers
+func BenchmarkVerify(b *testing.B) {
+	const count, size = 200, 256 << 10
+	files := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		files[fmt.Sprintf("dir%02d/file%03d.bin", i%10, i)] = strings.Repeat(fmt.Sprintf("%08d", i), size/8)
+	}
+	cfg := newTestConfig(b, files)
+	result := runBackup(b, newTestService(b, cfg))
+
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			cfg.Concurrency = concurrency
+			s := newTestService(b, cfg)
+			b.SetBytes(count * size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				verify, err := s.Verify(context.Background(), result.VersionID)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !verify.OK() {
+					b.Fatalf("verify found problems: %+v", verify)
+				}
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string // New source content, if any
+		removed     bool   // The source is deleted before the repair
+		wantCopy    string // Backup copy after the repair, "" if still missing
+		wantFixed   int
+		wantGone    bool
+		wantChanged bool
+	}{
+		{name: "source unchanged", wantCopy: "alpha", wantFixed: 1},
+		{name: "source removed", removed: true, wantGone: true},
+		{name: "source edited, same size", source: "ALPHA", wantChanged: true},
+		{name: "source resized", source: "alpha, longer", wantChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+			s := newTestService(t, cfg)
+			result := runBackup(t, s)
+			if err := os.Remove(targetPath(cfg, "a.txt")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.source != "" {
+				writeFiles(t, sourcePath(cfg, ""), map[string]string{"a.txt": tt.source})
+			}
+			if tt.removed {
+				if err := os.Remove(sourcePath(cfg, "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			repair, err := s.Repair(context.Background(), result.VersionID)
+			if err != nil {
+				t.Fatalf("Repair: %v", err)
+			}
+			if repair.Repaired != tt.wantFixed {
+				t.Errorf("Repaired = %d, want %d", repair.Repaired, tt.wantFixed)
+			}
+			if gone := len(repair.Unrepairable) == 1; gone != tt.wantGone {
+				t.Errorf("Unrepairable = %v, want source gone %v", repair.Unrepairable, tt.wantGone)
+			}
+			if changed := slices.Equal(repair.SourceChanged, []string{"data/a.txt"}); changed != tt.wantChanged {
+				t.Errorf("SourceChanged = %v, want source changed %v", repair.SourceChanged, tt.wantChanged)
+			}
+
+			if tt.wantCopy == "" {
+				if _, err := os.Stat(targetPath(cfg, "a.txt")); !os.IsNotExist(err) {
+					t.Error("a.txt was copied from a source that no longer matches the version")
+				}
+			} else if got := readFile(t, targetPath(cfg, "a.txt")); got != tt.wantCopy {
+				t.Errorf("a.txt = %q, want %q", got, tt.wantCopy)
+			}
+		})
+	}
+}
@@ -25,29 +25,56 @@ package backup
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 )
 
+// ToolVersion identifies the build that produced a backup. It is set at
+// build time with -ldflags "-X github.com/jack-sneddon/backup-butler/internal/backup.ToolVersion=<version>".
+var ToolVersion = "dev"
+
+// Version status values
+const (
+	StatusInProgress = "In Progress"
+	StatusCompleted  = "Completed"
+	StatusPartial    = "Partial"
+)
+
 // BackupVersion represents a single backup operation
 type BackupVersion struct {
-	ID         string                  // Unique identifier (timestamp-based)
-	Timestamp  time.Time               // When backup was performed
-	Files      map[string]FileMetadata // Map of path to file metadata
-	Size       int64                   // Total size of backup
-	Status     string                  // Success, Failed, Partial
-	Duration   time.Duration           // How long the backup took
-	Stats      BackupStats             // Additional statistics
-	ConfigUsed Config                  // Configuration used for this backup
+	ID          string                  // Unique identifier (timestamp-based)
+	Timestamp   time.Time               // When backup was performed
+	Files       map[string]FileMetadata // Map of source-relative, slash-separated path to file metadata
+	Size        int64                   // Total size of backup
+	Status      string                  // Success, Failed, Partial
+	Duration    time.Duration           // How long the backup took
+	AverageMBps float64                 // Average throughput of copied data
+	Stats       BackupStats             // Additional statistics
+	ConfigUsed  Config                  // Configuration used for this backup
+	Hostname    string                  // Host that performed the backup
+	ToolVersion string                  // Build of backup-butler that performed the backup
+	Hooks       []HookResult            // Pre- and post-backup command results
+	Snapshot    string                  // Filesystem snapshot type read from, empty for a live copy
+	Archive     string                  // Archive file under the target holding the files, empty for a file tree
+	RootHash    string                  // Merkle root over path-sorted file checksums, with root_hash
+	SinceLast   string                  // With --since-last, the version unmodified files were trusted from
+	Quarantined []string                // Corrupt backup copies moved to .quarantine by verification
 }
 
 // VersionManager handles backup versioning
 type VersionManager struct {
+	mu         sync.Mutex      // Guards currentVer against concurrent workers
 	baseDir    string          // Base directory for version storage
 	versions   []BackupVersion // List of all versions
 	currentVer *BackupVersion  // Current backup version being processed
+	compress   bool            // Write manifests gzipped
+	latestLink bool            // Maintain the latest.json link to the newest completed version
+	skipped    []error         // Version files that could not be read at load
 }
 
 func NewVersionManager(baseDir string) (*VersionManager, error) {
@@ -70,18 +97,69 @@ func NewVersionManager(baseDir string) (*VersionManager, error) {
 }
 
 func (vm *VersionManager) StartNewVersion(cfg *Config) *BackupVersion {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
 	version := &BackupVersion{
-		ID:         time.Now().Format("20060102-150405"),
-		Timestamp:  time.Now(),
-		Files:      make(map[string]FileMetadata),
-		Status:     "In Progress",
-		ConfigUsed: *cfg,
+		ID:          time.Now().Format("20060102-150405"),
+		Timestamp:   time.Now(),
+		Files:       make(map[string]FileMetadata),
+		Status:      StatusInProgress,
+		ConfigUsed:  *cfg,
+		Hostname:    hostname,
+		ToolVersion: ToolVersion,
 	}
 	vm.currentVer = version
 	return version
 }
 
+// CurrentVersionID returns the ID of the version in progress, or "" if none
+func (vm *VersionManager) CurrentVersionID() string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.currentVer == nil {
+		return ""
+	}
+	return vm.currentVer.ID
+}
+
+// SetHookResults records the pre- and post-backup command results on the current version
+func (vm *VersionManager) SetHookResults(results []HookResult) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.currentVer != nil {
+		vm.currentVer.Hooks = results
+	}
+}
+
+// CurrentFiles returns a copy of the file entries of the version in progress
+func (vm *VersionManager) CurrentFiles() map[string]FileMetadata {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.currentVer == nil {
+		return nil
+	}
+	files := make(map[string]FileMetadata, len(vm.currentVer.Files))
+	for key, metadata := range vm.currentVer.Files {
+		files[key] = metadata
+	}
+	return files
+}
+
+// SetRootHash records the Merkle root of the version in progress
+func (vm *VersionManager) SetRootHash(root string) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.currentVer != nil {
+		vm.currentVer.RootHash = root
+	}
+}
+
 func (vm *VersionManager) AddFile(path string, metadata FileMetadata) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
 	if vm.currentVer != nil {
 		vm.currentVer.Files[path] = metadata
 		vm.currentVer.Size += metadata.Size
@@ -90,41 +168,100 @@ func (vm *VersionManager) AddFile(path string, metadata FileMetadata) {
 	}
 }
 
-func (vm *VersionManager) CompleteVersion(stats BackupStats) error {
+// CompleteVersion finalizes the current version with the given status and saves it
+func (vm *VersionManager) CompleteVersion(stats BackupStats, status string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
 	if vm.currentVer == nil {
 		return fmt.Errorf("no backup version in progress")
 	}
 
-	vm.currentVer.Status = "Completed"
+	vm.currentVer.Status = status
 	vm.currentVer.Duration = time.Since(vm.currentVer.Timestamp)
 	vm.currentVer.Stats = stats
+	if seconds := vm.currentVer.Duration.Seconds(); seconds > 0 {
+		vm.currentVer.AverageMBps = float64(stats.BytesTransferred) / 1024 / 1024 / seconds
+	}
 
 	// Save the version
 	if err := vm.saveVersion(vm.currentVer); err != nil {
 		return err
 	}
 
-	vm.versions = append(vm.versions, *vm.currentVer)
+	// The version is saved, so it is recorded even if the housekeeping
+	// after it fails
+	completed := *vm.currentVer
+	vm.versions = append(vm.versions, completed)
 	vm.currentVer = nil
 
-	return nil
+	var errs []error
+	if err := vm.updateLifetimeStats(&completed); err != nil {
+		errs = append(errs, fmt.Errorf("version %s saved, but lifetime stats were not updated: %w", completed.ID, err))
+	}
+	if err := os.Remove(vm.partialFilename(completed.ID)); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("failed to remove partial version: %w", err))
+	}
+	if vm.latestLink && status == StatusCompleted {
+		if err := vm.updateLatest(completed.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update latest version link: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
+// saveVersion writes ver in the configured format and removes a copy left in
+// the other format, so toggling compress_versions converts manifests as they
+// are rewritten
 func (vm *VersionManager) saveVersion(ver *BackupVersion) error {
-	filename := filepath.Join(vm.baseDir, ".versions", ver.ID+".json")
+	filename := vm.versionPath(ver.ID)
 
 	data, err := json.MarshalIndent(ver, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal version data: %w", err)
 	}
+	data, err = encodeVersionFile(filename, data)
+	if err != nil {
+		return fmt.Errorf("failed to compress version data: %w", err)
+	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	// Write atomically so a crash never leaves a half-written manifest
+	if err := writeFileAtomic(filename, data, 0644); err != nil {
 		return fmt.Errorf("failed to save version file: %w", err)
 	}
 
+	other := filepath.Join(vm.baseDir, ".versions", ver.ID+versionExt)
+	if !vm.compress {
+		other = filepath.Join(vm.baseDir, ".versions", ver.ID+compressedVersionExt)
+	}
+	if err := os.Remove(other); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale version file: %w", err)
+	}
+
+	// The manifest may have changed format under the link
+	if vm.latestLink && vm.latestID() == ver.ID {
+		if err := vm.updateLatest(ver.ID); err != nil {
+			return fmt.Errorf("failed to update latest version link: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// versionIndex decodes a version file without its Files map, which dominates
+// both the size of the file and the cost of holding it in memory
+type versionIndex struct {
+	BackupVersion
+	Files skipJSON
+}
+
+// skipJSON discards a JSON value without allocating it
+type skipJSON struct{}
+
+func (skipJSON) UnmarshalJSON([]byte) error { return nil }
+
+// loadVersions reads the index of every version. Files maps are left nil and
+// loaded on demand by loadFiles. A version file that can't be read or parsed
+// is recorded in vm.skipped rather than failing the whole load.
 func (vm *VersionManager) loadVersions() error {
 	versionsDir := filepath.Join(vm.baseDir, ".versions")
 	entries, err := os.ReadDir(versionsDir)
@@ -135,42 +272,214 @@ func (vm *VersionManager) loadVersions() error {
 		return fmt.Errorf("failed to read versions directory: %w", err)
 	}
 
+	seen := make(map[string]bool)
 	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
-			filename := filepath.Join(versionsDir, entry.Name())
-			data, err := os.ReadFile(filename)
+		id := versionID(entry.Name())
+		// A manifest can briefly exist in both formats while being converted
+		if !entry.IsDir() && id != "" && !seen[id] {
+			seen[id] = true
+			filename := vm.existingVersionPath(id)
+			data, err := readVersionFile(filename)
 			if err != nil {
-				return fmt.Errorf("failed to read version file %s: %w", entry.Name(), err)
+				vm.skipped = append(vm.skipped, fmt.Errorf("failed to read version file %s: %w", filepath.Base(filename), err))
+				continue
 			}
 
-			var version BackupVersion
-			if err := json.Unmarshal(data, &version); err != nil {
-				return fmt.Errorf("failed to parse version file %s: %w", entry.Name(), err)
+			var index versionIndex
+			if err := json.Unmarshal(data, &index); err != nil {
+				vm.skipped = append(vm.skipped, fmt.Errorf("failed to parse version file %s: %w", filepath.Base(filename), err))
+				continue
 			}
 
-			vm.versions = append(vm.versions, version)
+			vm.versions = append(vm.versions, index.BackupVersion)
 		}
 	}
 
 	return nil
 }
 
+// loadFiles reads the Files map of an indexed version if it is not loaded yet.
+// Callers must hold vm.mu.
+func (vm *VersionManager) loadFiles(ver *BackupVersion) error {
+	if ver.Files != nil {
+		return nil
+	}
+
+	filename := vm.existingVersionPath(ver.ID)
+	data, err := readVersionFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read version file %s: %w", filepath.Base(filename), err)
+	}
+
+	var full BackupVersion
+	if err := json.Unmarshal(data, &full); err != nil {
+		return fmt.Errorf("failed to parse version file %s: %w", filepath.Base(filename), err)
+	}
+	ver.Files = full.Files
+	if ver.Files == nil {
+		ver.Files = make(map[string]FileMetadata)
+	}
+
+	// Persisting the migration is best effort; a read-only target
+	// simply migrates again in memory on the next load
+	if ver.migrateKeys() {
+		_ = vm.saveVersion(ver)
+	}
+
+	return nil
+}
+
+// Prune removes the oldest version manifests until the cumulative size of the
+// remaining versions is within maxBytes. The latest version is never removed.
+// It returns the IDs of the pruned versions.
+func (vm *VersionManager) Prune(maxBytes int64) ([]string, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	sort.SliceStable(vm.versions, func(i, j int) bool {
+		return vm.versions[i].Timestamp.Before(vm.versions[j].Timestamp)
+	})
+
+	// Walk newest to oldest; everything older than the point where the cap
+	// is exceeded gets pruned
+	keepFrom := 0
+	var cumulative int64
+	for i := len(vm.versions) - 1; i >= 0; i-- {
+		cumulative += vm.versions[i].Size
+		if cumulative > maxBytes && i < len(vm.versions)-1 {
+			keepFrom = i + 1
+			break
+		}
+	}
+
+	var pruned []string
+	for _, ver := range vm.versions[:keepFrom] {
+		if err := vm.removeVersionFiles(ver.ID); err != nil {
+			vm.versions = vm.versions[len(pruned):]
+			return pruned, fmt.Errorf("failed to remove version %s: %w", ver.ID, err)
+		}
+		if ver.Archive != "" {
+			archivePath := filepath.Join(ver.ConfigUsed.TargetDirectory, ver.Archive)
+			if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+				vm.versions = vm.versions[len(pruned):]
+				return pruned, fmt.Errorf("failed to remove archive of version %s: %w", ver.ID, err)
+			}
+		}
+		pruned = append(pruned, ver.ID)
+	}
+	vm.versions = vm.versions[keepFrom:]
+
+	return pruned, nil
+}
+
+// ReplaceFiles swaps a completed version's file entries and recorded
+// checksum algorithm, then saves it. Its root hash, computed with the old
+// algorithm, is dropped.
+func (vm *VersionManager) ReplaceFiles(id string, files map[string]FileMetadata, algorithm string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for i := range vm.versions {
+		if vm.versions[i].ID == id {
+			vm.versions[i].Files = files
+			vm.versions[i].ConfigUsed.ChecksumAlgorithm = algorithm
+			vm.versions[i].RootHash = ""
+			return vm.saveVersion(&vm.versions[i])
+		}
+	}
+	return fmt.Errorf("version not found: %s", id)
+}
+
+// AddQuarantined records quarantined copies on a completed version and saves it
+func (vm *VersionManager) AddQuarantined(id string, paths []string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for i := range vm.versions {
+		if vm.versions[i].ID == id {
+			if err := vm.loadFiles(&vm.versions[i]); err != nil {
+				return err
+			}
+			vm.versions[i].Quarantined = append(vm.versions[i].Quarantined, paths...)
+			return vm.saveVersion(&vm.versions[i])
+		}
+	}
+	return fmt.Errorf("version not found: %s", id)
+}
+
+// GetVersions returns the index of every version. Files maps are only
+// populated for versions already loaded through GetVersion.
 func (vm *VersionManager) GetVersions() []BackupVersion {
 	return vm.versions
 }
 
+// GetVersion returns a version with its Files map, loading it on first use
 func (vm *VersionManager) GetVersion(id string) (*BackupVersion, error) {
-	for _, ver := range vm.versions {
-		if ver.ID == id {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for i := range vm.versions {
+		if vm.versions[i].ID == id {
+			if err := vm.loadFiles(&vm.versions[i]); err != nil {
+				return nil, err
+			}
+			ver := vm.versions[i]
 			return &ver, nil
 		}
 	}
 	return nil, fmt.Errorf("version not found: %s", id)
 }
 
-func (vm *VersionManager) GetLatestVersion() *BackupVersion {
+// GetLatestVersion returns the most recent version with its Files map, or nil
+// if there are none
+func (vm *VersionManager) GetLatestVersion() (*BackupVersion, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
 	if len(vm.versions) == 0 {
-		return nil
+		return nil, nil
+	}
+	latest := &vm.versions[len(vm.versions)-1]
+	if err := vm.loadFiles(latest); err != nil {
+		return nil, err
+	}
+	return latest, nil
+}
+
+// migrateKeys rekeys versions written with absolute source paths to
+// source-relative keys. It reports whether anything changed.
+func (v *BackupVersion) migrateKeys() bool {
+	migrated := false
+	files := make(map[string]FileMetadata, len(v.Files))
+	for key, metadata := range v.Files {
+		if filepath.IsAbs(key) {
+			if relPath, err := filepath.Rel(v.ConfigUsed.SourceDirectory, key); err == nil {
+				key = filepath.ToSlash(relPath)
+				metadata.Path = key
+				migrated = true
+			}
+		}
+		files[key] = metadata
+	}
+	v.Files = files
+	return migrated
+}
+
+// SlowestFiles returns up to n copied files ordered by copy duration, slowest first
+func (v *BackupVersion) SlowestFiles(n int) []FileMetadata {
+	var copied []FileMetadata
+	for _, metadata := range v.Files {
+		if metadata.Duration > 0 {
+			copied = append(copied, metadata)
+		}
+	}
+
+	sort.Slice(copied, func(i, j int) bool {
+		return copied[i].Duration > copied[j].Duration
+	})
+
+	if len(copied) > n {
+		copied = copied[:n]
 	}
-	return &vm.versions[len(vm.versions)-1]
+	return copied
 }
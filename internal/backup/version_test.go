@@ -0,0 +1,272 @@
+// version_test.go
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCompleteVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, baseDir string)
+		wantErr bool
+	}{
+		{"saved", nil, false},
+		{
+			name: "lifetime stats unreadable",
+			setup: func(t *testing.T, baseDir string) {
+				// A directory in place of the stats file can't be read
+				if err := os.MkdirAll(filepath.Join(baseDir, ".versions", lifetimeStatsFile), 0755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			vm, err := NewVersionManager(baseDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(t, baseDir)
+			}
+
+			cfg := newTestConfig(t, nil)
+			version := vm.StartNewVersion(cfg)
+			vm.AddFile("data/a.txt", FileMetadata{Path: "data/a.txt", Size: 5})
+
+			err = vm.CompleteVersion(BackupStats{TotalFiles: 1}, StatusCompleted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompleteVersion error = %v, want error %v", err, tt.wantErr)
+			}
+
+			// However the housekeeping went, the saved version is recorded
+			// and no longer in progress
+			if id := vm.CurrentVersionID(); id != "" {
+				t.Errorf("version %s still in progress", id)
+			}
+			versions := vm.GetVersions()
+			if len(versions) != 1 || versions[0].ID != version.ID {
+				t.Fatalf("versions = %v, want just %s", versions, version.ID)
+			}
+			if _, err := vm.GetVersion(version.ID); err != nil {
+				t.Errorf("GetVersion: %v", err)
+			}
+		})
+	}
+}
+
+func TestLatestLink(t *testing.T) {
+	type run struct {
+		id     string
+		status string
+	}
+	tests := []struct {
+		name        string
+		disabled    bool
+		compress    bool
+		noSymlink   bool // Block the link so the pointer file is written
+		runs        []run
+		wantID      string
+		wantPointer bool
+	}{
+		{name: "disabled", disabled: true, runs: []run{{"v1", StatusCompleted}}},
+		{name: "first version", runs: []run{{"v1", StatusCompleted}}, wantID: "v1"},
+		{name: "newest version", runs: []run{{"v1", StatusCompleted}, {"v2", StatusCompleted}}, wantID: "v2"},
+		{name: "partial run keeps the link", runs: []run{{"v1", StatusCompleted}, {"v2", StatusPartial}}, wantID: "v1"},
+		{name: "no completed version", runs: []run{{"v1", StatusPartial}}},
+		{name: "compressed manifest", compress: true, runs: []run{{"v1", StatusCompleted}}, wantID: "v1"},
+		{name: "pointer file", noSymlink: true, runs: []run{{"v1", StatusCompleted}}, wantID: "v1", wantPointer: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			vm, err := NewVersionManager(baseDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vm.latestLink = !tt.disabled
+			vm.compress = tt.compress
+			versionsDir := filepath.Join(baseDir, ".versions")
+			link := filepath.Join(versionsDir, latestLinkFile)
+
+			cfg := newTestConfig(t, nil)
+			for _, r := range tt.runs {
+				if tt.noSymlink {
+					// Something in the way of the temporary link fails the symlink
+					writeFiles(t, link+".tmp-"+r.id, map[string]string{"blocker": "x"})
+				}
+				version := vm.StartNewVersion(cfg)
+				version.ID = r.id
+				if err := vm.CompleteVersion(BackupStats{}, r.status); err != nil {
+					t.Fatalf("CompleteVersion %s: %v", r.id, err)
+				}
+			}
+
+			if got := vm.latestID(); got != tt.wantID {
+				t.Errorf("latestID = %q, want %q", got, tt.wantID)
+			}
+			_, err = os.Stat(filepath.Join(versionsDir, latestPointerFile))
+			if gotPointer := err == nil; gotPointer != tt.wantPointer {
+				t.Errorf("pointer file written = %v, want %v", gotPointer, tt.wantPointer)
+			}
+			target, err := os.Readlink(link)
+			if tt.wantID == "" || tt.wantPointer {
+				if err == nil {
+					t.Errorf("latest.json links to %s, want no link", target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("latest.json: %v", err)
+			}
+
+			// The link resolves to the manifest, in whichever format it was written
+			data, err := readVersionFile(filepath.Join(versionsDir, target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest BackupVersion
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if manifest.ID != tt.wantID {
+				t.Errorf("latest.json manifest is %s, want %s", manifest.ID, tt.wantID)
+			}
+			// latest.json is not mistaken for a version when the index loads
+			if vm, err = NewVersionManager(baseDir); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(vm.GetVersions()); n != len(tt.runs) {
+				t.Errorf("reloaded %d versions, want %d", n, len(tt.runs))
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name     string
+		sizes    []int64 // Oldest first
+		maxBytes int64
+		want     []string
+	}{
+		{"under the cap", []int64{10, 20, 30}, 100, nil},
+		{"exactly the cap", []int64{10, 20, 30}, 60, nil},
+		{"oldest pruned", []int64{10, 20, 30}, 50, []string{"v0"}},
+		{"several pruned", []int64{40, 10, 20, 30}, 55, []string{"v0", "v1"}},
+		// The newest version is kept even when it alone exceeds the cap
+		{"latest over the cap", []int64{10, 20, 300}, 100, []string{"v0", "v1"}},
+		{"single version", []int64{300}, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			vm, err := NewVersionManager(baseDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			// Saved newest first, so pruning can't rely on file order
+			for i := len(tt.sizes) - 1; i >= 0; i-- {
+				ver := &BackupVersion{ID: fmt.Sprintf("v%d", i), Timestamp: start.Add(time.Duration(i) * time.Hour), Size: tt.sizes[i]}
+				if err := vm.saveVersion(ver); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if vm, err = NewVersionManager(baseDir); err != nil {
+				t.Fatal(err)
+			}
+
+			pruned, err := vm.Prune(tt.maxBytes)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			if !slices.Equal(pruned, tt.want) {
+				t.Errorf("pruned %v, want %v", pruned, tt.want)
+			}
+			if got, want := len(vm.GetVersions()), len(tt.sizes)-len(tt.want); got != want {
+				t.Errorf("%d versions remain, want %d", got, want)
+			}
+			for _, id := range tt.want {
+				if _, err := os.Stat(vm.existingVersionPath(id)); !os.IsNotExist(err) {
+					t.Errorf("version file of %s is still there", id)
+				}
+			}
+		})
+	}
+}
+
+// writeBenchmarkVersions saves count versions of files entries each under baseDir
+func writeBenchmarkVersions(b *testing.B, baseDir string, count, files int) {
+	b.Helper()
+	vm, err := NewVersionManager(baseDir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	manifest := make(map[string]FileMetadata, files)
+	for i := 0; i < files; i++ {
+		key := fmt.Sprintf("data/dir%03d/file%05d.txt", i%100, i)
+		manifest[key] = FileMetadata{Path: key, Size: int64(i), Checksum: fmt.Sprintf("%064x", i)}
+	}
+	for i := 0; i < count; i++ {
+		ver := &BackupVersion{ID: fmt.Sprintf("20240101-%06d", i), Status: StatusCompleted, Files: manifest}
+		if err := vm.saveVersion(ver); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetVersions compares reading every manifest in full, as loading
+// used to, with the index-only load that startup does now
+func BenchmarkGetVersions(b *testing.B) {
+	const versions, files = 200, 2000
+	baseDir := b.TempDir()
+	writeBenchmarkVersions(b, baseDir, versions, files)
+
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vm, err := NewVersionManager(baseDir)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, ver := range vm.GetVersions() {
+				if _, err := vm.GetVersion(ver.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vm, err := NewVersionManager(baseDir)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if got := len(vm.GetVersions()); got != versions {
+				b.Fatalf("loaded %d versions, want %d", got, versions)
+			}
+		}
+	})
+	// --latest-version loads the index and one manifest
+	b.Run("latest", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vm, err := NewVersionManager(baseDir)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := vm.GetLatestVersion(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// versiondiff.go
+package backup
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VersionDiffResult describes how one stored version differs from another
+type VersionDiffResult struct {
+	From, To  string
+	Added     []string // Files in To that From doesn't have
+	Modified  []string // Files whose size or checksum differs between the versions
+	Deleted   []string // Files in From that are gone from To
+	Unchanged int
+	SameRoot  bool // Both versions recorded the same root hash; no files were compared
+}
+
+// DiffVersions compares the manifests of two versions. When both recorded a
+// root hash and the roots match, the versions hold the same paths and
+// contents and the manifests are not loaded at all. Otherwise files are
+// compared by size, and by checksum where both versions recorded one.
+// Paths are manifest keys, relative to the source directory.
+func (s *Service) DiffVersions(fromID, toID string) (*VersionDiffResult, error) {
+	from, err := s.versionIndex(fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.versionIndex(toID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VersionDiffResult{From: from.ID, To: to.ID}
+	if from.RootHash != "" && from.RootHash == to.RootHash {
+		result.SameRoot = true
+		return result, nil
+	}
+
+	fromVer, err := s.GetVersion(fromID)
+	if err != nil {
+		return nil, err
+	}
+	toVer, err := s.GetVersion(toID)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, metadata := range toVer.Files {
+		previous, ok := fromVer.Files[key]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, key)
+		case previous.Size != metadata.Size,
+			previous.Checksum != "" && metadata.Checksum != "" && previous.Checksum != metadata.Checksum:
+			result.Modified = append(result.Modified, key)
+		default:
+			result.Unchanged++
+		}
+	}
+	for key := range fromVer.Files {
+		if _, ok := toVer.Files[key]; !ok {
+			result.Deleted = append(result.Deleted, key)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Modified)
+	sort.Strings(result.Deleted)
+	return result, nil
+}
+
+// versionIndex returns the indexed entry of a version without loading its files
+func (s *Service) versionIndex(id string) (BackupVersion, error) {
+	for _, version := range s.GetVersions() {
+		if version.ID == id {
+			return version, nil
+		}
+	}
+	return BackupVersion{}, fmt.Errorf("version not found: %s", id)
+}
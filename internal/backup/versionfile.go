@@ -0,0 +1,153 @@
+// versionfile.go
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version manifest extensions. Compressed manifests are read regardless of
+// compress_versions so the option can be toggled on an existing target.
+const (
+	versionExt           = ".json"
+	compressedVersionExt = ".json.gz"
+)
+
+// versionID returns the ID encoded in a completed version's file name, or ""
+// if the name isn't a version manifest
+func versionID(name string) string {
+	if name == lifetimeStatsFile || name == latestLinkFile || name == targetMarkerFile || strings.HasSuffix(name, partialSuffix) {
+		return ""
+	}
+	for _, ext := range []string{compressedVersionExt, versionExt} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return ""
+}
+
+// Files naming the newest completed version, for scripts that want its
+// manifest at a fixed path. The pointer file holds just the ID and replaces
+// the link where symlinks can't be created.
+const (
+	latestLinkFile    = "latest.json"
+	latestPointerFile = "latest"
+)
+
+// updateLatest points latest.json at the manifest for id. The link is
+// swapped in with a rename so readers never see it missing.
+func (vm *VersionManager) updateLatest(id string) error {
+	versionsDir := filepath.Join(vm.baseDir, ".versions")
+	link := filepath.Join(versionsDir, latestLinkFile)
+	pointer := filepath.Join(versionsDir, latestPointerFile)
+
+	tmp := link + ".tmp-" + id
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(vm.existingVersionPath(id)), tmp); err == nil {
+		if err := os.Rename(tmp, link); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Remove(pointer); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	// No symlink support (e.g. Windows without the privilege): record the ID
+	if err := writeFileAtomic(pointer, []byte(id+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// latestID returns the version latest.json or the pointer file names, or ""
+// if neither exists
+func (vm *VersionManager) latestID() string {
+	versionsDir := filepath.Join(vm.baseDir, ".versions")
+	if target, err := os.Readlink(filepath.Join(versionsDir, latestLinkFile)); err == nil {
+		return versionID(filepath.Base(target))
+	}
+	if data, err := os.ReadFile(filepath.Join(versionsDir, latestPointerFile)); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
+// versionPath returns the manifest path for id in the configured format
+func (vm *VersionManager) versionPath(id string) string {
+	ext := versionExt
+	if vm.compress {
+		ext = compressedVersionExt
+	}
+	return filepath.Join(vm.baseDir, ".versions", id+ext)
+}
+
+// existingVersionPath returns the manifest path for id in whichever format
+// it was written
+func (vm *VersionManager) existingVersionPath(id string) string {
+	compressed := filepath.Join(vm.baseDir, ".versions", id+compressedVersionExt)
+	if _, err := os.Stat(compressed); err == nil {
+		return compressed
+	}
+	return filepath.Join(vm.baseDir, ".versions", id+versionExt)
+}
+
+// removeVersionFiles deletes the manifest for id in both formats
+func (vm *VersionManager) removeVersionFiles(id string) error {
+	for _, ext := range []string{versionExt, compressedVersionExt} {
+		filename := filepath.Join(vm.baseDir, ".versions", id+ext)
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVersionFile reads a manifest, decompressing it if it is gzipped
+func readVersionFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(filename, ".gz") {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", filepath.Base(filename), err)
+	}
+	defer zr.Close()
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", filepath.Base(filename), err)
+	}
+	return data, nil
+}
+
+// encodeVersionFile gzips data when the manifest is written compressed
+func encodeVersionFile(filename string, data []byte) ([]byte, error) {
+	if !strings.HasSuffix(filename, ".gz") {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+// versionindex.go
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// IndexReport describes the outcome of validating every version file
+type IndexReport struct {
+	Valid       int      // Version files that parsed completely
+	Corrupt     []string // Version files that could not be read or parsed, with the reason
+	Quarantined []string // Paths corrupt version files were moved to
+}
+
+// SkippedVersions returns the errors for version files that were skipped
+// because they could not be read when the history was loaded
+func (vm *VersionManager) SkippedVersions() []error {
+	return vm.skipped
+}
+
+// RebuildIndex fully parses every version file, optionally moves the ones
+// that fail to .versions/.quarantine, and reloads the version index
+func (vm *VersionManager) RebuildIndex(quarantineBad bool) (*IndexReport, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	versionsDir := filepath.Join(vm.baseDir, ".versions")
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory: %w", err)
+	}
+
+	report := &IndexReport{}
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || versionID(entry.Name()) == "" {
+			continue
+		}
+
+		filename := filepath.Join(versionsDir, entry.Name())
+		invalid := validateVersionFile(filename)
+		if invalid == nil {
+			report.Valid++
+			continue
+		}
+		report.Corrupt = append(report.Corrupt, fmt.Sprintf("%s: %v", entry.Name(), invalid))
+
+		if quarantineBad {
+			quarantinePath, err := quarantine(versionsDir, filename, now)
+			if err != nil {
+				return report, fmt.Errorf("failed to quarantine version file %s: %w", entry.Name(), err)
+			}
+			report.Quarantined = append(report.Quarantined, quarantinePath)
+		}
+	}
+	sort.Strings(report.Corrupt)
+
+	vm.versions = nil
+	vm.skipped = nil
+	if err := vm.loadVersions(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// validateVersionFile checks that a version file reads and parses completely
+func validateVersionFile(filename string) error {
+	data, err := readVersionFile(filename)
+	if err != nil {
+		return err
+	}
+	var version BackupVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return err
+	}
+	if version.ID == "" {
+		return fmt.Errorf("missing version ID")
+	}
+	return nil
+}
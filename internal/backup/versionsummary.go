@@ -0,0 +1,39 @@
+// versionsummary.go
+package backup
+
+import "time"
+
+// VersionSummary is the machine-readable form of a version's header, as
+// listed by --list-versions --output json. Duration is a Go duration string
+// such as "1m30.5s", with DurationSeconds alongside for arithmetic.
+type VersionSummary struct {
+	ID              string      `json:"id"`
+	Timestamp       time.Time   `json:"timestamp"`
+	Duration        string      `json:"duration"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	Status          string      `json:"status"`
+	Size            int64       `json:"size"` // Total size of the backup in bytes
+	AverageMBps     float64     `json:"average_mbps"`
+	Stats           BackupStats `json:"stats"`
+	Archive         string      `json:"archive,omitempty"`
+}
+
+// VersionSummaries returns a summary of every version, in GetVersions order
+func (s *Service) VersionSummaries() []VersionSummary {
+	versions := s.GetVersions()
+	summaries := make([]VersionSummary, 0, len(versions))
+	for _, v := range versions {
+		summaries = append(summaries, VersionSummary{
+			ID:              v.ID,
+			Timestamp:       v.Timestamp,
+			Duration:        v.Duration.String(),
+			DurationSeconds: v.Duration.Seconds(),
+			Status:          v.Status,
+			Size:            v.Size,
+			AverageMBps:     v.AverageMBps,
+			Stats:           v.Stats,
+			Archive:         v.Archive,
+		})
+	}
+	return summaries
+}
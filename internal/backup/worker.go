@@ -33,7 +33,6 @@ func (p *WorkerPool) Execute(ctx context.Context, tasks []CopyTask) error {
 	}
 
 	taskCh := make(chan CopyTask, len(tasks))
-	var wg sync.WaitGroup
 
 	// Feed tasks to channel first
 	for _, task := range tasks {
@@ -41,28 +40,107 @@ func (p *WorkerPool) Execute(ctx context.Context, tasks []CopyTask) error {
 	}
 	close(taskCh)
 
-	// Start workers
-	for i := 0; i < p.workers; i++ {
+	return p.ExecuteStream(ctx, taskCh)
+}
+
+// ExecuteStream processes tasks as they arrive on taskCh until it is closed or
+// the context is cancelled, so copying can begin before all tasks are known.
+// Failures are reported the same way as Execute.
+func (p *WorkerPool) ExecuteStream(ctx context.Context, taskCh <-chan CopyTask) error {
+	var wg sync.WaitGroup
+	failures := newErrorSummary()
+	var first error
+	var firstOnce sync.Once
+
+	// An adaptive pool starts every worker it may need and lets the limiter
+	// decide how many run at once
+	workers := p.workers
+	if p.adaptive {
+		workers = maxConcurrency
+		p.limiter = newConcurrencyLimiter(min(max(p.workers, minConcurrency), maxConcurrency))
+		adaptCtx, stopAdapting := context.WithCancel(ctx)
+		defer stopAdapting()
+		go p.adaptConcurrency(adaptCtx, adaptiveInterval)
+	}
+
+	// Start workers, closing drained once the first finds no more tasks
+	drained := make(chan struct{})
+	var drainOnce sync.Once
+	for i := 0; i < workers; i++ {
+		if !p.waitToLaunch(ctx, i, drained) {
+			break
+		}
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			for task := range taskCh {
+			for {
+				if p.limiter != nil {
+					p.limiter.acquire()
+				}
+				// A producer that has stopped without closing taskCh must
+				// not strand the workers once the run is cancelled
+				var task CopyTask
+				var ok bool
+				select {
+				case task, ok = <-taskCh:
+				case <-ctx.Done():
+					p.releaseSlot()
+					return
+				}
+				if !ok {
+					drainOnce.Do(func() { close(drained) })
+					p.releaseSlot()
+					return
+				}
 				select {
 				case <-ctx.Done():
+					p.releaseSlot()
 					return
 				default:
 					if err := p.executeWithRetry(ctx, task); err != nil {
 						log.Printf("Worker %d: Error processing task: %v", workerID, err)
+						failures.Add(task.Source, err)
+						// Once the run is stopping, later failures are its
+						// consequence rather than its cause
+						if p.failFast != nil && ctx.Err() == nil {
+							firstOnce.Do(func() {
+								first = newBackupError("Execute", task.Source, err)
+								p.failFast()
+							})
+						}
+					} else {
+						p.completedBytes.Add(task.Size)
+						p.completedTasks.Add(1)
 					}
 				}
+				p.releaseSlot()
 			}
 		}(i)
 	}
 
 	wg.Wait()
+
+	if first != nil {
+		return &failFastError{err: first, failures: failures}
+	}
+	if failures.Total() > 0 {
+		return newBackupError("Execute", "", failures)
+	}
 	return nil
 }
 
+// releaseSlot frees an adaptive pool's concurrency slot
+func (p *WorkerPool) releaseSlot() {
+	if p.limiter != nil {
+		p.limiter.release()
+	}
+}
+
+// Retries returns the number of retry attempts made since the pool was created
+func (p *WorkerPool) Retries() int64 {
+	return p.retries.Load()
+}
+
 // executeWithRetry attempts to execute a task with configurable retries
 func (p *WorkerPool) executeWithRetry(ctx context.Context, task CopyTask) error {
 	var lastErr error
@@ -72,6 +150,9 @@ func (p *WorkerPool) executeWithRetry(ctx context.Context, task CopyTask) error
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
+			if p.fileTimeout > 0 {
+				task.deadline = time.Now().Add(p.fileTimeout)
+			}
 			if err := p.copyFn(task); err == nil {
 				return nil
 			} else {
@@ -79,6 +160,7 @@ func (p *WorkerPool) executeWithRetry(ctx context.Context, task CopyTask) error
 
 				// Don't sleep on the last attempt
 				if attempt < p.retryAttempts {
+					p.retries.Add(1)
 					// Exponential backoff with jitter
 					backoff := p.retryDelay * time.Duration(attempt*attempt)
 					jitter := time.Duration(rand.Int63n(int64(time.Second)))
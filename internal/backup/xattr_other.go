@@ -0,0 +1,17 @@
+//go:build !linux
+
+// xattr_other.go
+package backup
+
+import "errors"
+
+// readXattrs reports no extended attributes where reading them isn't
+// supported
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// setXattr can't restore extended attributes where they aren't supported
+func setXattr(path, name string, value []byte) error {
+	return errors.New("extended attributes are not supported on this platform")
+}